@@ -0,0 +1,11 @@
+USE management_sys;
+
+ALTER TABLE students
+DROP FOREIGN KEY fk_students_department,
+DROP COLUMN dept_id;
+
+ALTER TABLE lecturers
+DROP FOREIGN KEY fk_lecturers_department,
+DROP COLUMN dept_id;
+
+DROP TABLE IF EXISTS departments;
@@ -0,0 +1,36 @@
+USE management_sys;
+
+CREATE TABLE IF NOT EXISTS departments(
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    code VARCHAR(50) NOT NULL UNIQUE,
+    name VARCHAR(100) NOT NULL UNIQUE,
+    head_lecturer_id INT NULL,
+    CONSTRAINT fk_departments_head FOREIGN KEY (head_lecturer_id) REFERENCES lecturers(id) ON DELETE SET NULL
+);
+
+-- "CSE", "cse" and "Computer Science" are the same department
+INSERT IGNORE INTO departments (code, name) VALUES ('CSE', 'Computer Science');
+
+-- every other free-text value becomes a department keyed by its upper-cased text
+INSERT IGNORE INTO departments (code, name)
+SELECT UPPER(TRIM(d.dept)), MIN(TRIM(d.dept))
+FROM (SELECT dept FROM students UNION ALL SELECT dept FROM lecturers) d
+WHERE TRIM(IFNULL(d.dept, '')) <> ''
+AND NOT EXISTS (SELECT 1 FROM departments x WHERE x.code = TRIM(d.dept) OR x.name = TRIM(d.dept))
+GROUP BY UPPER(TRIM(d.dept));
+
+ALTER TABLE students
+ADD COLUMN dept_id INT NULL,
+ADD CONSTRAINT fk_students_department FOREIGN KEY (dept_id) REFERENCES departments(id);
+
+ALTER TABLE lecturers
+ADD COLUMN dept_id INT NULL,
+ADD CONSTRAINT fk_lecturers_department FOREIGN KEY (dept_id) REFERENCES departments(id);
+
+UPDATE students s
+JOIN departments d ON d.code = TRIM(s.dept) OR d.name = TRIM(s.dept)
+SET s.dept_id = d.id, s.dept = d.code;
+
+UPDATE lecturers l
+JOIN departments d ON d.code = TRIM(l.dept) OR d.name = TRIM(l.dept)
+SET l.dept_id = d.id, l.dept = d.code;
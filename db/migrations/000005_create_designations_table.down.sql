@@ -0,0 +1,8 @@
+USE management_sys;
+
+DROP TABLE IF EXISTS lecturer_promotions;
+
+ALTER TABLE lecturers
+DROP FOREIGN KEY fk_lecturers_designation;
+
+DROP TABLE IF EXISTS designations;
@@ -0,0 +1,41 @@
+USE management_sys;
+
+CREATE TABLE IF NOT EXISTS designations(
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    name VARCHAR(50) NOT NULL UNIQUE,
+    rank_order INT NOT NULL
+);
+
+INSERT IGNORE INTO designations (name, rank_order) VALUES
+    ('Lecturer', 1),
+    ('Senior Lecturer', 2),
+    ('Assistant Professor', 3),
+    ('Associate Professor', 4),
+    ('Professor', 5);
+
+-- keep designations already in use; they sit unranked until HR places them
+INSERT IGNORE INTO designations (name, rank_order)
+SELECT MIN(TRIM(designation)), 0 FROM lecturers
+WHERE TRIM(designation) <> ''
+GROUP BY UPPER(TRIM(designation));
+
+UPDATE lecturers l
+JOIN designations d ON d.name = TRIM(l.designation)
+SET l.designation = d.name;
+
+ALTER TABLE lecturers
+ADD CONSTRAINT fk_lecturers_designation FOREIGN KEY (designation) REFERENCES designations(name) ON UPDATE CASCADE;
+
+CREATE TABLE IF NOT EXISTS lecturer_promotions(
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    lecturer_id INT NOT NULL,
+    from_designation VARCHAR(50) NULL,
+    to_designation VARCHAR(50) NOT NULL,
+    effective_date DATE NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (lecturer_id) REFERENCES lecturers(id) ON DELETE CASCADE
+);
+
+-- the current designation is where every lecturer's history starts
+INSERT INTO lecturer_promotions (lecturer_id, from_designation, to_designation, effective_date)
+SELECT id, NULL, designation, CURDATE() FROM lecturers;
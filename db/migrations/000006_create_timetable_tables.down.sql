@@ -0,0 +1,9 @@
+USE management_sys;
+
+DROP TABLE IF EXISTS enrollments;
+
+DROP TABLE IF EXISTS course_sessions;
+
+DROP TABLE IF EXISTS rooms;
+
+DROP TABLE IF EXISTS courses;
@@ -0,0 +1,38 @@
+USE management_sys;
+
+CREATE TABLE IF NOT EXISTS courses(
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    code VARCHAR(20) NOT NULL UNIQUE,
+    title VARCHAR(100) NOT NULL,
+    dept_id INT NULL,
+    FOREIGN KEY (dept_id) REFERENCES departments(id)
+);
+
+CREATE TABLE IF NOT EXISTS rooms(
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    name VARCHAR(50) NOT NULL UNIQUE,
+    capacity INT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS course_sessions(
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    course_id INT NOT NULL,
+    room_id INT NOT NULL,
+    lecturer_id INT NOT NULL,
+    weekday TINYINT NOT NULL,
+    start_time TIME NOT NULL,
+    end_time TIME NOT NULL,
+    FOREIGN KEY (course_id) REFERENCES courses(id) ON DELETE CASCADE,
+    FOREIGN KEY (room_id) REFERENCES rooms(id),
+    FOREIGN KEY (lecturer_id) REFERENCES lecturers(id),
+    INDEX idx_sessions_weekday (weekday, start_time)
+);
+
+CREATE TABLE IF NOT EXISTS enrollments(
+    student_id INT NOT NULL,
+    course_id INT NOT NULL,
+    enrolled_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (student_id, course_id),
+    FOREIGN KEY (student_id) REFERENCES students(id) ON DELETE CASCADE,
+    FOREIGN KEY (course_id) REFERENCES courses(id) ON DELETE CASCADE
+);
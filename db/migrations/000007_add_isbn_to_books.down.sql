@@ -0,0 +1,11 @@
+USE management_sys;
+
+DROP INDEX idx_books_author ON books;
+
+ALTER TABLE books
+ADD CONSTRAINT author UNIQUE (author);
+
+ALTER TABLE books
+DROP INDEX uq_books_isbn13,
+DROP COLUMN isbn13,
+DROP COLUMN isbn10;
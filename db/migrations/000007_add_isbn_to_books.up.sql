@@ -0,0 +1,20 @@
+USE management_sys;
+
+ALTER TABLE books
+ADD COLUMN isbn10 VARCHAR(10) NULL AFTER author,
+ADD COLUMN isbn13 VARCHAR(13) NULL AFTER isbn10,
+ADD CONSTRAINT uq_books_isbn13 UNIQUE (isbn13);
+
+-- 000001 declared author UNIQUE and 000003's MODIFY kept the index, so
+-- drop every unique index on it: one author may write many books
+SET @drop_author_unique = (
+    SELECT CONCAT('ALTER TABLE books ', GROUP_CONCAT(CONCAT('DROP INDEX `', index_name, '`') SEPARATOR ', '))
+    FROM information_schema.statistics
+    WHERE table_schema = DATABASE() AND table_name = 'books' AND column_name = 'author' AND non_unique = 0
+);
+SET @drop_author_unique = IFNULL(@drop_author_unique, 'DO 0');
+PREPARE stmt FROM @drop_author_unique;
+EXECUTE stmt;
+DEALLOCATE PREPARE stmt;
+
+CREATE INDEX idx_books_author ON books (author);
@@ -0,0 +1,15 @@
+USE management_sys;
+
+ALTER TABLE books
+ADD COLUMN available_copies INT AFTER isbn13;
+
+UPDATE books b
+SET b.available_copies = (
+    SELECT COUNT(*) FROM book_copies c WHERE c.book_id = b.book_id AND c.status = 'available'
+);
+
+ALTER TABLE borrow_records
+DROP FOREIGN KEY fk_borrow_records_copy,
+DROP COLUMN copy_id;
+
+DROP TABLE IF EXISTS book_copies;
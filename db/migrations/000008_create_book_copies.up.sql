@@ -0,0 +1,43 @@
+USE management_sys;
+
+CREATE TABLE IF NOT EXISTS book_copies(
+    copy_id INT AUTO_INCREMENT PRIMARY KEY,
+    book_id INT NOT NULL,
+    barcode VARCHAR(32) NOT NULL UNIQUE,
+    copy_condition VARCHAR(20) NOT NULL DEFAULT 'good',
+    shelf_location VARCHAR(50) NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'available',
+    added_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    retired_at DATETIME NULL,
+    INDEX idx_book_copies_book_status (book_id, status),
+    CONSTRAINT fk_book_copies_book FOREIGN KEY (book_id) REFERENCES books(book_id) ON DELETE CASCADE
+);
+
+-- one available copy for every copy counted in books.available_copies
+INSERT INTO book_copies (book_id, barcode)
+WITH RECURSIVE n (i) AS (
+    SELECT 1
+    UNION ALL
+    SELECT i + 1 FROM n WHERE i < (SELECT IFNULL(MAX(available_copies), 0) FROM books)
+)
+SELECT b.book_id, CONCAT('B', LPAD(b.book_id, 6, '0'), '-', LPAD(n.i, 3, '0'))
+FROM books b
+JOIN n ON n.i <= b.available_copies;
+
+ALTER TABLE borrow_records
+ADD COLUMN copy_id INT NULL AFTER book_id,
+ADD CONSTRAINT fk_borrow_records_copy FOREIGN KEY (copy_id) REFERENCES book_copies(copy_id);
+
+-- copies that are out on loan were not counted, so give each open loan one
+INSERT INTO book_copies (book_id, barcode, status)
+SELECT book_id, CONCAT('L', LPAD(borrow_id, 8, '0')), 'borrowed'
+FROM borrow_records
+WHERE return_date IS NULL;
+
+UPDATE borrow_records r
+JOIN book_copies c ON c.barcode = CONCAT('L', LPAD(r.borrow_id, 8, '0'))
+SET r.copy_id = c.copy_id
+WHERE r.return_date IS NULL;
+
+ALTER TABLE books
+DROP COLUMN available_copies;
@@ -0,0 +1,8 @@
+USE management_sys;
+
+DROP TABLE IF EXISTS fines;
+
+ALTER TABLE borrow_records
+DROP COLUMN due_date;
+
+DROP TABLE IF EXISTS loan_policies;
@@ -0,0 +1,37 @@
+USE management_sys;
+
+-- fine_per_day and max_fine are in cents
+CREATE TABLE IF NOT EXISTS loan_policies(
+    user_type VARCHAR(20) PRIMARY KEY,
+    loan_days INT NOT NULL,
+    fine_per_day INT NOT NULL,
+    max_fine INT NOT NULL
+);
+
+INSERT INTO loan_policies (user_type, loan_days, fine_per_day, max_fine) VALUES
+('student', 14, 50, 2000),
+('lecturer', 30, 50, 2000);
+
+ALTER TABLE borrow_records
+ADD COLUMN due_date DATE NULL AFTER borrow_date;
+
+UPDATE borrow_records r
+JOIN loan_policies p ON p.user_type = r.user_type
+SET r.due_date = r.borrow_date + INTERVAL p.loan_days DAY
+WHERE r.borrow_date IS NOT NULL;
+
+-- amount is in cents
+CREATE TABLE IF NOT EXISTS fines(
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    borrow_id INT NULL,
+    user_id INT NOT NULL,
+    user_type VARCHAR(20) NOT NULL,
+    kind VARCHAR(20) NOT NULL DEFAULT 'overdue',
+    amount INT NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'unpaid',
+    note VARCHAR(255) NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    settled_at DATETIME NULL,
+    INDEX idx_fines_user (user_type, user_id, status),
+    CONSTRAINT fk_fines_borrow FOREIGN KEY (borrow_id) REFERENCES borrow_records(borrow_id)
+);
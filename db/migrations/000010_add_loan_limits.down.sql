@@ -0,0 +1,5 @@
+USE management_sys;
+
+ALTER TABLE loan_policies
+DROP COLUMN fine_limit,
+DROP COLUMN max_loans;
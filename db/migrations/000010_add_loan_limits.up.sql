@@ -0,0 +1,8 @@
+USE management_sys;
+
+-- fine_limit is in cents: unpaid fines above it block borrowing
+ALTER TABLE loan_policies
+ADD COLUMN max_loans INT NOT NULL DEFAULT 5,
+ADD COLUMN fine_limit INT NOT NULL DEFAULT 0;
+
+UPDATE loan_policies SET max_loans = 10 WHERE user_type = 'lecturer';
@@ -0,0 +1,8 @@
+USE management_sys;
+
+ALTER TABLE loan_policies
+DROP COLUMN pickup_days;
+
+UPDATE book_copies SET status = 'available' WHERE status = 'on_hold';
+
+DROP TABLE IF EXISTS holds;
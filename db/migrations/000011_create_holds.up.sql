@@ -0,0 +1,21 @@
+USE management_sys;
+
+CREATE TABLE IF NOT EXISTS holds(
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    book_id INT NOT NULL,
+    user_id INT NOT NULL,
+    user_type VARCHAR(20) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'waiting',
+    copy_id INT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    ready_at DATETIME NULL,
+    expires_at DATETIME NULL,
+    INDEX idx_holds_queue (book_id, status, id),
+    INDEX idx_holds_user (user_type, user_id, status),
+    CONSTRAINT fk_holds_book FOREIGN KEY (book_id) REFERENCES books(book_id) ON DELETE CASCADE,
+    CONSTRAINT fk_holds_copy FOREIGN KEY (copy_id) REFERENCES book_copies(copy_id) ON DELETE SET NULL
+);
+
+-- days a copy set aside for a hold waits to be picked up
+ALTER TABLE loan_policies
+ADD COLUMN pickup_days INT NOT NULL DEFAULT 3;
@@ -0,0 +1,10 @@
+USE management_sys;
+
+DROP TABLE IF EXISTS loan_renewals;
+
+ALTER TABLE borrow_records
+DROP COLUMN renewals;
+
+ALTER TABLE loan_policies
+DROP COLUMN grace_days,
+DROP COLUMN max_renewals;
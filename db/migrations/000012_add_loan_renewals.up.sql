@@ -0,0 +1,18 @@
+USE management_sys;
+
+ALTER TABLE loan_policies
+ADD COLUMN max_renewals INT NOT NULL DEFAULT 2,
+ADD COLUMN grace_days INT NOT NULL DEFAULT 0;
+
+ALTER TABLE borrow_records
+ADD COLUMN renewals INT NOT NULL DEFAULT 0 AFTER due_date;
+
+CREATE TABLE IF NOT EXISTS loan_renewals(
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    borrow_id INT NOT NULL,
+    old_due_date DATE NULL,
+    new_due_date DATE NOT NULL,
+    renewed_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_loan_renewals_borrow (borrow_id),
+    CONSTRAINT fk_loan_renewals_borrow FOREIGN KEY (borrow_id) REFERENCES borrow_records(borrow_id) ON DELETE CASCADE
+);
@@ -0,0 +1,4 @@
+USE management_sys;
+
+ALTER TABLE borrow_records
+DROP COLUMN returned_by;
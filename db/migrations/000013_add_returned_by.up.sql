@@ -0,0 +1,5 @@
+USE management_sys;
+
+-- who processed the return, e.g. the librarian at the desk
+ALTER TABLE borrow_records
+ADD COLUMN returned_by VARCHAR(100) NULL AFTER return_date;
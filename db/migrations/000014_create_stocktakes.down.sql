@@ -0,0 +1,4 @@
+USE management_sys;
+
+DROP TABLE IF EXISTS stocktake_scans;
+DROP TABLE IF EXISTS stocktakes;
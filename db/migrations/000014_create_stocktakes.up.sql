@@ -0,0 +1,19 @@
+USE management_sys;
+
+CREATE TABLE IF NOT EXISTS stocktakes(
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    location VARCHAR(50) NULL,
+    started_by VARCHAR(100) NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'open',
+    started_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    closed_at DATETIME NULL
+);
+
+CREATE TABLE IF NOT EXISTS stocktake_scans(
+    stocktake_id INT NOT NULL,
+    copy_id INT NOT NULL,
+    scanned_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (stocktake_id, copy_id),
+    CONSTRAINT fk_stocktake_scans_stocktake FOREIGN KEY (stocktake_id) REFERENCES stocktakes(id) ON DELETE CASCADE,
+    CONSTRAINT fk_stocktake_scans_copy FOREIGN KEY (copy_id) REFERENCES book_copies(copy_id) ON DELETE CASCADE
+);
@@ -0,0 +1,9 @@
+USE management_sys;
+
+ALTER TABLE books
+MODIFY author VARCHAR(100) NOT NULL;
+
+DROP TABLE IF EXISTS book_categories;
+DROP TABLE IF EXISTS book_authors;
+DROP TABLE IF EXISTS categories;
+DROP TABLE IF EXISTS authors;
@@ -0,0 +1,44 @@
+USE management_sys;
+
+CREATE TABLE IF NOT EXISTS authors(
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    CONSTRAINT uq_authors_name UNIQUE (name)
+);
+
+CREATE TABLE IF NOT EXISTS categories(
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    name VARCHAR(50) NOT NULL,
+    CONSTRAINT uq_categories_name UNIQUE (name)
+);
+
+-- position orders the authors of a book as on its title page
+CREATE TABLE IF NOT EXISTS book_authors(
+    book_id INT NOT NULL,
+    author_id INT NOT NULL,
+    position INT NOT NULL DEFAULT 0,
+    PRIMARY KEY (book_id, author_id),
+    INDEX idx_book_authors_author (author_id),
+    CONSTRAINT fk_book_authors_book FOREIGN KEY (book_id) REFERENCES books(book_id) ON DELETE CASCADE,
+    CONSTRAINT fk_book_authors_author FOREIGN KEY (author_id) REFERENCES authors(id)
+);
+
+CREATE TABLE IF NOT EXISTS book_categories(
+    book_id INT NOT NULL,
+    category_id INT NOT NULL,
+    PRIMARY KEY (book_id, category_id),
+    INDEX idx_book_categories_category (category_id),
+    CONSTRAINT fk_book_categories_book FOREIGN KEY (book_id) REFERENCES books(book_id) ON DELETE CASCADE,
+    CONSTRAINT fk_book_categories_category FOREIGN KEY (category_id) REFERENCES categories(id)
+);
+
+-- every author string so far names one author
+INSERT IGNORE INTO authors (name)
+SELECT DISTINCT TRIM(author) FROM books WHERE TRIM(IFNULL(author, '')) <> '';
+
+INSERT IGNORE INTO book_authors (book_id, author_id, position)
+SELECT b.book_id, a.id, 0 FROM books b JOIN authors a ON a.name = TRIM(b.author);
+
+-- books.author now holds the names of all the authors of a book
+ALTER TABLE books
+MODIFY author VARCHAR(255) NOT NULL;
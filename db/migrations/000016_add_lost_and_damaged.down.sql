@@ -0,0 +1,10 @@
+USE management_sys;
+
+ALTER TABLE borrow_records
+DROP COLUMN outcome;
+
+ALTER TABLE book_copies
+DROP COLUMN condition_note;
+
+ALTER TABLE loan_policies
+DROP COLUMN replacement_fee;
@@ -0,0 +1,14 @@
+USE management_sys;
+
+-- charged in cents for a lost book unless staff set the fee
+ALTER TABLE loan_policies
+ADD COLUMN replacement_fee INT NOT NULL DEFAULT 3000;
+
+ALTER TABLE book_copies
+ADD COLUMN condition_note VARCHAR(255) NULL AFTER shelf_location;
+
+-- how a closed loan ended: returned, lost or damaged
+ALTER TABLE borrow_records
+ADD COLUMN outcome VARCHAR(20) NULL AFTER returned_by;
+
+UPDATE borrow_records SET outcome = 'returned' WHERE return_date IS NOT NULL;
@@ -0,0 +1,18 @@
+USE management_sys;
+
+DROP TABLE IF EXISTS transfers;
+
+ALTER TABLE borrow_records
+DROP FOREIGN KEY fk_borrow_records_branch,
+DROP FOREIGN KEY fk_borrow_records_return_branch,
+DROP COLUMN branch_id,
+DROP COLUMN return_branch_id;
+
+-- copies on their way between branches are back on the shelf
+UPDATE book_copies SET status = 'available' WHERE status = 'in_transit';
+
+ALTER TABLE book_copies
+DROP FOREIGN KEY fk_book_copies_branch,
+DROP COLUMN branch_id;
+
+DROP TABLE IF EXISTS branches;
@@ -0,0 +1,43 @@
+USE management_sys;
+
+CREATE TABLE IF NOT EXISTS branches(
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    code VARCHAR(10) NOT NULL UNIQUE,
+    name VARCHAR(100) NOT NULL,
+    address VARCHAR(255) NULL
+);
+
+-- every copy belongs to a branch, the existing ones to the main library
+INSERT INTO branches (code, name) VALUES ('MAIN', 'Main Library');
+
+ALTER TABLE book_copies
+ADD COLUMN branch_id INT NULL AFTER book_id;
+
+UPDATE book_copies SET branch_id = (SELECT id FROM branches WHERE code = 'MAIN');
+
+ALTER TABLE book_copies
+MODIFY branch_id INT NOT NULL,
+ADD CONSTRAINT fk_book_copies_branch FOREIGN KEY (branch_id) REFERENCES branches(id);
+
+-- where a loan was borrowed and returned
+ALTER TABLE borrow_records
+ADD COLUMN branch_id INT NULL AFTER copy_id,
+ADD COLUMN return_branch_id INT NULL AFTER return_date,
+ADD CONSTRAINT fk_borrow_records_branch FOREIGN KEY (branch_id) REFERENCES branches(id),
+ADD CONSTRAINT fk_borrow_records_return_branch FOREIGN KEY (return_branch_id) REFERENCES branches(id);
+
+CREATE TABLE IF NOT EXISTS transfers(
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    copy_id INT NOT NULL,
+    from_branch_id INT NOT NULL,
+    to_branch_id INT NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'in_transit',
+    reason VARCHAR(255) NULL,
+    requested_by VARCHAR(100) NULL,
+    sent_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    closed_at DATETIME NULL,
+    INDEX idx_transfers_to_status (to_branch_id, status),
+    CONSTRAINT fk_transfers_copy FOREIGN KEY (copy_id) REFERENCES book_copies(copy_id) ON DELETE CASCADE,
+    CONSTRAINT fk_transfers_from FOREIGN KEY (from_branch_id) REFERENCES branches(id),
+    CONSTRAINT fk_transfers_to FOREIGN KEY (to_branch_id) REFERENCES branches(id)
+);
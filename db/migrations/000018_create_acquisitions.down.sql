@@ -0,0 +1,5 @@
+USE management_sys;
+
+DROP TABLE IF EXISTS notifications;
+
+DROP TABLE IF EXISTS acquisitions;
@@ -0,0 +1,34 @@
+USE management_sys;
+
+CREATE TABLE IF NOT EXISTS acquisitions(
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    user_id INT NOT NULL,
+    user_type VARCHAR(20) NOT NULL,
+    title VARCHAR(100) NOT NULL,
+    author VARCHAR(255) NOT NULL,
+    isbn13 VARCHAR(13) NULL,
+    course_id INT NULL,
+    reason VARCHAR(500) NULL,
+    copies INT NOT NULL DEFAULT 1,
+    status VARCHAR(20) NOT NULL DEFAULT 'suggested',
+    note VARCHAR(255) NULL,
+    processed_by VARCHAR(100) NULL,
+    book_id INT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    INDEX idx_acquisitions_status (status, id),
+    INDEX idx_acquisitions_user (user_type, user_id),
+    CONSTRAINT fk_acquisitions_course FOREIGN KEY (course_id) REFERENCES courses(id) ON DELETE SET NULL,
+    CONSTRAINT fk_acquisitions_book FOREIGN KEY (book_id) REFERENCES books(book_id) ON DELETE SET NULL
+);
+
+CREATE TABLE IF NOT EXISTS notifications(
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    user_id INT NOT NULL,
+    user_type VARCHAR(20) NOT NULL,
+    kind VARCHAR(30) NOT NULL,
+    message VARCHAR(500) NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    read_at DATETIME NULL,
+    INDEX idx_notifications_user (user_type, user_id, read_at)
+);
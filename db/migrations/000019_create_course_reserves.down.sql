@@ -0,0 +1,14 @@
+USE management_sys;
+
+ALTER TABLE borrow_records
+DROP COLUMN due_at;
+
+ALTER TABLE book_copies
+DROP FOREIGN KEY fk_book_copies_reserve,
+DROP COLUMN reserve_id;
+
+DROP TABLE IF EXISTS course_reserves;
+
+ALTER TABLE loan_policies
+DROP COLUMN short_loan_hours,
+DROP COLUMN short_fine_per_hour;
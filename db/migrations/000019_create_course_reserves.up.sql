@@ -0,0 +1,32 @@
+USE management_sys;
+
+-- short loans of reserve copies last short_loan_hours unless the reserve
+-- sets its own, and short_fine_per_hour is in cents
+ALTER TABLE loan_policies
+ADD COLUMN short_loan_hours INT NOT NULL DEFAULT 4,
+ADD COLUMN short_fine_per_hour INT NOT NULL DEFAULT 100;
+
+-- books a lecturer puts on reserve for a course until the end of term
+CREATE TABLE IF NOT EXISTS course_reserves(
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    course_id INT NOT NULL,
+    book_id INT NOT NULL,
+    lecturer_id INT NULL,
+    loan_hours INT NULL,
+    ends_on DATE NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'active',
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    released_at DATETIME NULL,
+    INDEX idx_course_reserves_status (status, ends_on),
+    CONSTRAINT fk_course_reserves_course FOREIGN KEY (course_id) REFERENCES courses(id) ON DELETE CASCADE,
+    CONSTRAINT fk_course_reserves_book FOREIGN KEY (book_id) REFERENCES books(book_id) ON DELETE CASCADE,
+    CONSTRAINT fk_course_reserves_lecturer FOREIGN KEY (lecturer_id) REFERENCES lecturers(id) ON DELETE SET NULL
+);
+
+ALTER TABLE book_copies
+ADD COLUMN reserve_id INT NULL AFTER branch_id,
+ADD CONSTRAINT fk_book_copies_reserve FOREIGN KEY (reserve_id) REFERENCES course_reserves(id) ON DELETE SET NULL;
+
+-- short loans are due at a time of day
+ALTER TABLE borrow_records
+ADD COLUMN due_at DATETIME NULL AFTER due_date;
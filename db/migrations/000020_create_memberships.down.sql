@@ -0,0 +1,7 @@
+USE management_sys;
+
+DROP TABLE IF EXISTS memberships;
+
+ALTER TABLE loan_policies
+DROP COLUMN suspend_overdue_days,
+DROP COLUMN suspend_fines;
@@ -0,0 +1,22 @@
+USE management_sys;
+
+-- a loan suspend_overdue_days overdue, or unpaid fines above suspend_fines
+-- (in cents), suspend the membership until they are settled
+ALTER TABLE loan_policies
+ADD COLUMN suspend_overdue_days INT NOT NULL DEFAULT 30,
+ADD COLUMN suspend_fines INT NOT NULL DEFAULT 5000;
+
+-- users without a row have an active membership that never expires
+CREATE TABLE IF NOT EXISTS memberships(
+    user_type VARCHAR(20) NOT NULL,
+    user_id INT NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'active',
+    automatic BOOLEAN NOT NULL DEFAULT FALSE,
+    reason VARCHAR(255) NULL,
+    suspended_by VARCHAR(100) NULL,
+    suspended_at DATETIME NULL,
+    expires_on DATE NULL,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_type, user_id),
+    INDEX idx_memberships_status (status)
+);
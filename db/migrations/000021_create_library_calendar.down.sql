@@ -0,0 +1,4 @@
+USE management_sys;
+
+DROP TABLE IF EXISTS library_holidays;
+DROP TABLE IF EXISTS library_closed_weekdays;
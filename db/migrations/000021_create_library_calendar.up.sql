@@ -0,0 +1,14 @@
+USE management_sys;
+
+-- weekdays the library is closed every week, 0 for Sunday to 6 for Saturday
+CREATE TABLE IF NOT EXISTS library_closed_weekdays(
+    weekday TINYINT NOT NULL PRIMARY KEY
+);
+
+-- days the library is closed besides its weekly closing days
+CREATE TABLE IF NOT EXISTS library_holidays(
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    day DATE NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    UNIQUE KEY uq_library_holidays_day (day)
+);
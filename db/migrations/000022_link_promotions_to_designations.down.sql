@@ -0,0 +1,5 @@
+USE management_sys;
+
+ALTER TABLE lecturer_promotions
+DROP FOREIGN KEY fk_lecturer_promotions_from,
+DROP FOREIGN KEY fk_lecturer_promotions_to;
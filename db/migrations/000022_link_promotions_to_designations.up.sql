@@ -0,0 +1,11 @@
+USE management_sys;
+
+-- history naming a designation that no longer exists keeps it, unranked
+INSERT IGNORE INTO designations (name, rank_order)
+SELECT to_designation, 0 FROM lecturer_promotions
+UNION SELECT from_designation, 0 FROM lecturer_promotions WHERE from_designation IS NOT NULL;
+
+-- renaming a designation renames it in the history too
+ALTER TABLE lecturer_promotions
+ADD CONSTRAINT fk_lecturer_promotions_from FOREIGN KEY (from_designation) REFERENCES designations(name) ON UPDATE CASCADE,
+ADD CONSTRAINT fk_lecturer_promotions_to FOREIGN KEY (to_designation) REFERENCES designations(name) ON UPDATE CASCADE;
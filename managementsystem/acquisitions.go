@@ -0,0 +1,321 @@
+package managementsystem
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"managementsystem/validation"
+)
+
+// Acquisition statuses. A suggestion is approved or rejected, an approved
+// one ordered (or still rejected), and an ordered one received.
+const (
+	acquisitionSuggested = "suggested"
+	acquisitionApproved  = "approved"
+	acquisitionRejected  = "rejected"
+	acquisitionOrdered   = "ordered"
+	acquisitionReceived  = "received"
+)
+
+// acquisitionFrom lists the statuses each status can be reached from.
+var acquisitionFrom = map[string][]string{
+	acquisitionApproved: {acquisitionSuggested},
+	acquisitionRejected: {acquisitionSuggested, acquisitionApproved},
+	acquisitionOrdered:  {acquisitionApproved},
+	acquisitionReceived: {acquisitionOrdered},
+}
+
+// Acquisition is a book a student or lecturer suggests the library buys,
+// for one of their courses if CourseID is set. ISBN may be an ISBN-10 or
+// ISBN-13 and is kept as the latter.
+type Acquisition struct {
+	ID          int    `json:"id"`
+	UserID      int    `json:"user_id" validate:"min=1"`
+	UserType    string `json:"user_type" validate:"enum=student|lecturer"`
+	Title       string `json:"title" validate:"trimmed,required,max=100"`
+	Author      string `json:"author" validate:"trimmed,required,max=255"`
+	ISBN        string `json:"isbn"`
+	CourseID    *int   `json:"course_id"`
+	Reason      string `json:"reason" validate:"trimmed,max=500"`
+	Copies      int    `json:"copies" validate:"min=1,max=100"`
+	Status      string `json:"status"`
+	Note        string `json:"note"` // the librarian's, on the latest decision
+	ProcessedBy string `json:"processed_by"`
+	BookID      *int   `json:"book_id"` // the book stocked once received
+	CreatedAt   string `json:"created_at"`
+	UpdatedAt   string `json:"updated_at"`
+}
+
+// AcquisitionDecision moves an acquisition on. Copies, on receipt,
+// overrides the number asked for.
+type AcquisitionDecision struct {
+	Note        string `json:"note" validate:"trimmed,max=255"`
+	ProcessedBy string `json:"processed_by" validate:"trimmed,max=100"`
+	Copies      int    `json:"copies" validate:"min=0,max=100"`
+}
+
+const acquisitionSelect = `SELECT id, user_id, user_type, title, author, IFNULL(isbn13, ''), course_id, IFNULL(reason, ''), copies, status,
+	IFNULL(note, ''), IFNULL(processed_by, ''), book_id,
+	DATE_FORMAT(created_at, '%Y-%m-%d %H:%i:%s'), DATE_FORMAT(updated_at, '%Y-%m-%d %H:%i:%s')
+	FROM acquisitions`
+
+func scanAcquisition(row interface{ Scan(...any) error }, a *Acquisition) error {
+	return row.Scan(&a.ID, &a.UserID, &a.UserType, &a.Title, &a.Author, &a.ISBN, &a.CourseID, &a.Reason, &a.Copies, &a.Status,
+		&a.Note, &a.ProcessedBy, &a.BookID, &a.CreatedAt, &a.UpdatedAt)
+}
+
+// loadAcquisition reads an acquisition, locking it when q is a transaction.
+func loadAcquisition(q querier, id int) (*Acquisition, error) {
+	var a Acquisition
+	if err := scanAcquisition(q.QueryRow(acquisitionSelect+" WHERE id=? FOR UPDATE", id), &a); err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// validation
+func ValidateAcquisition(a *Acquisition) error {
+	if a.Copies == 0 {
+		a.Copies = 1
+	}
+	if err := validation.Struct(a); err != nil {
+		return err
+	}
+	if a.ISBN != "" {
+		isbn13, err := ISBNTo13(a.ISBN)
+		if err != nil {
+			return err
+		}
+		a.ISBN = isbn13
+	}
+	return nil
+}
+
+// Suggest a book to buy
+func (h *HybridHandler5) SuggestAcquisitionHandler(w http.ResponseWriter, r *http.Request) {
+	var a Acquisition
+	if err := json.NewDecoder(r.Body).Decode(&a); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := ValidateAcquisition(&a); err != nil {
+		writeValidationError(w, r, err)
+		return
+	}
+	borrower, err := loadBorrower(h.MySQL.DB, a.UserType, a.UserID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !borrower.Exists {
+		http.Error(w, "no such "+a.UserType, http.StatusNotFound)
+		return
+	}
+	if a.CourseID != nil {
+		var exists bool
+		if err := h.MySQL.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM courses WHERE id=?)", *a.CourseID).Scan(&exists); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !exists {
+			http.Error(w, "unknown course", http.StatusBadRequest)
+			return
+		}
+	}
+	res, err := h.MySQL.DB.Exec("INSERT INTO acquisitions (user_id, user_type, title, author, isbn13, course_id, reason, copies, status) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		a.UserID, a.UserType, a.Title, a.Author, nullString{&a.ISBN}, a.CourseID, nullString{&a.Reason}, a.Copies, acquisitionSuggested)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	suggested, err := loadAcquisition(h.MySQL.DB, int(id))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusCreated, suggested)
+}
+
+// List acquisitions, filtered by ?status=, ?user_type= and ?user_id=
+func (h *HybridHandler5) ListAcquisitionsHandler(w http.ResponseWriter, r *http.Request) {
+	limit, offset, err := pagination(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	where, args := "1=1", []any{}
+	query := r.URL.Query()
+	if status := query.Get("status"); status != "" {
+		if _, ok := acquisitionFrom[status]; !ok && status != acquisitionSuggested {
+			http.Error(w, "status must be suggested, approved, rejected, ordered or received", http.StatusBadRequest)
+			return
+		}
+		where += " AND status=?"
+		args = append(args, status)
+	}
+	if userType := query.Get("user_type"); userType != "" {
+		where += " AND user_type=?"
+		args = append(args, userType)
+	}
+	if v := query.Get("user_id"); v != "" {
+		userID, err := strconv.Atoi(v)
+		if err != nil {
+			http.Error(w, "invalid user_id", http.StatusBadRequest)
+			return
+		}
+		where += " AND user_id=?"
+		args = append(args, userID)
+	}
+	rows, err := h.MySQL.DB.Query(acquisitionSelect+" WHERE "+where+" ORDER BY id DESC LIMIT ? OFFSET ?", append(args, limit, offset)...)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	acquisitions := []Acquisition{}
+	for rows.Next() {
+		var a Acquisition
+		if err := scanAcquisition(rows, &a); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		acquisitions = append(acquisitions, a)
+	}
+	if err := rows.Err(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, acquisitions)
+}
+
+// Get acquisition
+func (h *HybridHandler5) GetAcquisitionHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "invalid acquisition id", http.StatusBadRequest)
+		return
+	}
+	a, err := loadAcquisition(h.MySQL.DB, id)
+	if err != nil {
+		writeLoadError(w, "acquisition", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, a)
+}
+
+// Approve a suggestion
+func (h *HybridHandler5) ApproveAcquisitionHandler(w http.ResponseWriter, r *http.Request) {
+	h.decideAcquisition(w, r, acquisitionApproved)
+}
+
+// Reject a suggestion
+func (h *HybridHandler5) RejectAcquisitionHandler(w http.ResponseWriter, r *http.Request) {
+	h.decideAcquisition(w, r, acquisitionRejected)
+}
+
+// Order an approved book
+func (h *HybridHandler5) OrderAcquisitionHandler(w http.ResponseWriter, r *http.Request) {
+	h.decideAcquisition(w, r, acquisitionOrdered)
+}
+
+// Receive an ordered book into the library
+func (h *HybridHandler5) ReceiveAcquisitionHandler(w http.ResponseWriter, r *http.Request) {
+	h.decideAcquisition(w, r, acquisitionReceived)
+}
+
+// decideAcquisition moves an acquisition to status and tells the user who
+// suggested it. On receipt the book is created, or its copies added when
+// the library has it already.
+func (h *HybridHandler5) decideAcquisition(w http.ResponseWriter, r *http.Request, status string) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "invalid acquisition id", http.StatusBadRequest)
+		return
+	}
+	var decision AcquisitionDecision
+	if err := json.NewDecoder(r.Body).Decode(&decision); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := validation.Struct(decision); err != nil {
+		writeValidationError(w, r, err)
+		return
+	}
+	tx, err := h.MySQL.DB.Begin()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	a, err := loadAcquisition(tx, id)
+	if err != nil {
+		writeLoadError(w, "acquisition", err)
+		return
+	}
+	allowed := false
+	for _, from := range acquisitionFrom[status] {
+		allowed = allowed || a.Status == from
+	}
+	if !allowed {
+		http.Error(w, fmt.Sprintf("acquisition is %s and cannot be %s", a.Status, status), http.StatusConflict)
+		return
+	}
+	message := fmt.Sprintf("Your suggestion %q was %s.", a.Title, status)
+	if status == acquisitionReceived {
+		if decision.Copies != 0 {
+			a.Copies = decision.Copies
+		}
+		book := Book{Title: a.Title, Author: a.Author, ISBN13: a.ISBN, Available_copies: a.Copies}
+		if err := checkNewBook(h, &book); err != nil {
+			var he *httpError
+			if errors.As(err, &he) {
+				writeError(w, err)
+				return
+			}
+			writeValidationError(w, r, err)
+			return
+		}
+		if _, err := stockBook(h, tx, &book); err != nil {
+			writeError(w, err)
+			return
+		}
+		a.BookID = &book.Book_id
+		message = fmt.Sprintf("%q, which you suggested, is now in the library as book %d.", a.Title, book.Book_id)
+	}
+	if decision.Note != "" {
+		message += " " + decision.Note
+	}
+	_, err = tx.Exec("UPDATE acquisitions SET status=?, note=NULLIF(?, ''), processed_by=NULLIF(?, ''), copies=?, book_id=? WHERE id=?",
+		status, decision.Note, decision.ProcessedBy, a.Copies, a.BookID, id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := notify(tx, a.UserType, a.UserID, "acquisition_"+status, message); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if a, err = loadAcquisition(tx, id); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if a.BookID != nil {
+		h.cacheDel(bookResource.cacheKey(*a.BookID))
+	}
+	writeJSON(w, http.StatusOK, a)
+}
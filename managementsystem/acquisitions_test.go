@@ -0,0 +1,135 @@
+package managementsystem_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	managementsystem "managementsystem/managementsystem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"testing"
+)
+
+func TestHybridHandler5_Acquisitions(t *testing.T) {
+
+	os.Setenv("REDIS_ADDR", "localhost:6379")
+	os.Setenv("MySQL_DSN", "root:root@tcp(127.0.0.1:3306)/management_sys")
+
+	redisInstance, err := managementsystem.ConnectRedis()
+	if err != nil {
+		panic(err)
+	}
+	mysqlinstance, err := managementsystem.ConnectMySQL()
+	if err != nil {
+		panic(err)
+	}
+	handler := &managementsystem.HybridHandler5{MySQL: mysqlinstance, Redis: redisInstance, Ctx: context.Background()}
+	router := handler.Router()
+
+	mysqlinstance.DB.Exec("DELETE FROM acquisitions")
+	mysqlinstance.DB.Exec("DELETE FROM notifications")
+	mysqlinstance.DB.Exec("DELETE FROM holds")
+	mysqlinstance.DB.Exec("DELETE FROM fines")
+	mysqlinstance.DB.Exec("DELETE FROM borrow_records")
+	mysqlinstance.DB.Exec("DELETE FROM transfers")
+	mysqlinstance.DB.Exec("DELETE FROM book_copies")
+	mysqlinstance.DB.Exec("DELETE FROM books")
+	redisInstance.Client.FlushAll(context.Background())
+	mysqlinstance.DB.Exec("INSERT IGNORE INTO students (id, name, email, age, year) VALUES (?, ?, ?, ?, ?)", 701, "reader", "reader701@gmail.com", 20, 1)
+
+	post := func(path string, v any) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(v)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, path, bytes.NewBuffer(body)))
+		return w
+	}
+	suggest := func(title, isbn string) int {
+		w := post("/acquisitions", managementsystem.Acquisition{UserID: 701, UserType: "student", Title: title, Author: "Alan Donovan", ISBN: isbn})
+		if w.Code != http.StatusCreated {
+			t.Fatalf("Expected created status , got %d: %s", w.Code, w.Body.String())
+		}
+		var a managementsystem.Acquisition
+		json.NewDecoder(w.Body).Decode(&a)
+		return a.ID
+	}
+
+	// the library has one copy of the second book already
+	w := post("/books", managementsystem.Book{Title: "Rust", Author: "Alice", ISBN13: "9780306406157", Available_copies: 1})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected created status , got %d: %s", w.Code, w.Body.String())
+	}
+	goBook := "/acquisitions/" + strconv.Itoa(suggest("The Go Programming Language", "0134190440"))
+	rustBook := "/acquisitions/" + strconv.Itoa(suggest("Rust", "0-306-40615-2"))
+	zig := "/acquisitions/" + strconv.Itoa(suggest("Zig", ""))
+
+	tests := []struct {
+		name   string // description of this test case
+		path   string
+		body   any
+		status int
+		want   string // status of the acquisition afterwards
+		copies int    // copies of the book it stocked, if any
+	}{
+		{name: "invalid isbn", path: "/acquisitions", body: managementsystem.Acquisition{UserID: 701, UserType: "student", Title: "Bad", Author: "Bob", ISBN: "123"}, status: http.StatusBadRequest},
+		{name: "unknown student", path: "/acquisitions", body: managementsystem.Acquisition{UserID: 987654, UserType: "student", Title: "Bad", Author: "Bob"}, status: http.StatusNotFound},
+		{name: "ordered before approval", path: goBook + "/order", body: managementsystem.AcquisitionDecision{}, status: http.StatusConflict},
+		{name: "approve", path: goBook + "/approve", body: managementsystem.AcquisitionDecision{ProcessedBy: "librarian"}, status: http.StatusOK, want: "approved"},
+		{name: "order", path: goBook + "/order", body: managementsystem.AcquisitionDecision{}, status: http.StatusOK, want: "ordered"},
+		{name: "receive creates the book", path: goBook + "/receive", body: managementsystem.AcquisitionDecision{Copies: 2}, status: http.StatusOK, want: "received", copies: 2},
+		{name: "received twice", path: goBook + "/receive", body: managementsystem.AcquisitionDecision{}, status: http.StatusConflict},
+		{name: "approve a book the library has", path: rustBook + "/approve", body: managementsystem.AcquisitionDecision{}, status: http.StatusOK, want: "approved"},
+		{name: "order it", path: rustBook + "/order", body: managementsystem.AcquisitionDecision{}, status: http.StatusOK, want: "ordered"},
+		{name: "receive adds copies", path: rustBook + "/receive", body: managementsystem.AcquisitionDecision{}, status: http.StatusOK, want: "received", copies: 2},
+		{name: "reject", path: zig + "/reject", body: managementsystem.AcquisitionDecision{Note: "Out of print."}, status: http.StatusOK, want: "rejected"},
+		{name: "approve a rejected suggestion", path: zig + "/approve", body: managementsystem.AcquisitionDecision{}, status: http.StatusConflict},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := post(tt.path, tt.body)
+			if w.Code != tt.status {
+				t.Fatalf("Expected status %d, got %d: %s", tt.status, w.Code, w.Body.String())
+			}
+			if tt.want == "" {
+				return
+			}
+			var a managementsystem.Acquisition
+			if err := json.NewDecoder(w.Body).Decode(&a); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if a.Status != tt.want {
+				t.Fatalf("Expected the acquisition %s, got %+v", tt.want, a)
+			}
+			if tt.copies == 0 {
+				return
+			}
+			if a.BookID == nil {
+				t.Fatalf("Expected a book, got %+v", a)
+			}
+			w = httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/books/"+strconv.Itoa(*a.BookID), nil))
+			var book managementsystem.Book
+			if err := json.NewDecoder(w.Body).Decode(&book); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if book.Available_copies != tt.copies {
+				t.Fatalf("Expected %d copies, got %d", tt.copies, book.Available_copies)
+			}
+		})
+	}
+
+	// every decision was a notification for 701
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users/student/701/notifications?unread=true", nil))
+	var notifications []managementsystem.Notification
+	if err := json.NewDecoder(w.Body).Decode(&notifications); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(notifications) != 7 {
+		t.Fatalf("Expected 7 notifications, got %+v", notifications)
+	}
+	if w := post("/notifications/"+strconv.Itoa(notifications[0].ID)+"/read", nil); w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+}
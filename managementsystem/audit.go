@@ -0,0 +1,206 @@
+package managementsystem
+
+import (
+	"log"
+	"net/http"
+	"time"
+)
+
+// Kinds of inventory discrepancy. Only the repairable ones are fixed by a
+// repair; the others need a librarian.
+const (
+	driftBorrowedWithoutLoan = "copy_borrowed_without_loan" // repairable: the copy is made available
+	driftLoanCopyNotBorrowed = "loan_copy_not_borrowed"     // repairable: the copy is marked borrowed
+	driftHeldWithoutHold     = "copy_held_without_hold"     // repairable: the copy is made available
+	driftLoanWithoutCopy     = "loan_without_copy"
+	driftCopyOnSeveralLoans  = "copy_on_several_loans"
+)
+
+type Discrepancy struct {
+	Kind      string `json:"kind"`
+	Copy_id   *int   `json:"copy_id,omitempty"`
+	Borrow_id *int   `json:"borrow_id,omitempty"`
+	Detail    string `json:"detail"`
+	Repaired  bool   `json:"repaired"`
+}
+
+// BookAudit is the inventory of one book and what does not add up in it.
+type BookAudit struct {
+	Book_id       int           `json:"book_id"`
+	Title         string        `json:"title"`
+	Copies        int           `json:"copies"`
+	Available     int           `json:"available"`
+	OnLoan        int           `json:"on_loan"`
+	OpenLoans     int           `json:"open_loans"`
+	Discrepancies []Discrepancy `json:"discrepancies"`
+}
+
+type AuditReport struct {
+	GeneratedAt   string      `json:"generated_at"`
+	BooksChecked  int         `json:"books_checked"`
+	Discrepancies int         `json:"discrepancies"`
+	Books         []BookAudit `json:"books"` // only books with discrepancies
+}
+
+// driftQueries find each kind of discrepancy as (book_id, copy_id,
+// borrow_id, detail) rows.
+var driftQueries = []struct {
+	kind  string
+	query string
+}{
+	{driftBorrowedWithoutLoan, `SELECT c.book_id, c.copy_id, NULL, CONCAT('copy ', c.barcode, ' is borrowed but on no open loan')
+		FROM book_copies c
+		WHERE c.status = 'borrowed' AND NOT EXISTS (SELECT 1 FROM borrow_records r WHERE r.copy_id = c.copy_id AND r.return_date IS NULL)`},
+	{driftLoanCopyNotBorrowed, `SELECT r.book_id, c.copy_id, r.borrow_id, CONCAT('copy ', c.barcode, ' is on loan ', r.borrow_id, ' but ', c.status)
+		FROM borrow_records r JOIN book_copies c ON c.copy_id = r.copy_id
+		WHERE r.return_date IS NULL AND c.status <> 'borrowed'`},
+	{driftHeldWithoutHold, `SELECT c.book_id, c.copy_id, NULL, CONCAT('copy ', c.barcode, ' is on hold for nobody')
+		FROM book_copies c
+		WHERE c.status = 'on_hold' AND NOT EXISTS (SELECT 1 FROM holds h WHERE h.copy_id = c.copy_id AND h.status = 'ready')`},
+	{driftLoanWithoutCopy, `SELECT r.book_id, NULL, r.borrow_id, CONCAT('loan ', r.borrow_id, ' has no copy')
+		FROM borrow_records r
+		WHERE r.return_date IS NULL AND r.copy_id IS NULL`},
+	{driftCopyOnSeveralLoans, `SELECT c.book_id, c.copy_id, NULL, CONCAT('copy ', c.barcode, ' is on ', COUNT(*), ' open loans')
+		FROM borrow_records r JOIN book_copies c ON c.copy_id = r.copy_id
+		WHERE r.return_date IS NULL
+		GROUP BY c.copy_id, c.book_id, c.barcode HAVING COUNT(*) > 1`},
+}
+
+// audit reports the inventory discrepancies of every book. With repair set
+// it also fixes the repairable ones, so q should then be a transaction.
+func audit(q querier, repair bool) (*AuditReport, error) {
+	report := AuditReport{GeneratedAt: time.Now().Format(time.DateTime), Books: []BookAudit{}}
+	found := map[int][]Discrepancy{}
+	for _, drift := range driftQueries {
+		rows, err := q.Query(drift.query)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var bookID int
+			d := Discrepancy{Kind: drift.kind}
+			if err := rows.Scan(&bookID, &d.Copy_id, &d.Borrow_id, &d.Detail); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			found[bookID] = append(found[bookID], d)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	rows, err := q.Query(`SELECT b.book_id, b.title,
+		(SELECT COUNT(*) FROM book_copies c WHERE c.book_id = b.book_id AND c.status NOT IN ('retired', 'lost')),
+		(SELECT COUNT(*) FROM book_copies c WHERE c.book_id = b.book_id AND c.status = 'available'),
+		(SELECT COUNT(*) FROM book_copies c WHERE c.book_id = b.book_id AND c.status = 'borrowed'),
+		(SELECT COUNT(*) FROM borrow_records r WHERE r.book_id = b.book_id AND r.return_date IS NULL)
+		FROM books b ORDER BY b.book_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var book BookAudit
+		if err := rows.Scan(&book.Book_id, &book.Title, &book.Copies, &book.Available, &book.OnLoan, &book.OpenLoans); err != nil {
+			return nil, err
+		}
+		report.BooksChecked++
+		if book.Discrepancies = found[book.Book_id]; book.Discrepancies != nil {
+			report.Discrepancies += len(book.Discrepancies)
+			report.Books = append(report.Books, book)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if repair {
+		for i := range report.Books {
+			if err := repairBook(q, &report.Books[i]); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return &report, nil
+}
+
+// repairBook fixes the repairable discrepancies of a book and hands any
+// copy made available to its hold queue.
+func repairBook(q querier, book *BookAudit) error {
+	for i := range book.Discrepancies {
+		d := &book.Discrepancies[i]
+		var status string
+		switch d.Kind {
+		case driftBorrowedWithoutLoan, driftHeldWithoutHold:
+			status = copyAvailable
+		case driftLoanCopyNotBorrowed:
+			status = copyBorrowed
+		default:
+			continue
+		}
+		if _, err := q.Exec("UPDATE book_copies SET status=? WHERE copy_id=?", status, *d.Copy_id); err != nil {
+			return err
+		}
+		d.Repaired = true
+	}
+	return refreshHolds(q, book.Book_id)
+}
+
+// Library inventory audit
+func (h *HybridHandler5) AuditHandler(w http.ResponseWriter, r *http.Request) {
+	report, err := audit(h.MySQL.DB, false)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, report)
+}
+
+// Repair library inventory
+func (h *HybridHandler5) RepairAuditHandler(w http.ResponseWriter, r *http.Request) {
+	tx, err := h.MySQL.DB.Begin()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	report, err := audit(tx, true)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	for _, book := range report.Books {
+		h.cacheDel(bookResource.cacheKey(book.Book_id))
+	}
+	writeJSON(w, http.StatusOK, report)
+}
+
+// AuditJob logs the inventory discrepancies found every interval until the
+// handler's context is done.
+func (h *HybridHandler5) AuditJob(every time.Duration) {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-h.Ctx.Done():
+			return
+		case <-ticker.C:
+			report, err := audit(h.MySQL.DB, false)
+			if err != nil {
+				log.Println("library audit:", err)
+				continue
+			}
+			for _, book := range report.Books {
+				for _, d := range book.Discrepancies {
+					log.Printf("library audit: book %d: %s: %s", book.Book_id, d.Kind, d.Detail)
+				}
+			}
+		}
+	}
+}
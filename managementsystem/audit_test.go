@@ -0,0 +1,187 @@
+package managementsystem_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	managementsystem "managementsystem/managementsystem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"testing"
+)
+
+func TestHybridHandler5_Audit(t *testing.T) {
+
+	os.Setenv("REDIS_ADDR", "localhost:6379")
+	os.Setenv("MySQL_DSN", "root:root@tcp(127.0.0.1:3306)/management_sys")
+
+	redisInstance, err := managementsystem.ConnectRedis()
+	if err != nil {
+		panic(err)
+	}
+	mysqlinstance, err := managementsystem.ConnectMySQL()
+	if err != nil {
+		panic(err)
+	}
+	handler := &managementsystem.HybridHandler5{MySQL: mysqlinstance, Redis: redisInstance, Ctx: context.Background()}
+	router := handler.Router()
+
+	mysqlinstance.DB.Exec("DELETE FROM holds")
+	mysqlinstance.DB.Exec("DELETE FROM fines")
+	mysqlinstance.DB.Exec("DELETE FROM borrow_records")
+	mysqlinstance.DB.Exec("DELETE FROM book_copies")
+	mysqlinstance.DB.Exec("DELETE FROM books")
+	redisInstance.Client.FlushAll(context.Background())
+
+	// a copy marked borrowed with no loan, and a loan whose copy is on the shelf
+	res, err := mysqlinstance.DB.Exec("INSERT INTO books(title, author) VALUES (?, ?)", "GoLang", "Alice")
+	if err != nil {
+		t.Fatalf("insert book fail: %v", err)
+	}
+	book_id, _ := res.LastInsertId()
+	mysqlinstance.DB.Exec("INSERT INTO book_copies(book_id, branch_id, barcode, status) VALUES (?, (SELECT id FROM branches WHERE code='MAIN'), ?, ?)", book_id, "AUDIT-1", "borrowed")
+	res, _ = mysqlinstance.DB.Exec("INSERT INTO book_copies(book_id, branch_id, barcode, status) VALUES (?, (SELECT id FROM branches WHERE code='MAIN'), ?, ?)", book_id, "AUDIT-2", "available")
+	copy_id, _ := res.LastInsertId()
+	mysqlinstance.DB.Exec("INSERT INTO borrow_records(user_id, user_type, book_id, copy_id, borrow_date) VALUES (?, ?, ?, ?, CURDATE())", 101, "student", book_id, copy_id)
+
+	tests := []struct {
+		name     string // description of this test case
+		method   string
+		path     string
+		found    int  // discrepancies reported
+		repaired bool // whether they are reported repaired
+	}{
+		{name: "audit", method: http.MethodGet, path: "/library/audit", found: 2},
+		{name: "audit again changes nothing", method: http.MethodGet, path: "/library/audit", found: 2},
+		{name: "repair", method: http.MethodPost, path: "/library/audit/repair", found: 2, repaired: true},
+		{name: "nothing left", method: http.MethodGet, path: "/library/audit", found: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+			if w.Code != http.StatusOK {
+				t.Fatalf("Expected status OK, got %d: %s", w.Code, w.Body.String())
+			}
+			var report managementsystem.AuditReport
+			if err := json.NewDecoder(w.Body).Decode(&report); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if report.Discrepancies != tt.found {
+				t.Fatalf("Expected %d discrepancies, got %+v", tt.found, report)
+			}
+			for _, book := range report.Books {
+				for _, d := range book.Discrepancies {
+					if d.Repaired != tt.repaired {
+						t.Fatalf("Expected repaired %v, got %+v", tt.repaired, d)
+					}
+				}
+			}
+		})
+	}
+
+	var status string
+	mysqlinstance.DB.QueryRow("SELECT status FROM book_copies WHERE barcode=?", "AUDIT-1").Scan(&status)
+	if status != "available" {
+		t.Fatalf("Expected the copy without a loan to be available, got %s", status)
+	}
+}
+
+func TestHybridHandler5_Stocktake(t *testing.T) {
+
+	os.Setenv("REDIS_ADDR", "localhost:6379")
+	os.Setenv("MySQL_DSN", "root:root@tcp(127.0.0.1:3306)/management_sys")
+
+	redisInstance, err := managementsystem.ConnectRedis()
+	if err != nil {
+		panic(err)
+	}
+	mysqlinstance, err := managementsystem.ConnectMySQL()
+	if err != nil {
+		panic(err)
+	}
+	handler := &managementsystem.HybridHandler5{MySQL: mysqlinstance, Redis: redisInstance, Ctx: context.Background()}
+	router := handler.Router()
+
+	mysqlinstance.DB.Exec("DELETE FROM holds")
+	mysqlinstance.DB.Exec("DELETE FROM fines")
+	mysqlinstance.DB.Exec("DELETE FROM borrow_records")
+	mysqlinstance.DB.Exec("DELETE FROM book_copies")
+	mysqlinstance.DB.Exec("DELETE FROM books")
+	redisInstance.Client.FlushAll(context.Background())
+
+	// three copies on the shelf and one on loan
+	res, err := mysqlinstance.DB.Exec("INSERT INTO books(title, author) VALUES (?, ?)", "GoLang", "Alice")
+	if err != nil {
+		t.Fatalf("insert book fail: %v", err)
+	}
+	book_id, _ := res.LastInsertId()
+	for i, status := range []string{"available", "available", "available", "borrowed"} {
+		mysqlinstance.DB.Exec("INSERT INTO book_copies(book_id, branch_id, barcode, shelf_location, status) VALUES (?, (SELECT id FROM branches WHERE code='MAIN'), ?, ?, ?)", book_id, "STOCK-"+strconv.Itoa(i+1), "A1", status)
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/stocktakes", bytes.NewBufferString(`{"location":"A","started_by":"desk 1"}`)))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected created status , got %d: %s", w.Code, w.Body.String())
+	}
+	var stocktake managementsystem.Stocktake
+	if err := json.NewDecoder(w.Body).Decode(&stocktake); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	path := "/stocktakes/" + strconv.Itoa(stocktake.ID)
+
+	tests := []struct {
+		name    string // description of this test case
+		body    string
+		status  int
+		counted int
+		unknown int
+	}{
+		{name: "scan", body: `{"barcodes":["STOCK-1","STOCK-2"]}`, status: http.StatusOK, counted: 2},
+		{name: "scan again with unknown and loaned copies", body: `{"barcodes":["STOCK-2","STOCK-4","NOPE"]}`, status: http.StatusOK, counted: 1, unknown: 1},
+		{name: "no barcodes", body: `{"barcodes":[]}`, status: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, path+"/scans", bytes.NewBufferString(tt.body)))
+			if w.Code != tt.status {
+				t.Fatalf("Expected status %d, got %d: %s", tt.status, w.Code, w.Body.String())
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+			var result managementsystem.ScanResult
+			if err := json.NewDecoder(w.Body).Decode(&result); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if result.Counted != tt.counted || len(result.Unknown) != tt.unknown {
+				t.Fatalf("Expected %d counted and %d unknown, got %+v", tt.counted, tt.unknown, result)
+			}
+		})
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path+"/report", nil))
+	var report managementsystem.StocktakeReport
+	if err := json.NewDecoder(w.Body).Decode(&report); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if report.Expected != 3 || len(report.Missing) != 1 || report.Missing[0].Barcode != "STOCK-3" || len(report.Unexpected) != 1 || report.Unexpected[0].Barcode != "STOCK-4" {
+		t.Fatalf("Expected STOCK-3 missing and STOCK-4 unexpected, got %+v", report)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, path+"/close", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status OK, got %d: %s", w.Code, w.Body.String())
+	}
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, path+"/scans", bytes.NewBufferString(`{"barcodes":["STOCK-3"]}`)))
+	if w.Code != http.StatusConflict {
+		t.Fatalf("Expected scans into a closed stock-take to conflict, got %d", w.Code)
+	}
+}
@@ -0,0 +1,387 @@
+package managementsystem
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"managementsystem/validation"
+)
+
+type Author struct {
+	ID   int    `json:"id"`
+	Name string `json:"name" validate:"trimmed,required,max=100"`
+}
+
+type Category struct {
+	ID   int    `json:"id"`
+	Name string `json:"name" validate:"trimmed,required,max=50"`
+}
+
+// validation
+func ValidateAuthor(author Author) error {
+	return validation.Struct(author)
+}
+
+func ValidateCategory(category Category) error {
+	return validation.Struct(category)
+}
+
+var authorResource = &Resource[Author]{
+	Name:        "author",
+	Path:        "/authors",
+	Table:       "authors",
+	Key:         "id",
+	Columns:     []string{"name"},
+	CachePrefix: "author:",
+	CacheTTL:    10 * time.Minute,
+	Fields: func(a *Author) []any {
+		return []any{&a.ID, &a.Name}
+	},
+	Validate: func(a *Author) error { return ValidateAuthor(*a) },
+	Prepare: func(h *HybridHandler5, a *Author) error {
+		a.Name = strings.TrimSpace(a.Name)
+		return nil
+	},
+	AfterSave: func(h *HybridHandler5, tx querier, a *Author) error {
+		return syncAuthorNames(tx, a.ID)
+	},
+	AfterCommit: func(h *HybridHandler5, a *Author) {
+		if err := h.dropBookCache(h.MySQL.DB, "SELECT book_id FROM book_authors WHERE author_id=?", a.ID); err != nil {
+			log.Println("book cache:", err)
+		}
+	},
+}
+
+var categoryResource = &Resource[Category]{
+	Name:        "category",
+	Path:        "/categories",
+	Table:       "categories",
+	Key:         "id",
+	Columns:     []string{"name"},
+	CachePrefix: "category:",
+	CacheTTL:    10 * time.Minute,
+	Fields: func(c *Category) []any {
+		return []any{&c.ID, &c.Name}
+	},
+	Validate: func(c *Category) error { return ValidateCategory(*c) },
+	Prepare: func(h *HybridHandler5, c *Category) error {
+		c.Name = strings.TrimSpace(c.Name)
+		return nil
+	},
+	AfterCommit: func(h *HybridHandler5, c *Category) {
+		if err := h.dropBookCache(h.MySQL.DB, "SELECT book_id FROM book_categories WHERE category_id=?", c.ID); err != nil {
+			log.Println("book cache:", err)
+		}
+	},
+}
+
+// authorNames joins the names of authors the way the author string of a
+// book has them.
+func authorNames(authors []Author) string {
+	names := make([]string, len(authors))
+	for i, author := range authors {
+		names[i] = author.Name
+	}
+	return strings.Join(names, ", ")
+}
+
+// expandBook reads the authors, categories and copies per branch of a book.
+func expandBook(q querier, b *Book) error {
+	var err error
+	if b.Authors, err = bookAuthors(q, b.Book_id); err != nil {
+		return err
+	}
+	if b.Categories, err = bookCategories(q, b.Book_id); err != nil {
+		return err
+	}
+	b.Branches, err = bookBranches(q, b.Book_id)
+	return err
+}
+
+func bookAuthors(q querier, bookID int) ([]Author, error) {
+	rows, err := q.Query("SELECT a.id, a.name FROM book_authors ba JOIN authors a ON a.id = ba.author_id WHERE ba.book_id=? ORDER BY ba.position", bookID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	authors := []Author{}
+	for rows.Next() {
+		var author Author
+		if err := rows.Scan(&author.ID, &author.Name); err != nil {
+			return nil, err
+		}
+		authors = append(authors, author)
+	}
+	return authors, rows.Err()
+}
+
+func bookCategories(q querier, bookID int) ([]Category, error) {
+	rows, err := q.Query("SELECT c.id, c.name FROM book_categories bc JOIN categories c ON c.id = bc.category_id WHERE bc.book_id=? ORDER BY c.name", bookID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	categories := []Category{}
+	for rows.Next() {
+		var category Category
+		if err := rows.Scan(&category.ID, &category.Name); err != nil {
+			return nil, err
+		}
+		categories = append(categories, category)
+	}
+	return categories, rows.Err()
+}
+
+// resolveBookLinks settles the authors of a book from whichever of the
+// authors list and the author string the request changed, and looks up the
+// authors and categories it names by id or by name. Authors not known yet
+// are created by linkBook; categories must exist. On an update, a request
+// without categories keeps the ones the book has.
+func resolveBookLinks(h *HybridHandler5, b *Book) error {
+	var stored string
+	if b.Book_id > 0 {
+		err := h.MySQL.DB.QueryRow("SELECT author FROM books WHERE book_id=?", b.Book_id).Scan(&stored)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+	}
+	for i := range b.Authors {
+		if err := resolveAuthor(h.MySQL.DB, &b.Authors[i]); err != nil {
+			return err
+		}
+	}
+	author := strings.TrimSpace(b.Author)
+	if len(b.Authors) == 0 || authorNames(b.Authors) == stored {
+		// the authors list is absent or as stored, so the author string says
+		// what the request wants
+		switch {
+		case author != "" && author == stored:
+			authors, err := bookAuthors(h.MySQL.DB, b.Book_id)
+			if err != nil {
+				return err
+			}
+			if len(authors) > 0 {
+				b.Authors = authors
+				break
+			}
+			fallthrough
+		case author != "":
+			b.Authors = []Author{{Name: author}}
+			if err := resolveAuthor(h.MySQL.DB, &b.Authors[0]); err != nil {
+				return err
+			}
+		}
+	}
+	b.Authors = uniqueAuthors(b.Authors)
+	b.Author = authorNames(b.Authors)
+
+	if b.Categories == nil && b.Book_id > 0 {
+		var err error
+		if b.Categories, err = bookCategories(h.MySQL.DB, b.Book_id); err != nil {
+			return err
+		}
+	}
+	seen := map[int]bool{}
+	categories := []Category{}
+	for _, category := range b.Categories {
+		if err := resolveCategory(h.MySQL.DB, &category); err != nil {
+			return err
+		}
+		if !seen[category.ID] {
+			seen[category.ID] = true
+			categories = append(categories, category)
+		}
+	}
+	b.Categories = categories
+	return nil
+}
+
+// resolveAuthor fills in the name of an author given by id, or the id of
+// one given by name, leaving it 0 for a new author.
+func resolveAuthor(q querier, author *Author) error {
+	var err error
+	if author.ID > 0 {
+		err = q.QueryRow("SELECT name FROM authors WHERE id=?", author.ID).Scan(&author.Name)
+		if errors.Is(err, sql.ErrNoRows) {
+			return newHTTPError(http.StatusBadRequest, "unknown author %d", author.ID)
+		}
+		return err
+	}
+	author.Name = strings.TrimSpace(author.Name)
+	err = q.QueryRow("SELECT id, name FROM authors WHERE name=?", author.Name).Scan(&author.ID, &author.Name)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	return err
+}
+
+// resolveCategory looks up a category by id or else by name.
+func resolveCategory(q querier, category *Category) error {
+	var row *sql.Row
+	if category.ID > 0 {
+		row = q.QueryRow("SELECT id, name FROM categories WHERE id=?", category.ID)
+	} else {
+		row = q.QueryRow("SELECT id, name FROM categories WHERE name=?", strings.TrimSpace(category.Name))
+	}
+	if err := row.Scan(&category.ID, &category.Name); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return newHTTPError(http.StatusBadRequest, "unknown category %s", categoryLabel(*category))
+		}
+		return err
+	}
+	return nil
+}
+
+func categoryLabel(category Category) string {
+	if category.ID > 0 {
+		return strconv.Itoa(category.ID)
+	}
+	return category.Name
+}
+
+// uniqueAuthors drops repeated authors, keeping the first of each.
+func uniqueAuthors(authors []Author) []Author {
+	unique := []Author{}
+	seen := map[string]bool{}
+	for _, author := range authors {
+		key := strings.ToLower(author.Name)
+		if !seen[key] {
+			seen[key] = true
+			unique = append(unique, author)
+		}
+	}
+	return unique
+}
+
+// linkBook creates the new authors of a book and replaces its author and
+// category links with the ones resolveBookLinks settled.
+func linkBook(tx querier, b *Book) error {
+	if _, err := tx.Exec("DELETE FROM book_authors WHERE book_id=?", b.Book_id); err != nil {
+		return err
+	}
+	for i := range b.Authors {
+		author := &b.Authors[i]
+		if author.ID == 0 {
+			res, err := tx.Exec("INSERT INTO authors (name) VALUES (?) ON DUPLICATE KEY UPDATE id=LAST_INSERT_ID(id)", author.Name)
+			if err != nil {
+				return err
+			}
+			id, err := res.LastInsertId()
+			if err != nil {
+				return err
+			}
+			author.ID = int(id)
+		}
+		if _, err := tx.Exec("INSERT INTO book_authors (book_id, author_id, position) VALUES (?, ?, ?)", b.Book_id, author.ID, i); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec("DELETE FROM book_categories WHERE book_id=?", b.Book_id); err != nil {
+		return err
+	}
+	for _, category := range b.Categories {
+		if _, err := tx.Exec("INSERT INTO book_categories (book_id, category_id) VALUES (?, ?)", b.Book_id, category.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// syncAuthorNames rewrites the author string of the books of a renamed
+// author. Their cached copies are dropped once this is committed.
+func syncAuthorNames(q querier, authorID int) error {
+	_, err := q.Exec(`UPDATE books b SET b.author = (
+		SELECT GROUP_CONCAT(a.name ORDER BY ba.position SEPARATOR ', ')
+		FROM book_authors ba JOIN authors a ON a.id = ba.author_id WHERE ba.book_id = b.book_id)
+		WHERE b.book_id IN (SELECT book_id FROM book_authors WHERE author_id=?)`, authorID)
+	return err
+}
+
+// dropBookCache drops the cached copies of the books query selects. It runs
+// after the change they are dropped for is committed, so a read in between
+// cannot cache the old book again.
+func (h *HybridHandler5) dropBookCache(q querier, query string, args ...any) error {
+	rows, err := q.Query(query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return err
+		}
+		h.cacheDel(bookResource.cacheKey(id))
+	}
+	return rows.Err()
+}
+
+// Books of an author
+func (h *HybridHandler5) AuthorBooksHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := authorResource.id(w, r)
+	if !ok {
+		return
+	}
+	if _, err := authorResource.load(h, id); err != nil {
+		authorResource.writeLoadError(w, err)
+		return
+	}
+	h.listBooks(w, r, "book_id IN (SELECT book_id FROM book_authors WHERE author_id=?)", id)
+}
+
+// Books in a category
+func (h *HybridHandler5) CategoryBooksHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := categoryResource.id(w, r)
+	if !ok {
+		return
+	}
+	if _, err := categoryResource.load(h, id); err != nil {
+		categoryResource.writeLoadError(w, err)
+		return
+	}
+	h.listBooks(w, r, "book_id IN (SELECT book_id FROM book_categories WHERE category_id=?)", id)
+}
+
+// listBooks writes the books matching where, by title and paginated.
+func (h *HybridHandler5) listBooks(w http.ResponseWriter, r *http.Request, where string, id int) {
+	limit, offset, err := pagination(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	rows, err := h.MySQL.DB.Query(bookResource.selectSQL()+" WHERE "+where+" ORDER BY title, book_id LIMIT ? OFFSET ?", id, limit, offset)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	books := []Book{}
+	for rows.Next() {
+		var book Book
+		if err := rows.Scan(bookResource.Fields(&book)...); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		books = append(books, book)
+	}
+	if err := rows.Err(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	for i := range books {
+		if err := expandBook(h.MySQL.DB, &books[i]); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	writeJSON(w, http.StatusOK, books)
+}
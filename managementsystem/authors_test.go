@@ -0,0 +1,160 @@
+package managementsystem_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	managementsystem "managementsystem/managementsystem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"testing"
+)
+
+func TestHybridHandler5_Authors(t *testing.T) {
+
+	os.Setenv("REDIS_ADDR", "localhost:6379")
+	os.Setenv("MySQL_DSN", "root:root@tcp(127.0.0.1:3306)/management_sys")
+
+	redisInstance, err := managementsystem.ConnectRedis()
+	if err != nil {
+		panic(err)
+	}
+	mysqlinstance, err := managementsystem.ConnectMySQL()
+	if err != nil {
+		panic(err)
+	}
+	handler := &managementsystem.HybridHandler5{MySQL: mysqlinstance, Redis: redisInstance, Ctx: context.Background()}
+	router := handler.Router()
+
+	mysqlinstance.DB.Exec("DELETE FROM holds")
+	mysqlinstance.DB.Exec("DELETE FROM fines")
+	mysqlinstance.DB.Exec("DELETE FROM borrow_records")
+	mysqlinstance.DB.Exec("DELETE FROM book_copies")
+	mysqlinstance.DB.Exec("DELETE FROM books")
+	mysqlinstance.DB.Exec("DELETE FROM authors")
+	mysqlinstance.DB.Exec("DELETE FROM categories")
+	redisInstance.Client.FlushAll(context.Background())
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/categories", bytes.NewBufferString(`{"name":"Programming"}`)))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected created status , got %d: %s", w.Code, w.Body.String())
+	}
+	var category managementsystem.Category
+	if err := json.NewDecoder(w.Body).Decode(&category); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+
+	tests := []struct {
+		name       string // description of this test case
+		method     string
+		path       string // book path, relative to /books
+		body       string
+		status     int
+		author     string // author string of the book afterwards
+		categories int
+	}{
+		{
+			name:       "co-authored book",
+			method:     http.MethodPost,
+			body:       `{"title":"The Go Programming Language","authors":[{"name":"Alan Donovan"},{"name":"Brian Kernighan"}],"categories":[{"name":"Programming"}],"available_copies":1}`,
+			status:     http.StatusCreated,
+			author:     "Alan Donovan, Brian Kernighan",
+			categories: 1,
+		},
+		{
+			name:       "another book by an author, the old way",
+			method:     http.MethodPost,
+			body:       `{"title":"The C Programming Language","author":"Brian Kernighan","available_copies":1}`,
+			status:     http.StatusCreated,
+			author:     "Brian Kernighan",
+			categories: 0,
+		},
+		{
+			name:   "unknown category",
+			method: http.MethodPost,
+			body:   `{"title":"Rust","author":"Alice","categories":[{"name":"Cooking"}],"available_copies":1}`,
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "no author at all",
+			method: http.MethodPost,
+			body:   `{"title":"Rust","available_copies":1}`,
+			status: http.StatusBadRequest,
+		},
+	}
+	var books []managementsystem.Book
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(tt.method, "/books"+tt.path, bytes.NewBufferString(tt.body)))
+			if w.Code != tt.status {
+				t.Fatalf("Expected status %d, got %d: %s", tt.status, w.Code, w.Body.String())
+			}
+			if tt.status >= 300 {
+				return
+			}
+			var book managementsystem.Book
+			if err := json.NewDecoder(w.Body).Decode(&book); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if book.Author != tt.author || len(book.Categories) != tt.categories {
+				t.Fatalf("Expected author %q and %d categories, got %+v", tt.author, tt.categories, book)
+			}
+			books = append(books, book)
+		})
+	}
+	if len(books) != 2 {
+		t.Fatalf("Expected two books, got %d", len(books))
+	}
+
+	// both books show up under their shared author
+	kernighan := books[0].Authors[1]
+	if kernighan.ID != books[1].Authors[0].ID {
+		t.Fatalf("Expected one author for both books, got %+v and %+v", books[0].Authors, books[1].Authors)
+	}
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/authors/"+strconv.Itoa(kernighan.ID)+"/books", nil))
+	var byAuthor []managementsystem.Book
+	if err := json.NewDecoder(w.Body).Decode(&byAuthor); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(byAuthor) != 2 {
+		t.Fatalf("Expected two books by the author, got %d", len(byAuthor))
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/categories/"+strconv.Itoa(category.ID)+"/books", nil))
+	var inCategory []managementsystem.Book
+	if err := json.NewDecoder(w.Body).Decode(&inCategory); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(inCategory) != 1 || inCategory[0].Book_id != books[0].Book_id {
+		t.Fatalf("Expected the co-authored book in the category, got %+v", inCategory)
+	}
+
+	// renaming an author rewrites the author string of the books
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPatch, "/authors/"+strconv.Itoa(kernighan.ID), bytes.NewBufferString(`{"name":"Brian W. Kernighan"}`)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status OK, got %d: %s", w.Code, w.Body.String())
+	}
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/books/"+strconv.Itoa(books[0].Book_id), nil))
+	var renamed managementsystem.Book
+	if err := json.NewDecoder(w.Body).Decode(&renamed); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if renamed.Author != "Alan Donovan, Brian W. Kernighan" || len(renamed.Categories) != 1 {
+		t.Fatalf("Expected the renamed author and the category kept, got %+v", renamed)
+	}
+
+	// an author with books cannot be deleted
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/authors/"+strconv.Itoa(kernighan.ID), nil))
+	if w.Code != http.StatusConflict {
+		t.Fatalf("Expected conflict status, got %d: %s", w.Code, w.Body.String())
+	}
+}
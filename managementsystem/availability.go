@@ -0,0 +1,165 @@
+package managementsystem
+
+import (
+	"net/http"
+	"slices"
+	"time"
+)
+
+// Availability forecasts when a new borrower could get a copy of a book.
+// Copies on course reserve only go out on short loans and are left out.
+type Availability struct {
+	BookID          int              `json:"book_id"`
+	Copies          int              `json:"copies"` // copies that circulate, lent or not
+	Available       int              `json:"available"`
+	OnReserve       int              `json:"on_reserve"`
+	Holds           int              `json:"holds"`       // waiting in the queue
+	ReadyHolds      int              `json:"ready_holds"` // copies set aside for pickup
+	ExpectedReturns []ExpectedReturn `json:"expected_returns"`
+	// EstimatedDate is the earliest day a new borrower could get a copy,
+	// after everyone queued ahead of them had theirs for a full loan
+	// period, or nil if no copy circulates.
+	EstimatedDate *string `json:"estimated_date"`
+}
+
+// ExpectedReturn is the due date of an outstanding loan.
+type ExpectedReturn struct {
+	DueDate string `json:"due_date"`
+	Overdue bool   `json:"overdue"`
+}
+
+// EstimateAvailableDate returns the first day a copy is free for a new
+// borrower when copies become free on the days in free and each borrower
+// queued ahead of them keeps the next free copy for their loan days in
+// queue, until the due date the calendar gives the loan. It returns false
+// when there is no copy at all.
+func EstimateAvailableDate(calendar Calendar, free []time.Time, queue []int) (time.Time, bool) {
+	if len(free) == 0 {
+		return time.Time{}, false
+	}
+	free = slices.Clone(free)
+	slices.SortFunc(free, time.Time.Compare)
+	for _, loanDays := range queue {
+		next := calendar.DueDate(free[0], loanDays)
+		i, _ := slices.BinarySearchFunc(free[1:], next, time.Time.Compare)
+		free = slices.Insert(free[1:], i, next)
+	}
+	return free[0], true
+}
+
+// Availability forecast of a book
+func (h *HybridHandler5) BookAvailabilityHandler(w http.ResponseWriter, r *http.Request) {
+	bookID, ok := bookResource.id(w, r)
+	if !ok {
+		return
+	}
+	if _, err := bookResource.load(h, bookID); err != nil {
+		bookResource.writeLoadError(w, err)
+		return
+	}
+	a := Availability{BookID: bookID, ExpectedReturns: []ExpectedReturn{}}
+	var inTransit int
+	err := h.MySQL.DB.QueryRow(`SELECT
+		IFNULL(SUM(reserve_id IS NULL AND status IN (?, ?, ?, ?)), 0),
+		IFNULL(SUM(reserve_id IS NULL AND status=?), 0),
+		IFNULL(SUM(reserve_id IS NULL AND status=?), 0),
+		IFNULL(SUM(reserve_id IS NOT NULL), 0)
+		FROM book_copies WHERE book_id=?`,
+		copyAvailable, copyBorrowed, copyOnHold, copyInTransit, copyAvailable, copyInTransit, bookID).Scan(&a.Copies, &a.Available, &inTransit, &a.OnReserve)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	calendar, err := loadCalendar(h.MySQL.DB)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	today := calendar.NextOpen(calendar.Today)
+	var free []time.Time
+	// copies on the shelf or on their way to it are free the day the
+	// library next opens
+	for range a.Available + inTransit {
+		free = append(free, today)
+	}
+	rows, err := h.MySQL.DB.Query(`SELECT DATE_FORMAT(r.due_date, '%Y-%m-%d'), r.due_date < CURDATE()
+		FROM borrow_records r JOIN book_copies c ON c.copy_id = r.copy_id
+		WHERE r.book_id=? AND r.return_date IS NULL AND c.status=? AND c.reserve_id IS NULL
+		ORDER BY r.due_date, r.borrow_id`, bookID, copyBorrowed)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var ret ExpectedReturn
+		if err := rows.Scan(&ret.DueDate, &ret.Overdue); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		a.ExpectedReturns = append(a.ExpectedReturns, ret)
+		due, err := time.Parse(time.DateOnly, ret.DueDate)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		// an overdue book may come back any day
+		if ret.Overdue {
+			due = today
+		}
+		free = append(free, due)
+	}
+	if err := rows.Err(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// a ready copy goes out to its holder by the time the hold expires,
+	// waiting holds take the copies as they come back
+	rows, err = h.MySQL.DB.Query("SELECT status, user_type, DATE_FORMAT(GREATEST(IFNULL(expires_at, NOW()), NOW()), '%Y-%m-%d') FROM holds WHERE book_id=? AND status IN (?, ?) ORDER BY id", bookID, holdReady, holdWaiting)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+	policies := map[string]LoanPolicy{}
+	var queue []int
+	for rows.Next() {
+		var status, userType, pickup string
+		if err := rows.Scan(&status, &userType, &pickup); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		policy, ok := policies[userType]
+		if !ok {
+			if policy, err = loanPolicy(h.MySQL.DB, userType); err != nil {
+				writeError(w, err)
+				return
+			}
+			policies[userType] = policy
+		}
+		if status == holdWaiting {
+			a.Holds++
+			queue = append(queue, policy.LoanDays)
+			continue
+		}
+		a.ReadyHolds++
+		day, err := time.Parse(time.DateOnly, pickup)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		free = append(free, calendar.DueDate(day, policy.LoanDays))
+	}
+	if err := rows.Err(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if day, ok := EstimateAvailableDate(*calendar, free, queue); ok {
+		estimate := day.Format(time.DateOnly)
+		a.EstimatedDate = &estimate
+	}
+	writeJSON(w, http.StatusOK, a)
+}
@@ -0,0 +1,155 @@
+package managementsystem_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	managementsystem "managementsystem/managementsystem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestEstimateAvailableDate(t *testing.T) {
+	day := func(s string) time.Time {
+		d, _ := time.Parse(time.DateOnly, s)
+		return d
+	}
+	// closed at weekends
+	weekends := managementsystem.Calendar{ClosedWeekdays: map[time.Weekday]bool{time.Saturday: true, time.Sunday: true}}
+	tests := []struct {
+		name     string // description of this test case
+		calendar managementsystem.Calendar
+		free     []string
+		queue    []int
+		want     string // empty when no copy ever is free
+	}{
+		{name: "no copies", want: ""},
+		{name: "on the shelf", free: []string{"2026-01-01"}, want: "2026-01-01"},
+		{name: "earliest return", free: []string{"2026-01-10", "2026-01-05"}, want: "2026-01-05"},
+		{name: "behind one hold", free: []string{"2026-01-10", "2026-01-05"}, queue: []int{14}, want: "2026-01-10"},
+		{name: "behind the whole round", free: []string{"2026-01-10", "2026-01-05"}, queue: []int{14, 14}, want: "2026-01-19"},
+		{name: "loan periods differ", free: []string{"2026-01-01"}, queue: []int{30, 14}, want: "2026-02-14"},
+		{name: "due on a weekend", calendar: weekends, free: []string{"2026-10-16"}, queue: []int{15}, want: "2026-11-02"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var free []time.Time
+			for _, s := range tt.free {
+				free = append(free, day(s))
+			}
+			got, ok := managementsystem.EstimateAvailableDate(tt.calendar, free, tt.queue)
+			if !ok {
+				if tt.want != "" {
+					t.Fatalf("Expected %s, got no date", tt.want)
+				}
+				return
+			}
+			if got.Format(time.DateOnly) != tt.want {
+				t.Fatalf("Expected %q, got %s", tt.want, got.Format(time.DateOnly))
+			}
+		})
+	}
+}
+
+func TestHybridHandler5_BookAvailability(t *testing.T) {
+
+	os.Setenv("REDIS_ADDR", "localhost:6379")
+	os.Setenv("MySQL_DSN", "root:root@tcp(127.0.0.1:3306)/management_sys")
+
+	redisInstance, err := managementsystem.ConnectRedis()
+	if err != nil {
+		panic(err)
+	}
+	mysqlinstance, err := managementsystem.ConnectMySQL()
+	if err != nil {
+		panic(err)
+	}
+	handler := &managementsystem.HybridHandler5{MySQL: mysqlinstance, Redis: redisInstance, Ctx: context.Background()}
+	router := handler.Router()
+
+	mysqlinstance.DB.Exec("DELETE FROM holds")
+	mysqlinstance.DB.Exec("DELETE FROM fines")
+	mysqlinstance.DB.Exec("DELETE FROM borrow_records")
+	mysqlinstance.DB.Exec("DELETE FROM transfers")
+	mysqlinstance.DB.Exec("DELETE FROM book_copies")
+	mysqlinstance.DB.Exec("DELETE FROM books")
+	redisInstance.Client.FlushAll(context.Background())
+	mysqlinstance.DB.Exec("INSERT IGNORE INTO students (id, name, email, age, year) VALUES (?, ?, ?, ?, ?), (?, ?, ?, ?, ?)",
+		902, "reader", "reader902@gmail.com", 20, 1, 903, "reader", "reader903@gmail.com", 20, 1)
+
+	post := func(path string, v any) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(v)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, path, bytes.NewBuffer(body)))
+		return w
+	}
+	w := post("/books", managementsystem.Book{Title: "GoLang", Author: "Alice", Available_copies: 1})
+	var book managementsystem.Book
+	if err := json.NewDecoder(w.Body).Decode(&book); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	bookPath := "/books/" + strconv.Itoa(book.Book_id)
+	var loan managementsystem.Borrow_records
+
+	tests := []struct {
+		name      string // description of this test case
+		run       func()
+		available int
+		returns   int
+		holds     int
+		estimate  func() string
+	}{
+		{
+			name:      "on the shelf",
+			run:       func() {},
+			available: 1,
+			estimate:  func() string { return time.Now().Format(time.DateOnly) },
+		},
+		{
+			name: "lent out",
+			run: func() {
+				w := post("/borrow", managementsystem.Borrow_records{User_id: 902, User_type: "student", Book_id: book.Book_id})
+				json.NewDecoder(w.Body).Decode(&loan)
+			},
+			returns:  1,
+			estimate: func() string { return loan.Due_date },
+		},
+		{
+			name: "behind a hold",
+			run: func() {
+				post(bookPath+"/holds", managementsystem.Hold{UserID: 903, UserType: "student"})
+			},
+			returns: 1,
+			holds:   1,
+			estimate: func() string {
+				due, _ := time.Parse(time.DateOnly, loan.Due_date)
+				borrowed, _ := time.Parse(time.DateOnly, loan.Borrow_date)
+				return due.Add(due.Sub(borrowed)).Format(time.DateOnly)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.run()
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, bookPath+"/availability", nil))
+			if w.Code != http.StatusOK {
+				t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+			}
+			var a managementsystem.Availability
+			if err := json.NewDecoder(w.Body).Decode(&a); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if a.Available != tt.available || len(a.ExpectedReturns) != tt.returns || a.Holds != tt.holds {
+				t.Fatalf("Expected %d available, %d returns and %d holds, got %+v", tt.available, tt.returns, tt.holds, a)
+			}
+			if want := tt.estimate(); a.EstimatedDate == nil || *a.EstimatedDate != want {
+				t.Fatalf("Expected the estimate %s, got %+v", want, a)
+			}
+		})
+	}
+}
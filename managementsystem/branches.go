@@ -0,0 +1,93 @@
+package managementsystem
+
+import (
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"managementsystem/validation"
+)
+
+type Branch struct {
+	ID      int    `json:"id"`
+	Code    string `json:"code" validate:"trimmed,required,max=10"`
+	Name    string `json:"name" validate:"trimmed,required,max=100"`
+	Address string `json:"address" validate:"trimmed,max=255"`
+}
+
+// BranchCopies is how many copies of a book a branch has and how many of
+// them are on its shelves to borrow.
+type BranchCopies struct {
+	BranchID  int    `json:"branch_id"`
+	Code      string `json:"code"`
+	Name      string `json:"name"`
+	Copies    int    `json:"copies"`
+	Available int    `json:"available"`
+	InTransit int    `json:"in_transit"` // on their way to the branch
+}
+
+// validation
+func ValidateBranch(branch Branch) error {
+	return validation.Struct(branch)
+}
+
+var branchResource = &Resource[Branch]{
+	Name:        "branch",
+	Path:        "/branches",
+	Table:       "branches",
+	Key:         "id",
+	Columns:     []string{"code", "name", "address"},
+	CachePrefix: "branch:",
+	CacheTTL:    10 * time.Minute,
+	Fields: func(b *Branch) []any {
+		return []any{&b.ID, &b.Code, &b.Name, &nullString{&b.Address}}
+	},
+	Validate: func(b *Branch) error { return ValidateBranch(*b) },
+	Prepare: func(h *HybridHandler5, b *Branch) error {
+		b.Code = strings.ToUpper(strings.TrimSpace(b.Code))
+		b.Name = strings.TrimSpace(b.Name)
+		return nil
+	},
+	AfterCommit: func(h *HybridHandler5, b *Branch) {
+		if err := h.dropBookCache(h.MySQL.DB, "SELECT DISTINCT book_id FROM book_copies WHERE branch_id=?", b.ID); err != nil {
+			log.Println("book cache:", err)
+		}
+	},
+}
+
+// checkBranch answers 400 unless the branch exists.
+func checkBranch(q querier, id int) error {
+	var exists bool
+	if err := q.QueryRow("SELECT EXISTS(SELECT 1 FROM branches WHERE id=?)", id).Scan(&exists); err != nil {
+		return err
+	}
+	if !exists {
+		return newHTTPError(http.StatusBadRequest, "unknown branch %d", id)
+	}
+	return nil
+}
+
+// bookBranches counts the copies of a book at every branch. Copies in
+// transit count at the branch they are going to.
+func bookBranches(q querier, bookID int) ([]BranchCopies, error) {
+	rows, err := q.Query(`SELECT br.id, br.code, br.name,
+		(SELECT COUNT(*) FROM book_copies c WHERE c.book_id=? AND c.branch_id=br.id AND c.status NOT IN ('retired', 'lost', 'in_transit')),
+		(SELECT COUNT(*) FROM book_copies c WHERE c.book_id=? AND c.branch_id=br.id AND c.status='available'),
+		(SELECT COUNT(*) FROM transfers t JOIN book_copies c ON c.copy_id=t.copy_id WHERE c.book_id=? AND t.to_branch_id=br.id AND t.status='in_transit')
+		FROM branches br ORDER BY br.code`, bookID, bookID, bookID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	branches := []BranchCopies{}
+	for rows.Next() {
+		var b BranchCopies
+		if err := rows.Scan(&b.BranchID, &b.Code, &b.Name, &b.Copies, &b.Available, &b.InTransit); err != nil {
+			return nil, err
+		}
+		branches = append(branches, b)
+	}
+	return branches, rows.Err()
+}
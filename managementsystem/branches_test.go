@@ -0,0 +1,181 @@
+package managementsystem_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	managementsystem "managementsystem/managementsystem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"testing"
+)
+
+func TestHybridHandler5_Branches(t *testing.T) {
+
+	os.Setenv("REDIS_ADDR", "localhost:6379")
+	os.Setenv("MySQL_DSN", "root:root@tcp(127.0.0.1:3306)/management_sys")
+
+	redisInstance, err := managementsystem.ConnectRedis()
+	if err != nil {
+		panic(err)
+	}
+	mysqlinstance, err := managementsystem.ConnectMySQL()
+	if err != nil {
+		panic(err)
+	}
+	handler := &managementsystem.HybridHandler5{MySQL: mysqlinstance, Redis: redisInstance, Ctx: context.Background()}
+	router := handler.Router()
+
+	mysqlinstance.DB.Exec("DELETE FROM holds")
+	mysqlinstance.DB.Exec("DELETE FROM fines")
+	mysqlinstance.DB.Exec("DELETE FROM borrow_records")
+	mysqlinstance.DB.Exec("DELETE FROM transfers")
+	mysqlinstance.DB.Exec("DELETE FROM book_copies")
+	mysqlinstance.DB.Exec("DELETE FROM books")
+	mysqlinstance.DB.Exec("DELETE FROM branches WHERE code IN ('EAST', 'WEST')")
+	redisInstance.Client.FlushAll(context.Background())
+	mysqlinstance.DB.Exec("INSERT IGNORE INTO students (id, name, email, age, year) VALUES (?, ?, ?, ?, ?)", 601, "reader", "reader601@gmail.com", 20, 1)
+
+	post := func(path string, v any) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(v)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, path, bytes.NewBuffer(body)))
+		return w
+	}
+	branches := map[string]int{}
+	for _, code := range []string{"east", "west"} {
+		w := post("/branches", managementsystem.Branch{Code: code, Name: code + " campus"})
+		var branch managementsystem.Branch
+		if err := json.NewDecoder(w.Body).Decode(&branch); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		if branch.Code != "EAST" && branch.Code != "WEST" {
+			t.Fatalf("Expected the code upper-cased, got %+v", branch)
+		}
+		branches[branch.Code] = branch.ID
+	}
+	east, west := branches["EAST"], branches["WEST"]
+
+	// one copy at the first branch and one at EAST
+	w := post("/books", managementsystem.Book{Title: "GoLang", Author: "Alice", Available_copies: 1})
+	var book managementsystem.Book
+	if err := json.NewDecoder(w.Body).Decode(&book); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	bookPath := "/books/" + strconv.Itoa(book.Book_id)
+	w = post(bookPath+"/copies", managementsystem.BookCopy{Barcode: "EAST-1", Branch_id: east})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected created status , got %d: %s", w.Code, w.Body.String())
+	}
+	var eastCopy managementsystem.BookCopy
+	json.NewDecoder(w.Body).Decode(&eastCopy)
+
+	var transfer managementsystem.Transfer
+	tests := []struct {
+		name      string // description of this test case
+		run       func() *httptest.ResponseRecorder
+		status    int
+		branch    int // branch whose copies of the book are checked afterwards
+		available int
+		inTransit int
+	}{
+		{
+			name: "borrow at a branch without a copy",
+			run: func() *httptest.ResponseRecorder {
+				return post("/borrow", managementsystem.Borrow_records{User_id: 601, User_type: "student", Book_id: book.Book_id, Branch_id: west})
+			},
+			status: http.StatusBadRequest, branch: west,
+		},
+		{
+			name: "borrow at EAST",
+			run: func() *httptest.ResponseRecorder {
+				return post("/borrow", managementsystem.Borrow_records{User_id: 601, User_type: "student", Book_id: book.Book_id, Branch_id: east})
+			},
+			status: http.StatusCreated, branch: east,
+		},
+		{
+			name: "return at WEST sends the copy home",
+			run: func() *httptest.ResponseRecorder {
+				w := post("/return", managementsystem.ReturnRequest{Barcode: "EAST-1", Branch_id: west})
+				var response struct {
+					Transfer managementsystem.Transfer `json:"transfer"`
+				}
+				json.Unmarshal(w.Body.Bytes(), &response)
+				transfer = response.Transfer
+				return w
+			},
+			status: http.StatusCreated, branch: east, inTransit: 1,
+		},
+		{
+			name: "copy in transit cannot be sent again",
+			run: func() *httptest.ResponseRecorder {
+				return post("/transfers", managementsystem.Transfer{CopyID: eastCopy.Copy_id, ToBranchID: west})
+			},
+			status: http.StatusConflict, branch: east, inTransit: 1,
+		},
+		{
+			name: "EAST receives it",
+			run: func() *httptest.ResponseRecorder {
+				return post("/transfers/"+strconv.Itoa(transfer.ID)+"/receive", nil)
+			},
+			status: http.StatusOK, branch: east, available: 1,
+		},
+		{
+			name: "received twice",
+			run: func() *httptest.ResponseRecorder {
+				return post("/transfers/"+strconv.Itoa(transfer.ID)+"/receive", nil)
+			},
+			status: http.StatusConflict, branch: east, available: 1,
+		},
+		{
+			name: "send the copy to WEST",
+			run: func() *httptest.ResponseRecorder {
+				w := post("/transfers", managementsystem.Transfer{Barcode: "EAST-1", ToBranchID: west, Reason: "rebalancing"})
+				json.Unmarshal(w.Body.Bytes(), &transfer)
+				return w
+			},
+			status: http.StatusCreated, branch: west, inTransit: 1,
+		},
+		{
+			name: "cancel puts it back at EAST",
+			run: func() *httptest.ResponseRecorder {
+				return post("/transfers/"+strconv.Itoa(transfer.ID)+"/cancel", nil)
+			},
+			status: http.StatusOK, branch: east, available: 1,
+		},
+		{
+			name: "unknown branch",
+			run: func() *httptest.ResponseRecorder {
+				return post("/transfers", managementsystem.Transfer{Barcode: "EAST-1", ToBranchID: 987654})
+			},
+			status: http.StatusBadRequest, branch: east, available: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := tt.run()
+			if w.Code != tt.status {
+				t.Fatalf("Expected status %d, got %d: %s", tt.status, w.Code, w.Body.String())
+			}
+
+			w = httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, bookPath, nil))
+			var got managementsystem.Book
+			if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			for _, b := range got.Branches {
+				if b.BranchID != tt.branch {
+					continue
+				}
+				if b.Available != tt.available || b.InTransit != tt.inTransit {
+					t.Fatalf("Expected %d available and %d in transit at branch %d, got %+v", tt.available, tt.inTransit, tt.branch, b)
+				}
+				return
+			}
+			t.Fatalf("Expected branch %d in %+v", tt.branch, got.Branches)
+		})
+	}
+}
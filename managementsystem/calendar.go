@@ -0,0 +1,297 @@
+package managementsystem
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"managementsystem/validation"
+)
+
+// Calendar is when the library is closed: every week on ClosedWeekdays and
+// on the Holidays, keyed by YYYY-MM-DD. Due dates never fall on a closed
+// day and closed days are not fined.
+type Calendar struct {
+	Today          time.Time
+	Now            time.Time
+	ClosedWeekdays map[time.Weekday]bool
+	Holidays       map[string]bool
+}
+
+// Closed reports whether the library is closed on day.
+func (c Calendar) Closed(day time.Time) bool {
+	return c.ClosedWeekdays[day.Weekday()] || c.Holidays[day.Format(time.DateOnly)]
+}
+
+// NextOpen returns day if the library is open then, or the first day after
+// it that it is. A year of closed days is given up on.
+func (c Calendar) NextOpen(day time.Time) time.Time {
+	for i := 0; i < 366 && c.Closed(day); i++ {
+		day = day.AddDate(0, 0, 1)
+	}
+	return day
+}
+
+// DueDate is the due date of a loan of loanDays days from day, moved to the
+// next open day.
+func (c Calendar) DueDate(from time.Time, loanDays int) time.Time {
+	return c.NextOpen(from.AddDate(0, 0, loanDays))
+}
+
+// OverdueDays counts the days the library was open after a loan was due
+// until returned. A loan due on a closed day is due on the next open one.
+func (c Calendar) OverdueDays(due, returned time.Time) int {
+	days := 0
+	for day := c.NextOpen(due).AddDate(0, 0, 1); !day.After(returned); day = day.AddDate(0, 0, 1) {
+		if !c.Closed(day) {
+			days++
+		}
+	}
+	return days
+}
+
+// ShortLoanDue is when a short loan of hours from from is due, moved to
+// the same time on the next open day if that falls on a closed one.
+func (c Calendar) ShortLoanDue(from time.Time, hours int) time.Time {
+	return c.NextOpen(from.Add(time.Duration(hours) * time.Hour))
+}
+
+// OverdueHours counts the hours begun on days the library was open after a
+// short loan was due until returned.
+func (c Calendar) OverdueHours(due, returned time.Time) int {
+	var open time.Duration
+	for t := due; t.Before(returned); {
+		end := time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		if end.After(returned) {
+			end = returned
+		}
+		if !c.Closed(t) {
+			open += end.Sub(t)
+		}
+		t = end
+	}
+	return int((open + time.Hour - 1) / time.Hour)
+}
+
+// overdueHours is OverdueHours until now of a short loan due at due, if it
+// is one.
+func (c Calendar) overdueHours(due *string) (int, error) {
+	if due == nil {
+		return 0, nil
+	}
+	at, err := time.Parse(time.DateTime, *due)
+	if err != nil {
+		return 0, err
+	}
+	return c.OverdueHours(at, c.Now), nil
+}
+
+// overdueDays is OverdueDays until today of a loan due on due, if it has a
+// due date.
+func (c Calendar) overdueDays(due *string) (int, error) {
+	if due == nil {
+		return 0, nil
+	}
+	day, err := time.Parse(time.DateOnly, *due)
+	if err != nil {
+		return 0, err
+	}
+	return c.OverdueDays(day, c.Today), nil
+}
+
+// loadCalendar reads the library calendar, with today and now as MySQL
+// sees them.
+func loadCalendar(q querier) (*Calendar, error) {
+	c := Calendar{ClosedWeekdays: map[time.Weekday]bool{}, Holidays: map[string]bool{}}
+	var now string
+	if err := q.QueryRow("SELECT DATE_FORMAT(NOW(), '%Y-%m-%d %H:%i:%s')").Scan(&now); err != nil {
+		return nil, err
+	}
+	var err error
+	if c.Now, err = time.Parse(time.DateTime, now); err != nil {
+		return nil, err
+	}
+	c.Today = time.Date(c.Now.Year(), c.Now.Month(), c.Now.Day(), 0, 0, 0, 0, c.Now.Location())
+	rows, err := q.Query("SELECT weekday FROM library_closed_weekdays")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var weekday int
+		if err := rows.Scan(&weekday); err != nil {
+			return nil, err
+		}
+		c.ClosedWeekdays[time.Weekday(weekday)] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	// holidays long past make no difference to loans still out
+	holidays, err := q.Query("SELECT DATE_FORMAT(day, '%Y-%m-%d') FROM library_holidays WHERE day >= CURDATE() - INTERVAL 1 YEAR")
+	if err != nil {
+		return nil, err
+	}
+	defer holidays.Close()
+	for holidays.Next() {
+		var day string
+		if err := holidays.Scan(&day); err != nil {
+			return nil, err
+		}
+		c.Holidays[day] = true
+	}
+	return &c, holidays.Err()
+}
+
+// Holiday is a day the library is closed besides its weekly closing days.
+type Holiday struct {
+	ID   int    `json:"id"`
+	Day  string `json:"day" validate:"trimmed,required"`
+	Name string `json:"name" validate:"trimmed,required,max=100"`
+}
+
+// validation
+func ValidateHoliday(holiday Holiday) error {
+	if err := validation.Struct(holiday); err != nil {
+		return err
+	}
+	if _, err := time.Parse(time.DateOnly, holiday.Day); err != nil {
+		return fmt.Errorf("day must be YYYY-MM-DD")
+	}
+	return nil
+}
+
+var holidayResource = &Resource[Holiday]{
+	Name:        "holiday",
+	Path:        "/library/holidays",
+	Table:       "library_holidays",
+	Key:         "id",
+	Columns:     []string{"day", "name"},
+	CachePrefix: "holiday:",
+	CacheTTL:    10 * time.Minute,
+	Fields: func(d *Holiday) []any {
+		return []any{&d.ID, &d.Day, &d.Name}
+	},
+	Validate: func(d *Holiday) error { return ValidateHoliday(*d) },
+	Prepare: func(h *HybridHandler5, d *Holiday) error {
+		d.Day = strings.TrimSpace(d.Day)
+		return nil
+	},
+}
+
+// LibraryCalendar is the calendar as the API shows it, weekdays by their
+// lower case English names.
+type LibraryCalendar struct {
+	ClosedWeekdays []string  `json:"closed_weekdays"`
+	Holidays       []Holiday `json:"holidays"`
+}
+
+// weekdayNames maps the lower case name of a weekday to the weekday.
+var weekdayNames = func() map[string]time.Weekday {
+	names := map[string]time.Weekday{}
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		names[strings.ToLower(d.String())] = d
+	}
+	return names
+}()
+
+// Library calendar, with the holidays from ?from= on, or from today
+func (h *HybridHandler5) LibraryCalendarHandler(w http.ResponseWriter, r *http.Request) {
+	from := r.URL.Query().Get("from")
+	if from == "" {
+		from = time.Now().Format(time.DateOnly)
+	} else if _, err := time.Parse(time.DateOnly, from); err != nil {
+		http.Error(w, "from must be YYYY-MM-DD", http.StatusBadRequest)
+		return
+	}
+	calendar, err := libraryCalendar(h.MySQL.DB, from)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, calendar)
+}
+
+// Set the weekdays the library is closed every week
+func (h *HybridHandler5) ClosedWeekdaysHandler(w http.ResponseWriter, r *http.Request) {
+	var body LibraryCalendar
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	closed := map[time.Weekday]bool{}
+	for _, name := range body.ClosedWeekdays {
+		weekday, ok := weekdayNames[strings.ToLower(strings.TrimSpace(name))]
+		if !ok {
+			http.Error(w, fmt.Sprintf("unknown weekday %q", name), http.StatusBadRequest)
+			return
+		}
+		closed[weekday] = true
+	}
+	if len(closed) == len(weekdayNames) {
+		http.Error(w, "the library must open on at least one weekday", http.StatusBadRequest)
+		return
+	}
+	tx, err := h.MySQL.DB.Begin()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM library_closed_weekdays"); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	for weekday := range closed {
+		if _, err := tx.Exec("INSERT INTO library_closed_weekdays (weekday) VALUES (?)", int(weekday)); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	calendar, err := libraryCalendar(tx, time.Now().Format(time.DateOnly))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, calendar)
+}
+
+// libraryCalendar reads the calendar with the holidays from a day on.
+func libraryCalendar(q querier, from string) (*LibraryCalendar, error) {
+	calendar := LibraryCalendar{ClosedWeekdays: []string{}, Holidays: []Holiday{}}
+	rows, err := q.Query("SELECT weekday FROM library_closed_weekdays ORDER BY weekday")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var weekday int
+		if err := rows.Scan(&weekday); err != nil {
+			return nil, err
+		}
+		calendar.ClosedWeekdays = append(calendar.ClosedWeekdays, strings.ToLower(time.Weekday(weekday).String()))
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	holidays, err := q.Query("SELECT id, DATE_FORMAT(day, '%Y-%m-%d'), name FROM library_holidays WHERE day >= ? ORDER BY day", from)
+	if err != nil {
+		return nil, err
+	}
+	defer holidays.Close()
+	for holidays.Next() {
+		var d Holiday
+		if err := holidays.Scan(&d.ID, &d.Day, &d.Name); err != nil {
+			return nil, err
+		}
+		calendar.Holidays = append(calendar.Holidays, d)
+	}
+	return &calendar, holidays.Err()
+}
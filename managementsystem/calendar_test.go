@@ -0,0 +1,261 @@
+package managementsystem_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	managementsystem "managementsystem/managementsystem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCalendar(t *testing.T) {
+	day := func(s string) time.Time {
+		d, _ := time.Parse(time.DateOnly, s)
+		return d
+	}
+	// closed at weekends and on Friday 2026-10-30
+	calendar := managementsystem.Calendar{
+		ClosedWeekdays: map[time.Weekday]bool{time.Saturday: true, time.Sunday: true},
+		Holidays:       map[string]bool{"2026-10-30": true},
+	}
+	t.Run("DueDate", func(t *testing.T) {
+		tests := []struct {
+			name     string // description of this test case
+			calendar managementsystem.Calendar
+			from     string
+			days     int
+			want     string
+		}{
+			{name: "always open", from: "2026-10-16", days: 14, want: "2026-10-30"},
+			{name: "open day", calendar: calendar, from: "2026-10-19", days: 14, want: "2026-11-02"},
+			{name: "weekend", calendar: calendar, from: "2026-10-16", days: 1, want: "2026-10-19"},
+			{name: "holiday before a weekend", calendar: calendar, from: "2026-10-16", days: 14, want: "2026-11-02"},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				if got := tt.calendar.DueDate(day(tt.from), tt.days).Format(time.DateOnly); got != tt.want {
+					t.Fatalf("Expected due date %s, got %s", tt.want, got)
+				}
+			})
+		}
+	})
+	t.Run("OverdueDays", func(t *testing.T) {
+		tests := []struct {
+			name     string // description of this test case
+			due      string
+			returned string
+			want     int
+		}{
+			{name: "returned early", due: "2026-10-16", returned: "2026-10-15", want: 0},
+			{name: "returned on the due date", due: "2026-10-16", returned: "2026-10-16", want: 0},
+			{name: "returned after the weekend", due: "2026-10-16", returned: "2026-10-19", want: 1},
+			{name: "due on a closed day", due: "2026-10-17", returned: "2026-10-19", want: 0},
+			{name: "a week with a holiday", due: "2026-10-26", returned: "2026-11-02", want: 4},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				if got := calendar.OverdueDays(day(tt.due), day(tt.returned)); got != tt.want {
+					t.Fatalf("Expected %d days overdue, got %d", tt.want, got)
+				}
+			})
+		}
+	})
+	at := func(s string) time.Time {
+		d, _ := time.Parse(time.DateTime, s)
+		return d
+	}
+	t.Run("ShortLoanDue", func(t *testing.T) {
+		tests := []struct {
+			name  string // description of this test case
+			from  string
+			hours int
+			want  string
+		}{
+			{name: "same day", from: "2026-10-16 14:00:00", hours: 4, want: "2026-10-16 18:00:00"},
+			{name: "into the weekend", from: "2026-10-16 22:00:00", hours: 4, want: "2026-10-19 02:00:00"},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				if got := calendar.ShortLoanDue(at(tt.from), tt.hours).Format(time.DateTime); got != tt.want {
+					t.Fatalf("Expected due at %s, got %s", tt.want, got)
+				}
+			})
+		}
+	})
+	t.Run("OverdueHours", func(t *testing.T) {
+		tests := []struct {
+			name     string // description of this test case
+			due      string
+			returned string
+			want     int
+		}{
+			{name: "returned early", due: "2026-10-19 12:00:00", returned: "2026-10-19 11:00:00", want: 0},
+			{name: "hour begun", due: "2026-10-19 10:00:00", returned: "2026-10-19 12:30:00", want: 3},
+			{name: "over the weekend", due: "2026-10-16 16:00:00", returned: "2026-10-19 09:00:00", want: 17},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				if got := calendar.OverdueHours(at(tt.due), at(tt.returned)); got != tt.want {
+					t.Fatalf("Expected %d hours overdue, got %d", tt.want, got)
+				}
+			})
+		}
+	})
+}
+
+func TestHybridHandler5_LibraryCalendar(t *testing.T) {
+
+	os.Setenv("REDIS_ADDR", "localhost:6379")
+	os.Setenv("MySQL_DSN", "root:root@tcp(127.0.0.1:3306)/management_sys")
+
+	redisInstance, err := managementsystem.ConnectRedis()
+	if err != nil {
+		panic(err)
+	}
+	mysqlinstance, err := managementsystem.ConnectMySQL()
+	if err != nil {
+		panic(err)
+	}
+	handler := &managementsystem.HybridHandler5{MySQL: mysqlinstance, Redis: redisInstance, Ctx: context.Background()}
+	router := handler.Router()
+
+	mysqlinstance.DB.Exec("DELETE FROM library_holidays")
+	mysqlinstance.DB.Exec("DELETE FROM library_closed_weekdays")
+	mysqlinstance.DB.Exec("DELETE FROM holds")
+	mysqlinstance.DB.Exec("DELETE FROM fines")
+	mysqlinstance.DB.Exec("DELETE FROM borrow_records")
+	mysqlinstance.DB.Exec("DELETE FROM transfers")
+	mysqlinstance.DB.Exec("DELETE FROM book_copies")
+	mysqlinstance.DB.Exec("DELETE FROM books")
+	redisInstance.Client.FlushAll(context.Background())
+	mysqlinstance.DB.Exec("INSERT IGNORE INTO students (id, name, email, age, year) VALUES (?, ?, ?, ?, ?)", 1101, "reader", "reader1101@gmail.com", 20, 1)
+	// later tests borrow every day of the week
+	defer mysqlinstance.DB.Exec("DELETE FROM library_closed_weekdays")
+	defer mysqlinstance.DB.Exec("DELETE FROM library_holidays")
+
+	send := func(method, path string, v any) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(v)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(method, path, bytes.NewBuffer(body)))
+		return w
+	}
+	w := send(http.MethodPost, "/books", managementsystem.Book{Title: "GoLang", Author: "Alice", Available_copies: 2})
+	var book managementsystem.Book
+	if err := json.NewDecoder(w.Body).Decode(&book); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+
+	// the library opens only on today's weekday, so loans are due a whole
+	// number of weeks from today
+	today := time.Now()
+	var closed []string
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		if d != today.Weekday() {
+			closed = append(closed, strings.ToLower(d.String()))
+		}
+	}
+	var due string
+	tests := []struct {
+		name   string // description of this test case
+		run    func() *httptest.ResponseRecorder
+		status int
+		check  func(w *httptest.ResponseRecorder)
+	}{
+		{
+			name: "close every day",
+			run: func() *httptest.ResponseRecorder {
+				return send(http.MethodPut, "/library/calendar/weekdays", managementsystem.LibraryCalendar{ClosedWeekdays: append(closed, strings.ToLower(today.Weekday().String()))})
+			},
+			status: http.StatusBadRequest,
+		},
+		{
+			name: "unknown weekday",
+			run: func() *httptest.ResponseRecorder {
+				return send(http.MethodPut, "/library/calendar/weekdays", managementsystem.LibraryCalendar{ClosedWeekdays: []string{"someday"}})
+			},
+			status: http.StatusBadRequest,
+		},
+		{
+			name: "open one day a week",
+			run: func() *httptest.ResponseRecorder {
+				return send(http.MethodPut, "/library/calendar/weekdays", managementsystem.LibraryCalendar{ClosedWeekdays: closed})
+			},
+			status: http.StatusOK,
+		},
+		{
+			name: "due on an open day",
+			run: func() *httptest.ResponseRecorder {
+				return send(http.MethodPost, "/borrow", managementsystem.Borrow_records{User_id: 1101, User_type: "student", Book_id: book.Book_id})
+			},
+			status: http.StatusCreated,
+			check: func(w *httptest.ResponseRecorder) {
+				var loan managementsystem.Borrow_records
+				json.NewDecoder(w.Body).Decode(&loan)
+				d, err := time.Parse(time.DateOnly, loan.Due_date)
+				if err != nil || d.Weekday() != today.Weekday() {
+					t.Fatalf("Expected a loan due on a %s, got %+v", today.Weekday(), loan)
+				}
+				due = loan.Due_date
+			},
+		},
+		{
+			name: "holiday on the due date",
+			run: func() *httptest.ResponseRecorder {
+				return send(http.MethodPost, "/library/holidays", managementsystem.Holiday{Day: due, Name: "Founders' Day"})
+			},
+			status: http.StatusCreated,
+		},
+		{
+			name: "holiday twice",
+			run: func() *httptest.ResponseRecorder {
+				return send(http.MethodPost, "/library/holidays", managementsystem.Holiday{Day: due, Name: "Founders' Day"})
+			},
+			status: http.StatusConflict,
+		},
+		{
+			name: "due a week after the holiday",
+			run: func() *httptest.ResponseRecorder {
+				return send(http.MethodPost, "/borrow", managementsystem.Borrow_records{User_id: 1101, User_type: "student", Book_id: book.Book_id})
+			},
+			status: http.StatusCreated,
+			check: func(w *httptest.ResponseRecorder) {
+				var loan managementsystem.Borrow_records
+				json.NewDecoder(w.Body).Decode(&loan)
+				holiday, _ := time.Parse(time.DateOnly, due)
+				if want := holiday.AddDate(0, 0, 7).Format(time.DateOnly); loan.Due_date != want {
+					t.Fatalf("Expected the loan due on %s, got %+v", want, loan)
+				}
+			},
+		},
+		{
+			name: "calendar",
+			run: func() *httptest.ResponseRecorder {
+				return send(http.MethodGet, "/library/calendar", nil)
+			},
+			status: http.StatusOK,
+			check: func(w *httptest.ResponseRecorder) {
+				var calendar managementsystem.LibraryCalendar
+				json.NewDecoder(w.Body).Decode(&calendar)
+				if len(calendar.ClosedWeekdays) != 6 || len(calendar.Holidays) != 1 || calendar.Holidays[0].Day != due {
+					t.Fatalf("Expected six closed weekdays and the holiday, got %+v", calendar)
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := tt.run()
+			if w.Code != tt.status {
+				t.Fatalf("Expected status %d, got %d: %s", tt.status, w.Code, w.Body.String())
+			}
+			if tt.check != nil {
+				tt.check(w)
+			}
+		})
+	}
+}
@@ -0,0 +1,429 @@
+package managementsystem
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"managementsystem/validation"
+)
+
+// Catalog import formats.
+const (
+	formatCSV     = "csv"
+	formatMARC    = "marc"
+	formatMARCXML = "marcxml"
+)
+
+// What the import did with a record.
+const (
+	importCreated = "created"
+	importMerged  = "merged"
+	importFailed  = "failed"
+)
+
+// CatalogRecord is one book read from an import file, with the copies to
+// add and the problem with the record if it could not be read.
+type CatalogRecord struct {
+	Record int
+	Book   Book
+	Copies int
+	Error  string
+}
+
+type ImportResult struct {
+	Record  int    `json:"record"`
+	Title   string `json:"title"`
+	ISBN13  string `json:"isbn13,omitempty"`
+	Action  string `json:"action"`
+	Book_id int    `json:"book_id,omitempty"`
+	Copies  int    `json:"copies"`
+	Error   string `json:"error,omitempty"`
+}
+
+type ImportReport struct {
+	Format  string         `json:"format"`
+	Records int            `json:"records"`
+	Created int            `json:"created"`
+	Merged  int            `json:"merged"`
+	Failed  int            `json:"failed"`
+	Results []ImportResult `json:"results"`
+}
+
+// setISBN puts an ISBN of either length where it belongs on a book.
+func setISBN(book *Book, isbn string) {
+	isbn = NormalizeISBN(isbn)
+	if len(isbn) == 10 {
+		book.ISBN10 = isbn
+	} else {
+		book.ISBN13 = isbn
+	}
+}
+
+// ParseCatalogCSV reads books from CSV with a header row. Columns are
+// matched by name: title, author (several separated by semicolons), isbn,
+// isbn10, isbn13 and copies, which defaults to 1. Other columns are ignored.
+func ParseCatalogCSV(r io.Reader) ([]CatalogRecord, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("reading csv header: %w", err)
+	}
+	// plural and singular names are both accepted
+	aliases := map[string]string{"authors": "author", "copies": "copy", "available_copies": "copy"}
+	columns := map[string]int{}
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		if alias, ok := aliases[name]; ok {
+			name = alias
+		}
+		columns[name] = i
+	}
+	if _, ok := columns["title"]; !ok {
+		return nil, fmt.Errorf("csv header has no title column")
+	}
+	column := func(row []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(row) {
+			return strings.TrimSpace(row[i])
+		}
+		return ""
+	}
+
+	var records []CatalogRecord
+	for n := 1; ; n++ {
+		row, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return records, nil
+		}
+		record := CatalogRecord{Record: n, Copies: 1}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if !errors.As(err, &parseErr) {
+				return nil, err
+			}
+			record.Error = err.Error()
+			records = append(records, record)
+			continue
+		}
+		record.Book.Title = column(row, "title")
+		for _, name := range strings.Split(column(row, "author"), ";") {
+			if name = strings.TrimSpace(name); name != "" {
+				record.Book.Authors = append(record.Book.Authors, Author{Name: name})
+			}
+		}
+		if isbn := column(row, "isbn"); isbn != "" {
+			setISBN(&record.Book, isbn)
+		}
+		if isbn := column(row, "isbn10"); isbn != "" {
+			record.Book.ISBN10 = isbn
+		}
+		if isbn := column(row, "isbn13"); isbn != "" {
+			record.Book.ISBN13 = isbn
+		}
+		if copies := column(row, "copy"); copies != "" {
+			if record.Copies, err = strconv.Atoi(copies); err != nil {
+				record.Error = "copies must be a number"
+			}
+		}
+		records = append(records, record)
+	}
+}
+
+// marcField is a MARC 21 variable field. Control fields (00X) have only
+// Value; data fields have subfields.
+type marcField struct {
+	Tag       string
+	Value     string
+	Subfields [][2]string // code, value
+}
+
+// catalogRecord maps the MARC fields onto a book: the title from 245 $a and
+// $b, authors from 100 $a and 700 $a, the first ISBN in 020 $a, and a copy
+// for each 852 holdings field, or one when there are none.
+func catalogRecord(n int, fields []marcField) CatalogRecord {
+	record := CatalogRecord{Record: n}
+	for _, field := range fields {
+		switch field.Tag {
+		case "245":
+			var parts []string
+			for _, sub := range field.Subfields {
+				if sub[0] == "a" || sub[0] == "b" {
+					parts = append(parts, marcTrim(sub[1]))
+				}
+			}
+			record.Book.Title = strings.Join(parts, " ")
+		case "100", "700":
+			for _, sub := range field.Subfields {
+				if sub[0] == "a" {
+					record.Book.Authors = append(record.Book.Authors, Author{Name: marcTrim(sub[1])})
+				}
+			}
+		case "020":
+			for _, sub := range field.Subfields {
+				// "0306406152 (pbk.)" qualifies the ISBN after it
+				if sub[0] == "a" && record.Book.ISBN10 == "" && record.Book.ISBN13 == "" {
+					if isbn := strings.Fields(sub[1]); len(isbn) > 0 {
+						setISBN(&record.Book, isbn[0])
+					}
+				}
+			}
+		case "852":
+			record.Copies++
+		}
+	}
+	if record.Copies == 0 {
+		record.Copies = 1
+	}
+	return record
+}
+
+// marcTrim drops the ISBD punctuation MARC leaves at the end of subfields.
+func marcTrim(s string) string {
+	return strings.TrimSpace(strings.TrimRight(strings.TrimSpace(s), " /:;,=."))
+}
+
+// MARC 21 delimiters.
+const (
+	marcSubfieldMark = 0x1F
+	marcFieldEnd     = 0x1E
+	marcRecordEnd    = 0x1D
+)
+
+// ParseMARC reads books from binary MARC 21 (ISO 2709) records. Records are
+// expected in UTF-8; MARC-8 text outside ASCII is not converted.
+func ParseMARC(r io.Reader) ([]CatalogRecord, error) {
+	var records []CatalogRecord
+	reader := bufio.NewReader(r)
+	for n := 1; ; n++ {
+		raw, err := reader.ReadBytes(marcRecordEnd)
+		raw = bytes.TrimLeft(raw, " \r\n")
+		if len(raw) > 0 {
+			fields, parseErr := parseMARCRecord(raw)
+			if parseErr != nil {
+				records = append(records, CatalogRecord{Record: n, Error: parseErr.Error()})
+			} else {
+				records = append(records, catalogRecord(n, fields))
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return records, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+func parseMARCRecord(raw []byte) ([]marcField, error) {
+	if len(raw) < 25 {
+		return nil, fmt.Errorf("marc record too short")
+	}
+	base, err := strconv.Atoi(string(raw[12:17]))
+	if err != nil || !digits(string(raw[12:17])) || base < 25 || base > len(raw) {
+		return nil, fmt.Errorf("marc leader has no valid base address")
+	}
+	directory := raw[24 : base-1]
+	if len(directory)%12 != 0 {
+		return nil, fmt.Errorf("marc directory is malformed")
+	}
+	var fields []marcField
+	for i := 0; i < len(directory); i += 12 {
+		entry := directory[i : i+12]
+		// Atoi alone would take a sign, and a negative length or start
+		// would slice outside the record
+		if !digits(string(entry[3:12])) {
+			return nil, fmt.Errorf("marc directory entry %s is malformed", entry[:3])
+		}
+		length, err1 := strconv.Atoi(string(entry[3:7]))
+		start, err2 := strconv.Atoi(string(entry[7:12]))
+		if err1 != nil || err2 != nil || base+start+length > len(raw) {
+			return nil, fmt.Errorf("marc directory entry %s is malformed", entry[:3])
+		}
+		field := marcField{Tag: string(entry[:3])}
+		data := bytes.TrimRight(raw[base+start:base+start+length], string([]byte{marcFieldEnd, marcRecordEnd}))
+		if strings.HasPrefix(field.Tag, "00") {
+			field.Value = string(data)
+		} else {
+			parts := bytes.Split(data, []byte{marcSubfieldMark})
+			// parts[0] holds the two indicators
+			for _, part := range parts[1:] {
+				if len(part) > 0 {
+					field.Subfields = append(field.Subfields, [2]string{string(part[:1]), string(part[1:])})
+				}
+			}
+		}
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+type marcXMLRecord struct {
+	ControlFields []struct {
+		Tag   string `xml:"tag,attr"`
+		Value string `xml:",chardata"`
+	} `xml:"controlfield"`
+	DataFields []struct {
+		Tag       string `xml:"tag,attr"`
+		Subfields []struct {
+			Code  string `xml:"code,attr"`
+			Value string `xml:",chardata"`
+		} `xml:"subfield"`
+	} `xml:"datafield"`
+}
+
+// ParseMARCXML reads books from the record elements of MARCXML, either a
+// collection or a single record.
+func ParseMARCXML(r io.Reader) ([]CatalogRecord, error) {
+	var records []CatalogRecord
+	decoder := xml.NewDecoder(r)
+	for n := 1; ; {
+		token, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			return records, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading marcxml: %w", err)
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok || start.Name.Local != "record" {
+			continue
+		}
+		var rec marcXMLRecord
+		if err := decoder.DecodeElement(&rec, &start); err != nil {
+			return nil, fmt.Errorf("reading marcxml record %d: %w", n, err)
+		}
+		var fields []marcField
+		for _, cf := range rec.ControlFields {
+			fields = append(fields, marcField{Tag: cf.Tag, Value: cf.Value})
+		}
+		for _, df := range rec.DataFields {
+			field := marcField{Tag: df.Tag}
+			for _, sub := range df.Subfields {
+				field.Subfields = append(field.Subfields, [2]string{sub.Code, sub.Value})
+			}
+			fields = append(fields, field)
+		}
+		records = append(records, catalogRecord(n, fields))
+		n++
+	}
+}
+
+// importFormat picks the format from ?format=, else the Content-Type, else
+// the first bytes of the body.
+func importFormat(r *http.Request, body *bufio.Reader) (string, error) {
+	switch format := r.URL.Query().Get("format"); format {
+	case formatCSV, formatMARC, formatMARCXML:
+		return format, nil
+	case "":
+	default:
+		return "", fmt.Errorf("format must be csv, marc or marcxml")
+	}
+	contentType := r.Header.Get("Content-Type")
+	switch {
+	case strings.HasPrefix(contentType, "text/csv"):
+		return formatCSV, nil
+	case strings.HasPrefix(contentType, "application/marc"):
+		if strings.Contains(contentType, "xml") {
+			return formatMARCXML, nil
+		}
+		return formatMARC, nil
+	case strings.Contains(contentType, "xml"):
+		return formatMARCXML, nil
+	}
+	head, _ := body.Peek(5)
+	switch {
+	case len(bytes.TrimSpace(head)) > 0 && bytes.TrimSpace(head)[0] == '<':
+		return formatMARCXML, nil
+	case len(head) == 5 && digits(string(head)):
+		return formatMARC, nil
+	}
+	return formatCSV, nil
+}
+
+// Import books from CSV or MARC
+func (h *HybridHandler5) ImportBooksHandler(w http.ResponseWriter, r *http.Request) {
+	body := bufio.NewReader(http.MaxBytesReader(w, r.Body, 32<<20))
+	format, err := importFormat(r, body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var records []CatalogRecord
+	switch format {
+	case formatCSV:
+		records, err = ParseCatalogCSV(body)
+	case formatMARC:
+		records, err = ParseMARC(body)
+	case formatMARCXML:
+		records, err = ParseMARCXML(body)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	report := ImportReport{Format: format, Records: len(records), Results: []ImportResult{}}
+	for _, record := range records {
+		result := h.importRecord(r, record)
+		switch result.Action {
+		case importCreated:
+			report.Created++
+		case importMerged:
+			report.Merged++
+		default:
+			report.Failed++
+		}
+		report.Results = append(report.Results, result)
+	}
+	writeJSON(w, http.StatusOK, report)
+}
+
+// importRecord adds the copies of a record to the book with its ISBN, or
+// creates the book, each record in a transaction of its own.
+func (h *HybridHandler5) importRecord(r *http.Request, record CatalogRecord) ImportResult {
+	book := record.Book
+	result := ImportResult{Record: record.Record, Title: book.Title, Copies: record.Copies}
+	fail := func(err error) ImportResult {
+		result.Action = importFailed
+		result.Error = validation.Translate(err, r.Header.Get("Accept-Language")).Error()
+		return result
+	}
+	if record.Error != "" {
+		return fail(errors.New(record.Error))
+	}
+	book.Available_copies = record.Copies
+	if err := checkNewBook(h, &book); err != nil {
+		return fail(err)
+	}
+	result.ISBN13 = book.ISBN13
+
+	tx, err := h.MySQL.DB.Begin()
+	if err != nil {
+		return fail(err)
+	}
+	defer tx.Rollback()
+
+	merged, err := stockBook(h, tx, &book)
+	if err != nil {
+		return fail(err)
+	}
+	result.Action = importCreated
+	if merged {
+		result.Action = importMerged
+	}
+	if err := tx.Commit(); err != nil {
+		return fail(err)
+	}
+	h.cacheDel(bookResource.cacheKey(book.Book_id))
+	result.Book_id = book.Book_id
+	return result
+}
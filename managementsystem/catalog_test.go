@@ -0,0 +1,276 @@
+package managementsystem_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	managementsystem "managementsystem/managementsystem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+// marc21 encodes data fields as a binary MARC 21 record. Each field is a
+// tag followed by its indicators and subfields, e.g. "245", "10", "aTitle".
+func marc21(fields ...[]string) string {
+	var directory, data strings.Builder
+	for _, field := range fields {
+		value := field[1]
+		for _, sub := range field[2:] {
+			value += "\x1f" + sub
+		}
+		value += "\x1e"
+		fmt.Fprintf(&directory, "%s%04d%05d", field[0], len(value), data.Len())
+		data.WriteString(value)
+	}
+	base := 24 + directory.Len() + 1
+	length := base + data.Len() + 1
+	leader := fmt.Sprintf("%05dnam a22%05d a 4500", length, base)
+	return leader + directory.String() + "\x1e" + data.String() + "\x1d"
+}
+
+func TestParseMARC(t *testing.T) {
+	tests := []struct {
+		name    string // description of this test case
+		input   string
+		title   string
+		authors []string
+		isbn10  string
+		isbn13  string
+		copies  int
+		failed  bool
+	}{
+		{
+			name: "co-authored book with two holdings",
+			input: marc21(
+				[]string{"020", "  ", "a9780134190440 (pbk.)"},
+				[]string{"100", "1 ", "aDonovan, Alan A. A.,", "eauthor."},
+				[]string{"245", "14", "aThe Go programming language /", "cAlan A. A. Donovan, Brian W. Kernighan."},
+				[]string{"700", "1 ", "aKernighan, Brian W.,"},
+				[]string{"852", "  ", "aMain"},
+				[]string{"852", "  ", "aBranch"},
+			),
+			title:   "The Go programming language",
+			authors: []string{"Donovan, Alan A. A", "Kernighan, Brian W"},
+			isbn13:  "9780134190440",
+			copies:  2,
+		},
+		{
+			name: "subtitle and an ISBN-10",
+			input: marc21(
+				[]string{"020", "  ", "a0-306-40615-2"},
+				[]string{"245", "10", "aRust :", "bsystems programming."},
+			),
+			title:  "Rust systems programming",
+			isbn10: "0306406152",
+			copies: 1,
+		},
+		{
+			name:   "truncated record",
+			input:  "00099nam a22000",
+			failed: true,
+		},
+		{
+			// a signed length in the directory once sliced outside the record
+			name:   "negative field length",
+			input:  strings.Replace(marc21([]string{"245", "10", "aRust"}), "24500090", "245-0010", 1),
+			failed: true,
+		},
+		{
+			name:   "negative field start",
+			input:  strings.Replace(marc21([]string{"245", "10", "aRust"}), "000900000", "0009-0001", 1),
+			failed: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			records, err := managementsystem.ParseMARC(strings.NewReader(tt.input))
+			if err != nil {
+				t.Fatalf("ParseMARC() failed: %v", err)
+			}
+			if len(records) != 1 {
+				t.Fatalf("Expected one record, got %d", len(records))
+			}
+			record := records[0]
+			if tt.failed {
+				if record.Error == "" {
+					t.Fatalf("Expected the record to fail, got %+v", record)
+				}
+				return
+			}
+			if record.Error != "" {
+				t.Fatalf("ParseMARC() record failed: %s", record.Error)
+			}
+			book := record.Book
+			if book.Title != tt.title || book.ISBN10 != tt.isbn10 || book.ISBN13 != tt.isbn13 || record.Copies != tt.copies {
+				t.Fatalf("Expected %q %q %q with %d copies, got %+v with %d copies", tt.title, tt.isbn10, tt.isbn13, tt.copies, book, record.Copies)
+			}
+			if len(book.Authors) != len(tt.authors) {
+				t.Fatalf("Expected authors %v, got %+v", tt.authors, book.Authors)
+			}
+			for i, name := range tt.authors {
+				if book.Authors[i].Name != name {
+					t.Fatalf("Expected authors %v, got %+v", tt.authors, book.Authors)
+				}
+			}
+		})
+	}
+}
+
+func TestParseMARCXML(t *testing.T) {
+	input := `<?xml version="1.0" encoding="UTF-8"?>
+<marc:collection xmlns:marc="http://www.loc.gov/MARC21/slim">
+  <marc:record>
+    <marc:leader>00000nam a2200000 a 4500</marc:leader>
+    <marc:controlfield tag="001">12345</marc:controlfield>
+    <marc:datafield tag="020" ind1=" " ind2=" "><marc:subfield code="a">9780134190440</marc:subfield></marc:datafield>
+    <marc:datafield tag="100" ind1="1" ind2=" "><marc:subfield code="a">Donovan, Alan A. A.,</marc:subfield></marc:datafield>
+    <marc:datafield tag="245" ind1="1" ind2="4"><marc:subfield code="a">The Go programming language /</marc:subfield></marc:datafield>
+  </marc:record>
+  <marc:record>
+    <marc:datafield tag="245" ind1="1" ind2="0"><marc:subfield code="a">Rust.</marc:subfield></marc:datafield>
+  </marc:record>
+</marc:collection>`
+	records, err := managementsystem.ParseMARCXML(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("ParseMARCXML() failed: %v", err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("Expected two records, got %d", len(records))
+	}
+	if got := records[0].Book; got.Title != "The Go programming language" || got.ISBN13 != "9780134190440" || len(got.Authors) != 1 {
+		t.Fatalf("Unexpected first record %+v", got)
+	}
+	if got := records[1]; got.Record != 2 || got.Book.Title != "Rust" || got.Copies != 1 {
+		t.Fatalf("Unexpected second record %+v", got)
+	}
+}
+
+func TestParseCatalogCSV(t *testing.T) {
+	input := "Title,Authors,ISBN,Copies\n" +
+		"The Go Programming Language,Alan Donovan; Brian Kernighan,978-0-13-419044-0,3\n" +
+		"Rust,Alice,,\n" +
+		"Zig,Bob,,many\n"
+	records, err := managementsystem.ParseCatalogCSV(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("ParseCatalogCSV() failed: %v", err)
+	}
+	if len(records) != 3 {
+		t.Fatalf("Expected three records, got %d", len(records))
+	}
+	if got := records[0]; got.Book.ISBN13 != "9780134190440" || len(got.Book.Authors) != 2 || got.Copies != 3 {
+		t.Fatalf("Unexpected first record %+v", got)
+	}
+	if got := records[1]; got.Book.Title != "Rust" || got.Copies != 1 || got.Error != "" {
+		t.Fatalf("Unexpected second record %+v", got)
+	}
+	if records[2].Error == "" {
+		t.Fatalf("Expected copies of many to fail, got %+v", records[2])
+	}
+
+	if _, err := managementsystem.ParseCatalogCSV(strings.NewReader("name,author\nGoLang,Alice\n")); err == nil {
+		t.Fatalf("Expected a header without title to fail")
+	}
+}
+
+func TestHybridHandler5_ImportBooksHandler(t *testing.T) {
+
+	os.Setenv("REDIS_ADDR", "localhost:6379")
+	os.Setenv("MySQL_DSN", "root:root@tcp(127.0.0.1:3306)/management_sys")
+
+	redisInstance, err := managementsystem.ConnectRedis()
+	if err != nil {
+		panic(err)
+	}
+	mysqlinstance, err := managementsystem.ConnectMySQL()
+	if err != nil {
+		panic(err)
+	}
+	handler := &managementsystem.HybridHandler5{MySQL: mysqlinstance, Redis: redisInstance, Ctx: context.Background()}
+	router := handler.Router()
+
+	mysqlinstance.DB.Exec("DELETE FROM holds")
+	mysqlinstance.DB.Exec("DELETE FROM fines")
+	mysqlinstance.DB.Exec("DELETE FROM borrow_records")
+	mysqlinstance.DB.Exec("DELETE FROM book_copies")
+	mysqlinstance.DB.Exec("DELETE FROM books")
+	redisInstance.Client.FlushAll(context.Background())
+
+	// the library already has one copy of the Go book
+	body, _ := json.Marshal(managementsystem.Book{Title: "The Go Programming Language", Author: "Alan Donovan", ISBN13: "9780134190440", Available_copies: 1})
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/books", bytes.NewBuffer(body)))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected created status , got %d: %s", w.Code, w.Body.String())
+	}
+
+	csv := "title,author,isbn,copies\n" +
+		"The Go Programming Language,Alan Donovan,0134190440,2\n" +
+		"Rust,Alice,,1\n" +
+		"Bad ISBN,Bob,9780134190441,1\n"
+	tests := []struct {
+		name        string // description of this test case
+		contentType string
+		body        string
+		status      int
+		actions     []string
+	}{
+		{
+			name:        "csv",
+			contentType: "text/csv",
+			body:        csv,
+			status:      http.StatusOK,
+			actions:     []string{"merged", "created", "failed"},
+		},
+		{
+			name:    "marc by sniffing",
+			body:    marc21([]string{"020", "  ", "a9780134190440"}, []string{"245", "14", "aThe Go programming language /"}),
+			status:  http.StatusOK,
+			actions: []string{"merged"},
+		},
+		{
+			name:        "csv without a title column",
+			contentType: "text/csv",
+			body:        "name\nGoLang\n",
+			status:      http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/books/import", strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			if w.Code != tt.status {
+				t.Fatalf("Expected status %d, got %d: %s", tt.status, w.Code, w.Body.String())
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+			var report managementsystem.ImportReport
+			if err := json.NewDecoder(w.Body).Decode(&report); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if len(report.Results) != len(tt.actions) {
+				t.Fatalf("Expected %d results, got %+v", len(tt.actions), report)
+			}
+			for i, action := range tt.actions {
+				if report.Results[i].Action != action {
+					t.Fatalf("Expected record %d %s, got %+v", i+1, action, report.Results[i])
+				}
+			}
+		})
+	}
+
+	// 1 copy to start, 2 from the csv and 1 from marc
+	var copies int
+	mysqlinstance.DB.QueryRow("SELECT COUNT(*) FROM book_copies c JOIN books b ON b.book_id = c.book_id WHERE b.isbn13=?", "9780134190440").Scan(&copies)
+	if copies != 4 {
+		t.Fatalf("Expected 4 copies of the merged book, got %d", copies)
+	}
+}
@@ -0,0 +1,124 @@
+package managementsystem
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"managementsystem/validation"
+)
+
+// Checkout borrows several books for one user at once, each by book id or
+// by the barcode of a copy, at the branch if it has one.
+type Checkout struct {
+	UserID   int            `json:"user_id" validate:"min=1"`
+	UserType string         `json:"user_type" validate:"enum=student|lecturer"`
+	BranchID int            `json:"branch_id" validate:"min=0"`
+	Items    []CheckoutItem `json:"items" validate:"min=1,max=20"`
+}
+
+type CheckoutItem struct {
+	BookID  int    `json:"book_id"`
+	Barcode string `json:"barcode"`
+}
+
+// CheckoutReceipt lists the loans of a checkout with their due dates.
+type CheckoutReceipt struct {
+	UserID   int              `json:"user_id"`
+	UserType string           `json:"user_type"`
+	Loans    []Borrow_records `json:"loans"`
+}
+
+// CheckoutRefusal tells why a checkout borrowed nothing, item by item.
+type CheckoutRefusal struct {
+	Error string              `json:"Error"`
+	Items []CheckoutItemError `json:"items"`
+}
+
+// CheckoutItemError is an item that could not be borrowed, with the status
+// and body borrowing it alone would have been answered with.
+type CheckoutItemError struct {
+	Index   int    `json:"index"`
+	BookID  int    `json:"book_id,omitempty"`
+	Barcode string `json:"barcode,omitempty"`
+	Status  int    `json:"status"`
+	Error   string `json:"error"`
+	Refusal any    `json:"refusal,omitempty"`
+}
+
+// records turns the items of a checkout into the loans to make.
+func (c *Checkout) records() []Borrow_records {
+	records := make([]Borrow_records, len(c.Items))
+	for i, item := range c.Items {
+		records[i] = Borrow_records{User_id: c.UserID, User_type: c.UserType, Book_id: item.BookID, Barcode: item.Barcode, Branch_id: c.BranchID}
+	}
+	return records
+}
+
+// validation
+func ValidateCheckout(c *Checkout) error {
+	if err := validation.Struct(c); err != nil {
+		return err
+	}
+	for i, record := range c.records() {
+		if err := ValidateBorrow(record); err != nil {
+			return fmt.Errorf("items[%d]: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// Borrow several books in one transaction, all of them or none
+func (h *HybridHandler5) CheckoutHandler(w http.ResponseWriter, r *http.Request) {
+	var c Checkout
+	if err := json.NewDecoder(r.Body).Decode(&c); err != nil {
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+	if err := ValidateCheckout(&c); err != nil {
+		writeValidationError(w, r, err)
+		return
+	}
+	if err := h.updateSuspension(c.UserType, c.UserID); err != nil {
+		writeError(w, err)
+		return
+	}
+	tx, err := h.MySQL.DB.Begin()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	// every item is tried, so the desk sees all that stands in the way;
+	// the loans made so far count towards the loan limit of the next
+	receipt := CheckoutReceipt{UserID: c.UserID, UserType: c.UserType, Loans: []Borrow_records{}}
+	var failed []CheckoutItemError
+	for i, record := range c.records() {
+		err := borrow(tx, &record)
+		var he *httpError
+		if errors.As(err, &he) {
+			failed = append(failed, CheckoutItemError{Index: i, BookID: record.Book_id, Barcode: record.Barcode, Status: he.Status, Error: he.Message, Refusal: he.Body})
+			continue
+		}
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		receipt.Loans = append(receipt.Loans, record)
+	}
+	if len(failed) > 0 {
+		refusal := CheckoutRefusal{Error: fmt.Sprintf("%d of %d books cannot be borrowed, none was", len(failed), len(c.Items)), Items: failed}
+		writeJSON(w, failed[0].Status, refusal)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	for _, loan := range receipt.Loans {
+		h.cacheDel(bookResource.cacheKey(loan.Book_id))
+	}
+	writeJSON(w, http.StatusCreated, receipt)
+}
@@ -0,0 +1,93 @@
+package managementsystem_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	managementsystem "managementsystem/managementsystem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestHybridHandler5_Checkout(t *testing.T) {
+
+	os.Setenv("REDIS_ADDR", "localhost:6379")
+	os.Setenv("MySQL_DSN", "root:root@tcp(127.0.0.1:3306)/management_sys")
+
+	redisInstance, err := managementsystem.ConnectRedis()
+	if err != nil {
+		panic(err)
+	}
+	mysqlinstance, err := managementsystem.ConnectMySQL()
+	if err != nil {
+		panic(err)
+	}
+	handler := &managementsystem.HybridHandler5{MySQL: mysqlinstance, Redis: redisInstance, Ctx: context.Background()}
+	router := handler.Router()
+
+	mysqlinstance.DB.Exec("DELETE FROM holds")
+	mysqlinstance.DB.Exec("DELETE FROM fines")
+	mysqlinstance.DB.Exec("DELETE FROM borrow_records")
+	mysqlinstance.DB.Exec("DELETE FROM transfers")
+	mysqlinstance.DB.Exec("DELETE FROM book_copies")
+	mysqlinstance.DB.Exec("DELETE FROM books")
+	redisInstance.Client.FlushAll(context.Background())
+	mysqlinstance.DB.Exec("INSERT IGNORE INTO students (id, name, email, age, year) VALUES (?, ?, ?, ?, ?)", 901, "reader", "reader901@gmail.com", 20, 1)
+
+	post := func(path string, v any) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(v)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, path, bytes.NewBuffer(body)))
+		return w
+	}
+	ids := []int{}
+	for _, title := range []string{"GoLang", "Rust"} {
+		var book managementsystem.Book
+		w := post("/books", managementsystem.Book{Title: title, Author: "Alice", Available_copies: 1})
+		if err := json.NewDecoder(w.Body).Decode(&book); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		ids = append(ids, book.Book_id)
+	}
+	goBook, rust := ids[0], ids[1]
+
+	tests := []struct {
+		name   string // description of this test case
+		items  []managementsystem.CheckoutItem
+		status int
+		loans  int // loans 901 has afterwards
+	}{
+		{name: "no items", items: nil, status: http.StatusBadRequest},
+		{name: "item without a book", items: []managementsystem.CheckoutItem{{BookID: goBook}, {}}, status: http.StatusBadRequest},
+		{name: "unknown book", items: []managementsystem.CheckoutItem{{BookID: goBook}, {BookID: 987654}}, status: http.StatusNotFound},
+		{name: "one copy twice", items: []managementsystem.CheckoutItem{{BookID: goBook}, {BookID: rust}, {BookID: goBook}}, status: http.StatusBadRequest},
+		{name: "both books", items: []managementsystem.CheckoutItem{{BookID: goBook}, {BookID: rust}}, status: http.StatusCreated, loans: 2},
+		{name: "already borrowed", items: []managementsystem.CheckoutItem{{BookID: rust}}, status: http.StatusBadRequest, loans: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := post("/borrows/batch", managementsystem.Checkout{UserID: 901, UserType: "student", Items: tt.items})
+			if w.Code != tt.status {
+				t.Fatalf("Expected status %d, got %d: %s", tt.status, w.Code, w.Body.String())
+			}
+			if w.Code == http.StatusCreated {
+				var receipt managementsystem.CheckoutReceipt
+				if err := json.NewDecoder(w.Body).Decode(&receipt); err != nil {
+					t.Fatalf("failed to decode response: %v", err)
+				}
+				for _, loan := range receipt.Loans {
+					if loan.Due_date == "" {
+						t.Fatalf("Expected a due date, got %+v", loan)
+					}
+				}
+			}
+			var loans int
+			mysqlinstance.DB.QueryRow("SELECT COUNT(*) FROM borrow_records WHERE user_id=901 AND user_type='student'").Scan(&loans)
+			if loans != tt.loans {
+				t.Fatalf("Expected %d loans, got %d", tt.loans, loans)
+			}
+		})
+	}
+}
@@ -0,0 +1,386 @@
+package managementsystem
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"managementsystem/validation"
+)
+
+// Copy statuses. Only available copies can be borrowed, and copies on hold
+// only by the user holding them. Copies in transit are on their way to
+// another branch. Lost and retired copies are no longer in the inventory.
+const (
+	copyAvailable = "available"
+	copyBorrowed  = "borrowed"
+	copyOnHold    = "on_hold"
+	copyInRepair  = "in_repair"
+	copyInTransit = "in_transit"
+	copyLost      = "lost"
+	copyRetired   = "retired"
+)
+
+// BookCopy is one physical copy of a book, shelved at a branch.
+type BookCopy struct {
+	Copy_id        int    `json:"copy_id"`
+	Book_id        int    `json:"book_id"`
+	Branch_id      int    `json:"branch_id" validate:"min=0"`
+	Reserve_id     *int   `json:"reserve_id"` // the course reserve it is on, lent on short loans
+	Barcode        string `json:"barcode" validate:"trimmed,max=32"`
+	Condition      string `json:"condition" validate:"enum=new|good|fair|poor|damaged"`
+	Shelf_location string `json:"shelf_location" validate:"max=50"`
+	Condition_note string `json:"condition_note" validate:"max=255"`
+	Status         string `json:"status"`
+}
+
+// availableCopiesSQL counts the available copies of the books row in scope.
+const availableCopiesSQL = "(SELECT COUNT(*) FROM book_copies c WHERE c.book_id = books.book_id AND c.status = 'available')"
+
+// validation
+func ValidateCopy(bookCopy BookCopy) error {
+	return validation.Struct(bookCopy)
+}
+
+var copyResource = &Resource[BookCopy]{
+	Name:    "copy",
+	Table:   "book_copies",
+	Key:     "copy_id",
+	Columns: []string{"book_id", "branch_id", "reserve_id", "barcode", "copy_condition", "shelf_location", "condition_note", "status"},
+	Fields: func(c *BookCopy) []any {
+		return []any{&c.Copy_id, &c.Book_id, &c.Branch_id, &c.Reserve_id, &c.Barcode, &c.Condition, &nullString{&c.Shelf_location}, &nullString{&c.Condition_note}, &c.Status}
+	},
+	Validate: func(c *BookCopy) error { return ValidateCopy(*c) },
+}
+
+// addCopies adds n available copies of a book like template, generating
+// barcodes after the highest one the book has unless template has one,
+// which is only allowed for a single copy. Copies without a branch go
+// to the first one. New copies are never on course reserve; copies are put
+// on reserve when the reserve is created.
+func addCopies(q querier, bookID, n int, template BookCopy) ([]BookCopy, error) {
+	template.Reserve_id = nil
+	if template.Barcode != "" && n > 1 {
+		return nil, newHTTPError(http.StatusBadRequest, "a barcode can only be given for a single copy")
+	}
+	if template.Condition == "" {
+		template.Condition = "good"
+	}
+	if err := ValidateCopy(template); err != nil {
+		return nil, err
+	}
+	if template.Branch_id == 0 {
+		var first *int
+		if err := q.QueryRow("SELECT MIN(id) FROM branches").Scan(&first); err != nil {
+			return nil, err
+		}
+		if first == nil {
+			return nil, newHTTPError(http.StatusConflict, "no branch to shelve the copy at")
+		}
+		template.Branch_id = *first
+	} else if err := checkBranch(q, template.Branch_id); err != nil {
+		return nil, err
+	}
+	// the book row is locked so concurrent adds number their copies in turn
+	var locked int
+	if err := q.QueryRow("SELECT book_id FROM books WHERE book_id=? FOR UPDATE", bookID).Scan(&locked); err != nil {
+		return nil, err
+	}
+	var existing int
+	prefix := fmt.Sprintf("B%06d-", bookID)
+	err := q.QueryRow("SELECT IFNULL(MAX(CAST(SUBSTRING(barcode, ?) AS UNSIGNED)), 0) FROM book_copies WHERE book_id=? AND barcode LIKE ?", len(prefix)+1, bookID, prefix+"%").Scan(&existing)
+	if err != nil {
+		return nil, err
+	}
+	copies := make([]BookCopy, 0, n)
+	for i := 1; i <= n; i++ {
+		c := template
+		c.Book_id = bookID
+		c.Status = copyAvailable
+		if c.Barcode == "" {
+			c.Barcode = fmt.Sprintf("%s%03d", prefix, existing+i)
+		}
+		fields := copyResource.Fields(&c)
+		res, err := q.Exec("INSERT INTO book_copies (book_id, branch_id, reserve_id, barcode, copy_condition, shelf_location, condition_note, status) VALUES (?, ?, ?, ?, ?, ?, ?, ?)", values(fields[1:])...)
+		if err != nil {
+			return nil, err
+		}
+		id, err := res.LastInsertId()
+		if err != nil {
+			return nil, err
+		}
+		c.Copy_id = int(id)
+		copies = append(copies, c)
+	}
+	return copies, nil
+}
+
+// loadCopy reads a copy, locking it when q is a transaction.
+func loadCopy(q querier, where string, args ...any) (*BookCopy, error) {
+	var c BookCopy
+	err := q.QueryRow(copyResource.selectSQL()+" WHERE "+where+" FOR UPDATE", args...).Scan(copyResource.Fields(&c)...)
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// List copies of a book
+func (h *HybridHandler5) ListCopiesHandler(w http.ResponseWriter, r *http.Request) {
+	bookID, ok := bookResource.id(w, r)
+	if !ok {
+		return
+	}
+	if _, err := bookResource.load(h, bookID); err != nil {
+		bookResource.writeLoadError(w, err)
+		return
+	}
+	rows, err := h.MySQL.DB.Query(copyResource.selectSQL()+" WHERE book_id=? ORDER BY copy_id", bookID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	copies := []BookCopy{}
+	for rows.Next() {
+		var c BookCopy
+		if err := rows.Scan(copyResource.Fields(&c)...); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		copies = append(copies, c)
+	}
+	if err := rows.Err(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, copies)
+}
+
+// Add a copy to a book
+func (h *HybridHandler5) AddCopyHandler(w http.ResponseWriter, r *http.Request) {
+	bookID, ok := bookResource.id(w, r)
+	if !ok {
+		return
+	}
+	var template BookCopy
+	if err := json.NewDecoder(r.Body).Decode(&template); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if _, err := bookResource.load(h, bookID); err != nil {
+		bookResource.writeLoadError(w, err)
+		return
+	}
+	tx, err := h.MySQL.DB.Begin()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	copies, err := addCopies(tx, bookID, 1, template)
+	if err != nil {
+		var verrs validation.Errors
+		if errors.As(err, &verrs) {
+			writeValidationError(w, r, err)
+			return
+		}
+		writeError(w, err)
+		return
+	}
+	// the new copy goes to the first waiting hold, if any
+	if err := refreshHolds(tx, bookID); err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := tx.QueryRow("SELECT status FROM book_copies WHERE copy_id=?", copies[0].Copy_id).Scan(&copies[0].Status); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	h.cacheDel(bookResource.cacheKey(bookID))
+	writeJSON(w, http.StatusCreated, copies[0])
+}
+
+// Get copy
+func (h *HybridHandler5) GetCopyHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := copyResource.id(w, r)
+	if !ok {
+		return
+	}
+	c, err := copyResource.load(h, id)
+	if err != nil {
+		copyResource.writeLoadError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, c)
+}
+
+// Update condition, condition note or shelf location of a copy
+func (h *HybridHandler5) UpdateCopyHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := copyResource.id(w, r)
+	if !ok {
+		return
+	}
+	c, err := copyResource.load(h, id)
+	if err != nil {
+		copyResource.writeLoadError(w, err)
+		return
+	}
+	var body struct {
+		Condition      *string `json:"condition"`
+		Shelf_location *string `json:"shelf_location"`
+		Condition_note *string `json:"condition_note"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if body.Condition != nil {
+		c.Condition = *body.Condition
+	}
+	if body.Shelf_location != nil {
+		c.Shelf_location = *body.Shelf_location
+	}
+	if body.Condition_note != nil {
+		c.Condition_note = *body.Condition_note
+	}
+	if err := ValidateCopy(*c); err != nil {
+		writeValidationError(w, r, err)
+		return
+	}
+	_, err = h.MySQL.DB.Exec("UPDATE book_copies SET copy_condition=?, shelf_location=?, condition_note=? WHERE copy_id=?", c.Condition, nullString{&c.Shelf_location}, nullString{&c.Condition_note}, id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, c)
+}
+
+// Retire a copy that is not on loan
+func (h *HybridHandler5) RetireCopyHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "invalid copy id", http.StatusBadRequest)
+		return
+	}
+	tx, err := h.MySQL.DB.Begin()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	c, err := loadCopy(tx, "copy_id=?", id)
+	if err != nil {
+		copyResource.writeLoadError(w, err)
+		return
+	}
+	switch c.Status {
+	case copyRetired:
+		http.Error(w, "copy already retired", http.StatusConflict)
+		return
+	case copyBorrowed:
+		http.Error(w, "copy is on loan and must be returned first", http.StatusConflict)
+		return
+	case copyOnHold:
+		http.Error(w, "copy is held for a reader", http.StatusConflict)
+		return
+	case copyLost:
+		http.Error(w, "copy is lost", http.StatusConflict)
+		return
+	case copyInTransit:
+		http.Error(w, "copy is in transit between branches", http.StatusConflict)
+		return
+	}
+	if _, err := tx.Exec("UPDATE book_copies SET status=?, retired_at=NOW() WHERE copy_id=?", copyRetired, id); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	h.cacheDel(bookResource.cacheKey(c.Book_id))
+	c.Status = copyRetired
+	writeJSON(w, http.StatusOK, c)
+}
+
+// pickCopy locks the copy a borrow will lend: the one with the record's
+// barcode, or else a copy held for the user, or the first available copy of
+// the book, one on course reserve only when no other is left. Copies held for
+// someone else are never lent, and a borrow at a branch only lends copies
+// shelved there.
+func pickCopy(tx querier, record *Borrow_records) (*BookCopy, error) {
+	if record.Branch_id != 0 {
+		if err := checkBranch(tx, record.Branch_id); err != nil {
+			return nil, err
+		}
+	}
+	bookID := record.Book_id
+	if record.Barcode != "" {
+		err := tx.QueryRow("SELECT book_id FROM book_copies WHERE barcode=?", record.Barcode).Scan(&bookID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, newHTTPError(http.StatusNotFound, "copy not found")
+		}
+		if err != nil {
+			return nil, err
+		}
+		if record.Book_id != 0 && record.Book_id != bookID {
+			return nil, newHTTPError(http.StatusBadRequest, "barcode %s is not a copy of book %d", record.Barcode, record.Book_id)
+		}
+	} else {
+		var exists bool
+		if err := tx.QueryRow("SELECT EXISTS(SELECT 1 FROM books WHERE book_id=?)", bookID).Scan(&exists); err != nil {
+			return nil, err
+		}
+		if !exists {
+			return nil, newHTTPError(http.StatusNotFound, "Book not found")
+		}
+	}
+	if err := refreshHolds(tx, bookID); err != nil {
+		return nil, err
+	}
+
+	held, err := loadCopy(tx, "copy_id=(SELECT copy_id FROM holds WHERE book_id=? AND user_id=? AND user_type=? AND status=? ORDER BY id LIMIT 1)", bookID, record.User_id, record.User_type, holdReady)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+	if held != nil && (record.Barcode == "" || record.Barcode == held.Barcode) && (record.Branch_id == 0 || record.Branch_id == held.Branch_id) {
+		return held, nil
+	}
+	if record.Barcode != "" {
+		c, err := loadCopy(tx, "barcode=?", record.Barcode)
+		if err != nil {
+			return nil, err
+		}
+		if c.Status != copyAvailable {
+			return nil, newHTTPError(http.StatusBadRequest, "copy %s is %s", c.Barcode, c.Status)
+		}
+		if record.Branch_id != 0 && c.Branch_id != record.Branch_id {
+			return nil, newHTTPError(http.StatusBadRequest, "copy %s is shelved at branch %d", c.Barcode, c.Branch_id)
+		}
+		return c, nil
+	}
+	if record.Branch_id != 0 {
+		c, err := loadCopy(tx, "book_id=? AND branch_id=? AND status=? ORDER BY reserve_id IS NOT NULL, copy_id LIMIT 1", bookID, record.Branch_id, copyAvailable)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, newHTTPError(http.StatusBadRequest, "Book not available at this branch")
+		}
+		return c, err
+	}
+	c, err := loadCopy(tx, "book_id=? AND status=? ORDER BY reserve_id IS NOT NULL, copy_id LIMIT 1", bookID, copyAvailable)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, newHTTPError(http.StatusBadRequest, "Book not available")
+	}
+	return c, err
+}
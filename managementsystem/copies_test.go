@@ -0,0 +1,182 @@
+package managementsystem_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	managementsystem "managementsystem/managementsystem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"testing"
+)
+
+func TestHybridHandler5_BookCopies(t *testing.T) {
+
+	os.Setenv("REDIS_ADDR", "localhost:6379")
+	os.Setenv("MySQL_DSN", "root:root@tcp(127.0.0.1:3306)/management_sys")
+
+	redisInstance, err := managementsystem.ConnectRedis()
+	if err != nil {
+		panic(err)
+	}
+	mysqlinstance, err := managementsystem.ConnectMySQL()
+	if err != nil {
+		panic(err)
+	}
+	handler := &managementsystem.HybridHandler5{MySQL: mysqlinstance, Redis: redisInstance, Ctx: context.Background()}
+	router := handler.Router()
+
+	mysqlinstance.DB.Exec("DELETE FROM fines")
+	mysqlinstance.DB.Exec("DELETE FROM borrow_records")
+	mysqlinstance.DB.Exec("DELETE FROM book_copies")
+	mysqlinstance.DB.Exec("DELETE FROM books")
+	redisInstance.Client.FlushAll(context.Background())
+	// the borrowers must exist
+	mysqlinstance.DB.Exec("INSERT IGNORE INTO students (id, name, email, age, year) VALUES (?, ?, ?, ?, ?)", 101, "borrower", "borrower101@gmail.com", 20, 1)
+
+	// a new book starts with available_copies copies
+	body, _ := json.Marshal(managementsystem.Book{Title: "GoLang", Author: "Alice", Available_copies: 2})
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/books", bytes.NewBuffer(body)))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected created status , got %d: %s", w.Code, w.Body.String())
+	}
+	var book managementsystem.Book
+	if err := json.NewDecoder(w.Body).Decode(&book); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	bookPath := "/books/" + strconv.Itoa(book.Book_id)
+
+	body, _ = json.Marshal(managementsystem.BookCopy{Barcode: "GO-SHELF", Condition: "new", Shelf_location: "A1"})
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, bookPath+"/copies", bytes.NewBuffer(body)))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected created status , got %d: %s", w.Code, w.Body.String())
+	}
+	var added managementsystem.BookCopy
+	if err := json.NewDecoder(w.Body).Decode(&added); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	copyPath := "/copies/" + strconv.Itoa(added.Copy_id)
+
+	tests := []struct {
+		name      string // description of this test case
+		method    string
+		path      string
+		body      any
+		status    int
+		available int // available_copies of the book afterwards
+	}{
+		{
+			name:      "list copies",
+			method:    http.MethodGet,
+			path:      bookPath + "/copies",
+			status:    http.StatusOK,
+			available: 3,
+		},
+		{
+			name:      "invalid condition",
+			method:    http.MethodPatch,
+			path:      copyPath,
+			body:      map[string]string{"condition": "shiny"},
+			status:    http.StatusBadRequest,
+			available: 3,
+		},
+		{
+			name:      "borrow by barcode",
+			method:    http.MethodPost,
+			path:      "/borrow",
+			body:      managementsystem.Borrow_records{User_id: 101, User_type: "student", Barcode: "GO-SHELF"},
+			status:    http.StatusCreated,
+			available: 2,
+		},
+		{
+			name:      "borrowed copy cannot be retired",
+			method:    http.MethodPost,
+			path:      copyPath + "/retire",
+			status:    http.StatusConflict,
+			available: 2,
+		},
+		{
+			name:      "return",
+			method:    http.MethodPost,
+			path:      "/return",
+			body:      managementsystem.Borrow_records{User_id: 101, User_type: "student", Book_id: book.Book_id},
+			status:    http.StatusCreated,
+			available: 3,
+		},
+		{
+			name:      "retire",
+			method:    http.MethodPost,
+			path:      copyPath + "/retire",
+			status:    http.StatusOK,
+			available: 2,
+		},
+		{
+			name:      "already retired",
+			method:    http.MethodPost,
+			path:      copyPath + "/retire",
+			status:    http.StatusConflict,
+			available: 2,
+		},
+		{
+			name:      "retired copy cannot be borrowed",
+			method:    http.MethodPost,
+			path:      "/borrow",
+			body:      managementsystem.Borrow_records{User_id: 101, User_type: "student", Barcode: "GO-SHELF"},
+			status:    http.StatusBadRequest,
+			available: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buffer bytes.Buffer
+			if tt.body != nil {
+				if err := json.NewEncoder(&buffer).Encode(tt.body); err != nil {
+					log.Panic(err)
+				}
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, &buffer))
+			if w.Code != tt.status {
+				t.Fatalf("Expected status %d, got %d: %s", tt.status, w.Code, w.Body.String())
+			}
+
+			w = httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, bookPath, nil))
+			var got managementsystem.Book
+			if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if got.Available_copies != tt.available {
+				t.Fatalf("Expected %d available copies, got %d", tt.available, got.Available_copies)
+			}
+		})
+	}
+
+	// generated barcodes follow the highest one, even after a copy is gone
+	addCopy := func() managementsystem.BookCopy {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, bookPath+"/copies", bytes.NewBufferString("{}")))
+		if w.Code != http.StatusCreated {
+			t.Fatalf("Expected created status , got %d: %s", w.Code, w.Body.String())
+		}
+		var c managementsystem.BookCopy
+		json.NewDecoder(w.Body).Decode(&c)
+		return c
+	}
+	prefix := fmt.Sprintf("B%06d-", book.Book_id)
+	if c := addCopy(); c.Barcode != prefix+"004" {
+		t.Fatalf("Expected barcode %s004, got %+v", prefix, c)
+	}
+	if _, err := mysqlinstance.DB.Exec("DELETE FROM book_copies WHERE barcode=?", prefix+"001"); err != nil {
+		t.Fatalf("delete copy fail: %v", err)
+	}
+	if c := addCopy(); c.Barcode != prefix+"005" {
+		t.Fatalf("Expected barcode %s005, got %+v", prefix, c)
+	}
+}
@@ -0,0 +1,143 @@
+package managementsystem
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"managementsystem/validation"
+)
+
+type Department struct {
+	ID             int    `json:"id"`
+	Code           string `json:"code" validate:"trimmed,required,max=50"`
+	Name           string `json:"name" validate:"trimmed,required,max=100"`
+	HeadLecturerID *int   `json:"head_lecturer_id"`
+}
+
+type DepartmentSummary struct {
+	Department    Department `json:"department"`
+	Head          *Lecturer  `json:"head"`
+	StudentCount  int        `json:"student_count"`
+	LecturerCount int        `json:"lecturer_count"`
+}
+
+// validation
+func ValidateDepartment(department Department) error {
+	return validation.Struct(department)
+}
+
+var departmentResource = &Resource[Department]{
+	Name:        "department",
+	Path:        "/departments",
+	Table:       "departments",
+	Key:         "id",
+	Columns:     []string{"code", "name", "head_lecturer_id"},
+	CachePrefix: "department:",
+	CacheTTL:    10 * time.Minute,
+	Fields: func(d *Department) []any {
+		return []any{&d.ID, &d.Code, &d.Name, &d.HeadLecturerID}
+	},
+	Validate: func(d *Department) error { return ValidateDepartment(*d) },
+	Prepare: func(h *HybridHandler5, d *Department) error {
+		d.Code = strings.ToUpper(strings.TrimSpace(d.Code))
+		d.Name = strings.TrimSpace(d.Name)
+		return nil
+	},
+	AfterSave: func(h *HybridHandler5, tx querier, d *Department) error {
+		return syncDepartmentCode(tx, d)
+	},
+	AfterCommit: func(h *HybridHandler5, d *Department) {
+		h.dropDepartmentMembers(d)
+	},
+}
+
+// resolveDepartment points a student or lecturer at its department, looked
+// up by id or else by code or name, and replaces dept with the code.
+func (h *HybridHandler5) resolveDepartment(id **int, dept *string) error {
+	var row *sql.Row
+	if *id != nil && **id > 0 {
+		row = h.MySQL.DB.QueryRow("SELECT id, code FROM departments WHERE id=?", **id)
+	} else if name := strings.TrimSpace(*dept); name != "" {
+		row = h.MySQL.DB.QueryRow("SELECT id, code FROM departments WHERE code=? OR name=?", name, name)
+	} else {
+		return nil
+	}
+	var deptID int
+	if err := row.Scan(&deptID, dept); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return newHTTPError(http.StatusBadRequest, "unknown department")
+		}
+		return err
+	}
+	*id = &deptID
+	return nil
+}
+
+// syncDepartmentCode copies a department's code onto its students and
+// lecturers.
+func syncDepartmentCode(q querier, d *Department) error {
+	for _, table := range []string{"students", "lecturers"} {
+		if _, err := q.Exec("UPDATE "+table+" SET dept=? WHERE dept_id=? AND dept<>?", d.Code, d.ID, d.Code); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dropDepartmentMembers drops the cached copies of a department's students
+// and lecturers, once syncDepartmentCode's changes are committed.
+func (h *HybridHandler5) dropDepartmentMembers(d *Department) {
+	for _, res := range []struct{ table, prefix string }{
+		{"students", studentResource.CachePrefix},
+		{"lecturers", lecturerResource.CachePrefix},
+	} {
+		rows, err := h.MySQL.DB.Query("SELECT id FROM "+res.table+" WHERE dept_id=?", d.ID)
+		if err != nil {
+			log.Println("department cache:", err)
+			continue
+		}
+		for rows.Next() {
+			var id int
+			if err := rows.Scan(&id); err != nil {
+				break
+			}
+			h.cacheDel(res.prefix + strconv.Itoa(id))
+		}
+		rows.Close()
+	}
+}
+
+// Department summary
+func (h *HybridHandler5) DepartmentSummaryHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "invalid department id", http.StatusBadRequest)
+		return
+	}
+	department, err := departmentResource.load(h, id)
+	if err != nil {
+		departmentResource.writeLoadError(w, err)
+		return
+	}
+	summary := DepartmentSummary{Department: *department}
+	err = h.MySQL.DB.QueryRow("SELECT (SELECT COUNT(*) FROM students WHERE dept_id=?), (SELECT COUNT(*) FROM lecturers WHERE dept_id=?)", id, id).Scan(&summary.StudentCount, &summary.LecturerCount)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if department.HeadLecturerID != nil {
+		head, err := lecturerResource.load(h, *department.HeadLecturerID)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		summary.Head = head
+	}
+	writeJSON(w, http.StatusOK, summary)
+}
@@ -0,0 +1,170 @@
+package managementsystem_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log"
+	managementsystem "managementsystem/managementsystem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"testing"
+)
+
+func TestHybridHandler5_CreateDepartment(t *testing.T) {
+
+	os.Setenv("REDIS_ADDR", "localhost:6379")
+	os.Setenv("MySQL_DSN", "root:root@tcp(127.0.0.1:3306)/management_sys")
+
+	redisInstance, err := managementsystem.ConnectRedis()
+	if err != nil {
+		panic(err)
+	}
+	mysqlinstance, err := managementsystem.ConnectMySQL()
+	if err != nil {
+		panic(err)
+	}
+	handler := &managementsystem.HybridHandler5{MySQL: mysqlinstance, Redis: redisInstance, Ctx: context.Background()}
+	router := handler.Router()
+
+	mysqlinstance.DB.Exec("DELETE FROM departments WHERE code='ECE'")
+	redisInstance.Client.FlushAll(context.Background())
+
+	tests := []struct {
+		name       string // description of this test case
+		department managementsystem.Department
+		status     int
+	}{
+		{
+			name:       "valid",
+			department: managementsystem.Department{Code: "ece", Name: "Electronics"},
+			status:     http.StatusCreated,
+		},
+		{
+			name:       "duplicate code",
+			department: managementsystem.Department{Code: "ECE", Name: "Electronics and Communication"},
+			status:     http.StatusConflict,
+		},
+		{
+			name:       "empty name",
+			department: managementsystem.Department{Code: "ME", Name: "  "},
+			status:     http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			userBytes, err := json.Marshal(tt.department)
+			if err != nil {
+				log.Panic(err)
+			}
+			r := httptest.NewRequest(http.MethodPost, "/departments", bytes.NewBuffer(userBytes))
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, r)
+
+			if w.Code != tt.status {
+				t.Fatalf("Expected status %d, got %d", tt.status, w.Code)
+			}
+			if tt.status == http.StatusCreated {
+				var department managementsystem.Department
+				if err := json.NewDecoder(w.Body).Decode(&department); err != nil {
+					t.Fatalf("failed to decode response: %v", err)
+				}
+				if department.Code != "ECE" {
+					t.Fatalf("Expected code ECE, got %s", department.Code)
+				}
+			}
+		})
+	}
+}
+
+func TestHybridHandler5_DepartmentSummaryHandler(t *testing.T) {
+
+	os.Setenv("REDIS_ADDR", "localhost:6379")
+	os.Setenv("MySQL_DSN", "root:root@tcp(127.0.0.1:3306)/management_sys")
+
+	redisInstance, err := managementsystem.ConnectRedis()
+	if err != nil {
+		panic(err)
+	}
+	mysqlinstance, err := managementsystem.ConnectMySQL()
+	if err != nil {
+		panic(err)
+	}
+	handler := &managementsystem.HybridHandler5{MySQL: mysqlinstance, Redis: redisInstance, Ctx: context.Background()}
+	router := handler.Router()
+
+	mysqlinstance.DB.Exec("DELETE FROM students")
+	mysqlinstance.DB.Exec("DELETE FROM lecturers")
+	redisInstance.Client.FlushAll(context.Background())
+
+	var deptID int
+	if err := mysqlinstance.DB.QueryRow("SELECT id FROM departments WHERE code='CSE'").Scan(&deptID); err != nil {
+		t.Fatalf("CSE department missing: %v", err)
+	}
+
+	// "cse" and "Computer Science" must land in the same department
+	for i, dept := range []string{"cse", "Computer Science"} {
+		body, _ := json.Marshal(managementsystem.Student{Name: "Akash", Email: "akash" + strconv.Itoa(i) + "@gmail.com", Age: 20, Dept: dept, Year: 3})
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/students", bytes.NewBuffer(body)))
+		if w.Code != http.StatusCreated {
+			t.Fatalf("Expected created status , got %d: %s", w.Code, w.Body.String())
+		}
+	}
+	body, _ := json.Marshal(managementsystem.Lecturer{Name: "ramesh", Email: "ramesh@gmail.com", Dept: "CSE", Designation: "Senior Lecturer"})
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/lecturers", bytes.NewBuffer(body)))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected created status , got %d: %s", w.Code, w.Body.String())
+	}
+
+	tests := []struct {
+		name      string // description of this test case
+		id        int
+		students  int
+		lecturers int
+		willpass  bool
+	}{
+		{
+			name:      "valid id",
+			id:        deptID,
+			students:  2,
+			lecturers: 1,
+			willpass:  true,
+		},
+		{
+			name:     "invalid id",
+			id:       9876,
+			willpass: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/departments/"+strconv.Itoa(tt.id)+"/summary", nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, r)
+
+			if tt.willpass {
+				if w.Code != http.StatusOK {
+					t.Fatalf("Expected ok status , got %d", w.Code)
+				}
+				var summary managementsystem.DepartmentSummary
+				if err := json.NewDecoder(w.Body).Decode(&summary); err != nil {
+					t.Fatalf("failed to decode response: %v", err)
+				}
+				if summary.StudentCount != tt.students {
+					t.Fatalf("Expected %d students, got %d", tt.students, summary.StudentCount)
+				}
+				if summary.LecturerCount != tt.lecturers {
+					t.Fatalf("Expected %d lecturers, got %d", tt.lecturers, summary.LecturerCount)
+				}
+			} else {
+				if w.Code != http.StatusNotFound {
+					t.Fatalf("Expected not found status , got %d", w.Code)
+				}
+			}
+		})
+	}
+}
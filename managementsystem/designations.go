@@ -0,0 +1,246 @@
+package managementsystem
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"managementsystem/validation"
+)
+
+// Designation is a lecturer rank. A higher Rank is more senior.
+type Designation struct {
+	ID   int    `json:"id"`
+	Name string `json:"name" validate:"trimmed,required,max=50"`
+	Rank int    `json:"rank" validate:"min=0"`
+}
+
+type Promotion struct {
+	ID              int     `json:"id"`
+	LecturerID      int     `json:"lecturer_id"`
+	FromDesignation *string `json:"from_designation"`
+	ToDesignation   string  `json:"to_designation" validate:"trimmed,required"`
+	EffectiveDate   string  `json:"effective_date"`
+}
+
+type PromotionHistory struct {
+	LecturerID  int         `json:"lecturer_id"`
+	Designation string      `json:"designation"`
+	Promotions  []Promotion `json:"promotions"`
+}
+
+type PromotionReportRow struct {
+	Year        int    `json:"year"`
+	Designation string `json:"designation"`
+	Promotions  int    `json:"promotions"`
+}
+
+// validation
+func ValidateDesignation(designation Designation) error {
+	return validation.Struct(designation)
+}
+
+var designationResource = &Resource[Designation]{
+	Name:        "designation",
+	Path:        "/designations",
+	Table:       "designations",
+	Key:         "id",
+	Columns:     []string{"name", "rank_order"},
+	CachePrefix: "designation:",
+	CacheTTL:    10 * time.Minute,
+	Fields: func(d *Designation) []any {
+		return []any{&d.ID, &d.Name, &d.Rank}
+	},
+	Validate: func(d *Designation) error { return ValidateDesignation(*d) },
+}
+
+// resolveDesignation replaces name with the managed designation it matches
+// and returns its rank.
+func resolveDesignation(q querier, name *string) (int, error) {
+	var rank int
+	err := q.QueryRow("SELECT name, rank_order FROM designations WHERE name=?", strings.TrimSpace(*name)).Scan(name, &rank)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, newHTTPError(http.StatusBadRequest, "unknown designation %q", *name)
+	}
+	return rank, err
+}
+
+// recordDesignation adds a history entry, effective today, when the
+// lecturer's designation differs from the last one recorded. As with a
+// promotion, the new designation must rank above the last one.
+func recordDesignation(q querier, l *Lecturer) error {
+	var last string
+	var lastRank int
+	err := q.QueryRow("SELECT p.to_designation, d.rank_order FROM lecturer_promotions p JOIN designations d ON d.name=p.to_designation WHERE p.lecturer_id=? ORDER BY p.id DESC LIMIT 1", l.ID).Scan(&last, &lastRank)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	if err == nil && last == l.Designation {
+		return nil
+	}
+	var from *string
+	if err == nil {
+		rank, err := resolveDesignation(q, &l.Designation)
+		if err != nil {
+			return err
+		}
+		if rank <= lastRank {
+			return newHTTPError(http.StatusBadRequest, "a promotion must be to a higher designation than %s", last)
+		}
+		from = &last
+	}
+	_, err = q.Exec("INSERT INTO lecturer_promotions (lecturer_id, from_designation, to_designation, effective_date) VALUES (?, ?, ?, CURDATE())", l.ID, from, l.Designation)
+	return err
+}
+
+// Promote lecturer
+func (h *HybridHandler5) PromoteLecturerHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := lecturerResource.id(w, r)
+	if !ok {
+		return
+	}
+	var promotion Promotion
+	if err := json.NewDecoder(r.Body).Decode(&promotion); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := validation.Struct(promotion); err != nil {
+		writeValidationError(w, r, err)
+		return
+	}
+	if promotion.EffectiveDate == "" {
+		promotion.EffectiveDate = time.Now().Format(time.DateOnly)
+	}
+	if _, err := time.Parse(time.DateOnly, promotion.EffectiveDate); err != nil {
+		http.Error(w, "effective_date must be YYYY-MM-DD", http.StatusBadRequest)
+		return
+	}
+
+	tx, err := h.MySQL.DB.Begin()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	var current string
+	var currentRank int
+	err = tx.QueryRow("SELECT l.designation, d.rank_order FROM lecturers l JOIN designations d ON d.name=l.designation WHERE l.id=? FOR UPDATE", id).Scan(&current, &currentRank)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "lecturer not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	rank, err := resolveDesignation(tx, &promotion.ToDesignation)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if rank <= currentRank {
+		http.Error(w, "a promotion must be to a higher designation than "+current, http.StatusBadRequest)
+		return
+	}
+	res, err := tx.Exec("INSERT INTO lecturer_promotions (lecturer_id, from_designation, to_designation, effective_date) VALUES (?, ?, ?, ?)", id, current, promotion.ToDesignation, promotion.EffectiveDate)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+	if _, err := tx.Exec("UPDATE lecturers SET designation=? WHERE id=?", promotion.ToDesignation, id); err != nil {
+		writeDBError(w, err)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	h.cacheDel(lecturerResource.cacheKey(id))
+
+	promotionID, _ := res.LastInsertId()
+	promotion.ID = int(promotionID)
+	promotion.LecturerID = id
+	promotion.FromDesignation = &current
+	writeJSON(w, http.StatusCreated, promotion)
+}
+
+// Lecturer designation history
+func (h *HybridHandler5) LecturerHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := lecturerResource.id(w, r)
+	if !ok {
+		return
+	}
+	lecturer, err := lecturerResource.load(h, id)
+	if err != nil {
+		lecturerResource.writeLoadError(w, err)
+		return
+	}
+	rows, err := h.MySQL.DB.Query("SELECT id, lecturer_id, from_designation, to_designation, DATE_FORMAT(effective_date, '%Y-%m-%d') FROM lecturer_promotions WHERE lecturer_id=? ORDER BY effective_date, id", id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	history := PromotionHistory{LecturerID: id, Designation: lecturer.Designation, Promotions: []Promotion{}}
+	for rows.Next() {
+		var p Promotion
+		if err := rows.Scan(&p.ID, &p.LecturerID, &p.FromDesignation, &p.ToDesignation, &p.EffectiveDate); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		history.Promotions = append(history.Promotions, p)
+	}
+	if err := rows.Err(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, history)
+}
+
+// Promotions per year, optionally for a single ?year=
+func (h *HybridHandler5) PromotionReportHandler(w http.ResponseWriter, r *http.Request) {
+	query := `SELECT YEAR(p.effective_date), p.to_designation, COUNT(*)
+		FROM lecturer_promotions p
+		JOIN designations f ON f.name = p.from_designation
+		JOIN designations t ON t.name = p.to_designation
+		WHERE t.rank_order > f.rank_order`
+	var args []any
+	if year := r.URL.Query().Get("year"); year != "" {
+		y, err := strconv.Atoi(year)
+		if err != nil {
+			http.Error(w, "invalid year", http.StatusBadRequest)
+			return
+		}
+		query += " AND YEAR(p.effective_date)=?"
+		args = append(args, y)
+	}
+	query += " GROUP BY YEAR(p.effective_date), p.to_designation, t.rank_order ORDER BY 1, t.rank_order"
+
+	rows, err := h.MySQL.DB.Query(query, args...)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	report := []PromotionReportRow{}
+	for rows.Next() {
+		var row PromotionReportRow
+		if err := rows.Scan(&row.Year, &row.Designation, &row.Promotions); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		report = append(report, row)
+	}
+	if err := rows.Err(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, report)
+}
@@ -0,0 +1,147 @@
+package managementsystem_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log"
+	managementsystem "managementsystem/managementsystem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"testing"
+)
+
+func TestHybridHandler5_PromoteLecturerHandler(t *testing.T) {
+
+	os.Setenv("REDIS_ADDR", "localhost:6379")
+	os.Setenv("MySQL_DSN", "root:root@tcp(127.0.0.1:3306)/management_sys")
+
+	redisInstance, err := managementsystem.ConnectRedis()
+	if err != nil {
+		panic(err)
+	}
+	mysqlinstance, err := managementsystem.ConnectMySQL()
+	if err != nil {
+		panic(err)
+	}
+	handler := &managementsystem.HybridHandler5{MySQL: mysqlinstance, Redis: redisInstance, Ctx: context.Background()}
+	router := handler.Router()
+
+	mysqlinstance.DB.Exec("DELETE FROM lecturers")
+	mysqlinstance.DB.Exec("ALTER TABLE lecturers AUTO_INCREMENT=1")
+	redisInstance.Client.FlushAll(context.Background())
+
+	body, _ := json.Marshal(managementsystem.Lecturer{Name: "ramesh", Email: "ramesh@gmail.com", Dept: "CSE", Designation: "assistant professor"})
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/lecturers", bytes.NewBuffer(body)))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected created status , got %d: %s", w.Code, w.Body.String())
+	}
+	var lecturer managementsystem.Lecturer
+	json.NewDecoder(w.Body).Decode(&lecturer)
+	if lecturer.Designation != "Assistant Professor" {
+		t.Fatalf("Expected designation Assistant Professor, got %s", lecturer.Designation)
+	}
+
+	tests := []struct {
+		name      string // description of this test case
+		promotion managementsystem.Promotion
+		status    int
+	}{
+		{
+			name:      "valid promotion",
+			promotion: managementsystem.Promotion{ToDesignation: "Associate Professor", EffectiveDate: "2025-07-01"},
+			status:    http.StatusCreated,
+		},
+		{
+			name:      "demotion is refused",
+			promotion: managementsystem.Promotion{ToDesignation: "Lecturer"},
+			status:    http.StatusBadRequest,
+		},
+		{
+			name:      "unknown designation",
+			promotion: managementsystem.Promotion{ToDesignation: "Dean of Everything"},
+			status:    http.StatusBadRequest,
+		},
+		{
+			name:      "invalid effective date",
+			promotion: managementsystem.Promotion{ToDesignation: "Professor", EffectiveDate: "01/07/2025"},
+			status:    http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			userBytes, err := json.Marshal(tt.promotion)
+			if err != nil {
+				log.Panic(err)
+			}
+			r := httptest.NewRequest(http.MethodPost, "/lecturers/1/promotions", bytes.NewBuffer(userBytes))
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, r)
+
+			if w.Code != tt.status {
+				t.Fatalf("Expected status %d, got %d: %s", tt.status, w.Code, w.Body.String())
+			}
+		})
+	}
+
+	// an update cannot demote past the promotion rules either
+	lecturer.Designation = "Lecturer"
+	body, _ = json.Marshal(lecturer)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/lecturers/1", bytes.NewBuffer(body)))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Expected bad request status , got %d: %s", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/lecturers/1/history", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected ok status , got %d", w.Code)
+	}
+	var history managementsystem.PromotionHistory
+	if err := json.NewDecoder(w.Body).Decode(&history); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if history.Designation != "Associate Professor" {
+		t.Fatalf("Expected designation Associate Professor, got %s", history.Designation)
+	}
+	if len(history.Promotions) != 2 {
+		t.Fatalf("Expected 2 history entries, got %d", len(history.Promotions))
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/reports/promotions?year=2025", nil))
+	var report []managementsystem.PromotionReportRow
+	if err := json.NewDecoder(w.Body).Decode(&report); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(report) != 1 || report[0].Designation != "Associate Professor" || report[0].Promotions != 1 {
+		t.Fatalf("Expected one promotion to Associate Professor in 2025, got %+v", report)
+	}
+
+	// a renamed designation keeps its history
+	var associate managementsystem.Designation
+	mysqlinstance.DB.QueryRow("SELECT id, name, rank_order FROM designations WHERE name='Associate Professor'").Scan(&associate.ID, &associate.Name, &associate.Rank)
+	rename := func(name string) {
+		body, _ := json.Marshal(managementsystem.Designation{Name: name, Rank: associate.Rank})
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/designations/"+strconv.Itoa(associate.ID), bytes.NewBuffer(body)))
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected ok status , got %d: %s", w.Code, w.Body.String())
+		}
+	}
+	rename("Assoc. Professor")
+	defer rename("Associate Professor")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/reports/promotions?year=2025", nil))
+	report = nil
+	if err := json.NewDecoder(w.Body).Decode(&report); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(report) != 1 || report[0].Designation != "Assoc. Professor" {
+		t.Fatalf("Expected the promotion under the new name, got %+v", report)
+	}
+}
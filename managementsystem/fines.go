@@ -0,0 +1,237 @@
+package managementsystem
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"managementsystem/validation"
+)
+
+// Fine kinds and statuses. Amounts are in cents.
+const (
+	fineOverdue = "overdue"
+	fineLost    = "lost"   // replacement of a lost book
+	fineDamage  = "damage" // repair of a book returned damaged
+
+	fineUnpaid = "unpaid"
+	finePaid   = "paid"
+	fineWaived = "waived"
+)
+
+type Fine struct {
+	ID        int    `json:"id"`
+	BorrowID  *int   `json:"borrow_id"`
+	UserID    int    `json:"user_id"`
+	UserType  string `json:"user_type"`
+	Kind      string `json:"kind"`
+	Amount    int    `json:"amount"`
+	Status    string `json:"status"`
+	Note      string `json:"note,omitempty"`
+	CreatedAt string `json:"created_at"`
+}
+
+// FineStatement is what a user owes: recorded fines, and fines still
+// accruing on overdue books that have not been returned.
+type FineStatement struct {
+	UserType    string `json:"user_type"`
+	UserID      int    `json:"user_id"`
+	Fines       []Fine `json:"fines"`
+	Accruing    []Fine `json:"accruing"`
+	Outstanding int    `json:"outstanding"`
+}
+
+const fineSelect = "SELECT id, borrow_id, user_id, user_type, kind, amount, status, IFNULL(note, ''), DATE_FORMAT(created_at, '%Y-%m-%d %H:%i:%s') FROM fines"
+
+func scanFine(row interface{ Scan(...any) error }, f *Fine) error {
+	return row.Scan(&f.ID, &f.BorrowID, &f.UserID, &f.UserType, &f.Kind, &f.Amount, &f.Status, &f.Note, &f.CreatedAt)
+}
+
+// chargeOverdue records the fine for a loan returned daysOverdue days late,
+// or for a short loan hoursOverdue hours late, if there is one.
+func chargeOverdue(tx querier, record *Borrow_records, daysOverdue, hoursOverdue int) (*Fine, error) {
+	policy, err := loanPolicy(tx, record.User_type)
+	if err != nil {
+		return nil, err
+	}
+	amount := policy.OverdueFine(daysOverdue)
+	if record.Due_at != nil {
+		amount = policy.ShortLoanFine(hoursOverdue)
+	}
+	if amount == 0 {
+		return nil, nil
+	}
+	return chargeFine(tx, record, fineOverdue, amount, "")
+}
+
+// chargeFine records an unpaid fine of a kind for a loan.
+func chargeFine(tx querier, record *Borrow_records, kind string, amount int, note string) (*Fine, error) {
+	res, err := tx.Exec("INSERT INTO fines (borrow_id, user_id, user_type, kind, amount, status, note) VALUES (?, ?, ?, ?, ?, ?, NULLIF(?, ''))", record.Borrow_id, record.User_id, record.User_type, kind, amount, fineUnpaid, note)
+	if err != nil {
+		return nil, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	var fine Fine
+	if err := scanFine(tx.QueryRow(fineSelect+" WHERE id=?", id), &fine); err != nil {
+		return nil, err
+	}
+	return &fine, nil
+}
+
+// userPath reads the {type} and {id} of a /users/{type}/{id} route.
+func userPath(w http.ResponseWriter, r *http.Request) (string, int, bool) {
+	vars := mux.Vars(r)
+	userType := vars["type"]
+	if userType != "student" && userType != "lecturer" {
+		http.Error(w, "user type must be student or lecturer", http.StatusBadRequest)
+		return "", 0, false
+	}
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "invalid user id", http.StatusBadRequest)
+		return "", 0, false
+	}
+	return userType, id, true
+}
+
+// Fines of a user
+func (h *HybridHandler5) UserFinesHandler(w http.ResponseWriter, r *http.Request) {
+	userType, userID, ok := userPath(w, r)
+	if !ok {
+		return
+	}
+	statement := FineStatement{UserType: userType, UserID: userID, Fines: []Fine{}, Accruing: []Fine{}}
+
+	rows, err := h.MySQL.DB.Query(fineSelect+" WHERE user_type=? AND user_id=? ORDER BY id", userType, userID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var fine Fine
+		if err := scanFine(rows, &fine); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if fine.Status == fineUnpaid {
+			statement.Outstanding += fine.Amount
+		}
+		statement.Fines = append(statement.Fines, fine)
+	}
+	if err := rows.Err(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	policy, err := loanPolicy(h.MySQL.DB, userType)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	calendar, err := loadCalendar(h.MySQL.DB)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	overdue, err := h.MySQL.DB.Query("SELECT borrow_id, DATE_FORMAT(due_date, '%Y-%m-%d'), DATE_FORMAT(due_at, '%Y-%m-%d %H:%i:%s') FROM borrow_records WHERE user_type=? AND user_id=? AND return_date IS NULL AND IFNULL(due_at < NOW(), due_date < CURDATE()) ORDER BY borrow_id", userType, userID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer overdue.Close()
+	for overdue.Next() {
+		var borrowID int
+		var due, dueAt *string
+		if err := overdue.Scan(&borrowID, &due, &dueAt); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		days, err := calendar.overdueDays(due)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		fine := Fine{BorrowID: &borrowID, UserID: userID, UserType: userType, Kind: fineOverdue, Amount: policy.OverdueFine(days), Status: fineUnpaid}
+		if dueAt != nil {
+			hours, err := calendar.overdueHours(dueAt)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			fine.Amount = policy.ShortLoanFine(hours)
+		}
+		statement.Outstanding += fine.Amount
+		statement.Accruing = append(statement.Accruing, fine)
+	}
+	if err := overdue.Err(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, statement)
+}
+
+// Pay a fine
+func (h *HybridHandler5) PayFineHandler(w http.ResponseWriter, r *http.Request) {
+	h.settleFine(w, r, finePaid, "")
+}
+
+// Waive a fine
+func (h *HybridHandler5) WaiveFineHandler(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Reason string `json:"reason" validate:"trimmed,required,max=255"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := validation.Struct(body); err != nil {
+		writeValidationError(w, r, err)
+		return
+	}
+	h.settleFine(w, r, fineWaived, body.Reason)
+}
+
+// settleFine moves an unpaid fine to status, keeping note if there is one.
+func (h *HybridHandler5) settleFine(w http.ResponseWriter, r *http.Request, status, note string) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "invalid fine id", http.StatusBadRequest)
+		return
+	}
+	tx, err := h.MySQL.DB.Begin()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	var fine Fine
+	err = scanFine(tx.QueryRow(fineSelect+" WHERE id=? FOR UPDATE", id), &fine)
+	if err != nil {
+		writeLoadError(w, "fine", err)
+		return
+	}
+	if fine.Status != fineUnpaid {
+		http.Error(w, "fine is already "+fine.Status, http.StatusConflict)
+		return
+	}
+	if note == "" {
+		note = fine.Note
+	}
+	if _, err := tx.Exec("UPDATE fines SET status=?, note=NULLIF(?, ''), settled_at=NOW() WHERE id=?", status, note, id); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	fine.Status = status
+	fine.Note = note
+	writeJSON(w, http.StatusOK, fine)
+}
@@ -0,0 +1,186 @@
+package managementsystem_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log"
+	managementsystem "managementsystem/managementsystem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"testing"
+)
+
+func TestLoanPolicy_OverdueFine(t *testing.T) {
+	policy := managementsystem.LoanPolicy{UserType: "student", LoanDays: 14, FinePerDay: 50, MaxFine: 2000}
+	tests := []struct {
+		name string // description of this test case
+		days int
+		want int
+	}{
+		{name: "returned early", days: -3, want: 0},
+		{name: "returned on the due date", days: 0, want: 0},
+		{name: "one day late", days: 1, want: 50},
+		{name: "ten days late", days: 10, want: 500},
+		{name: "capped", days: 100, want: 2000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := policy.OverdueFine(tt.days); got != tt.want {
+				t.Fatalf("Expected fine %d, got %d", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestLoanPolicy_ShortLoanFine(t *testing.T) {
+	policy := managementsystem.LoanPolicy{UserType: "student", ShortLoanHours: 4, ShortFinePerHour: 100, MaxFine: 2000}
+	tests := []struct {
+		name  string // description of this test case
+		hours int
+		want  int
+	}{
+		{name: "returned on time", hours: 0, want: 0},
+		{name: "one hour late", hours: 1, want: 100},
+		{name: "a day late", hours: 24, want: 2000},
+		{name: "capped", hours: 100, want: 2000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := policy.ShortLoanFine(tt.hours); got != tt.want {
+				t.Fatalf("Expected fine %d, got %d", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestHybridHandler5_Fines(t *testing.T) {
+
+	os.Setenv("REDIS_ADDR", "localhost:6379")
+	os.Setenv("MySQL_DSN", "root:root@tcp(127.0.0.1:3306)/management_sys")
+
+	redisInstance, err := managementsystem.ConnectRedis()
+	if err != nil {
+		panic(err)
+	}
+	mysqlinstance, err := managementsystem.ConnectMySQL()
+	if err != nil {
+		panic(err)
+	}
+	handler := &managementsystem.HybridHandler5{MySQL: mysqlinstance, Redis: redisInstance, Ctx: context.Background()}
+	router := handler.Router()
+
+	mysqlinstance.DB.Exec("DELETE FROM fines")
+	mysqlinstance.DB.Exec("DELETE FROM borrow_records")
+	mysqlinstance.DB.Exec("DELETE FROM book_copies")
+	mysqlinstance.DB.Exec("DELETE FROM books")
+	redisInstance.Client.FlushAll(context.Background())
+
+	res, err := mysqlinstance.DB.Exec("INSERT INTO books(title, author) VALUES (?, ?)", "GoLang", "Alice")
+	if err != nil {
+		log.Panic(err)
+	}
+	book_id, _ := res.LastInsertId()
+	res, err = mysqlinstance.DB.Exec("INSERT INTO book_copies(book_id, branch_id, barcode, status) VALUES (?, (SELECT id FROM branches WHERE code='MAIN'), ?, ?), (?, (SELECT id FROM branches WHERE code='MAIN'), ?, ?)", book_id, "GO-1", "borrowed", book_id, "GO-2", "borrowed")
+	if err != nil {
+		log.Panic(err)
+	}
+	copy_id, _ := res.LastInsertId()
+
+	// one loan six days overdue, one still overdue and not returned
+	_, err = mysqlinstance.DB.Exec("INSERT INTO borrow_records(user_id, user_type, book_id, copy_id, borrow_date, due_date) VALUES (?, ?, ?, ?, CURDATE() - INTERVAL 20 DAY, CURDATE() - INTERVAL 6 DAY), (?, ?, ?, ?, CURDATE() - INTERVAL 16 DAY, CURDATE() - INTERVAL 2 DAY)", 101, "student", book_id, copy_id, 101, "student", book_id, copy_id+1)
+	if err != nil {
+		t.Fatalf("insert borrow fail: %v", err)
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/return", bytes.NewBufferString(`{"user_id":101,"user_type":"student","barcode":"GO-1"}`)))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected created status , got %d: %s", w.Code, w.Body.String())
+	}
+	var returned struct {
+		Fine *managementsystem.Fine `json:"fine"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&returned); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if returned.Fine == nil || returned.Fine.Amount != 300 {
+		t.Fatalf("Expected a fine of 300, got %+v", returned.Fine)
+	}
+	finePath := "/fines/" + strconv.Itoa(returned.Fine.ID)
+
+	tests := []struct {
+		name        string // description of this test case
+		method      string
+		path        string
+		body        string
+		status      int
+		outstanding int // outstanding amount of the student afterwards
+	}{
+		{
+			name:        "statement",
+			method:      http.MethodGet,
+			path:        "/users/student/101/fines",
+			status:      http.StatusOK,
+			outstanding: 400,
+		},
+		{
+			name:        "waive without reason",
+			method:      http.MethodPost,
+			path:        finePath + "/waive",
+			body:        `{"reason":" "}`,
+			status:      http.StatusBadRequest,
+			outstanding: 400,
+		},
+		{
+			name:        "pay",
+			method:      http.MethodPost,
+			path:        finePath + "/pay",
+			status:      http.StatusOK,
+			outstanding: 100,
+		},
+		{
+			name:        "pay twice",
+			method:      http.MethodPost,
+			path:        finePath + "/pay",
+			status:      http.StatusConflict,
+			outstanding: 100,
+		},
+		{
+			name:        "unknown fine",
+			method:      http.MethodPost,
+			path:        "/fines/98765/waive",
+			body:        `{"reason":"lost in the post"}`,
+			status:      http.StatusNotFound,
+			outstanding: 100,
+		},
+		{
+			name:        "invalid user type",
+			method:      http.MethodGet,
+			path:        "/users/staff/101/fines",
+			status:      http.StatusBadRequest,
+			outstanding: 100,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, bytes.NewBufferString(tt.body)))
+			if w.Code != tt.status {
+				t.Fatalf("Expected status %d, got %d: %s", tt.status, w.Code, w.Body.String())
+			}
+
+			w = httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users/student/101/fines", nil))
+			var statement managementsystem.FineStatement
+			if err := json.NewDecoder(w.Body).Decode(&statement); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if statement.Outstanding != tt.outstanding {
+				t.Fatalf("Expected outstanding %d, got %d", tt.outstanding, statement.Outstanding)
+			}
+		})
+	}
+}
@@ -0,0 +1,301 @@
+package managementsystem
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"managementsystem/validation"
+)
+
+// Hold statuses. A waiting hold is in the queue for its book; a ready hold
+// has a copy set aside until it expires.
+const (
+	holdWaiting   = "waiting"
+	holdReady     = "ready"
+	holdFulfilled = "fulfilled"
+	holdCancelled = "cancelled"
+	holdExpired   = "expired"
+)
+
+type Hold struct {
+	ID        int     `json:"id"`
+	BookID    int     `json:"book_id"`
+	UserID    int     `json:"user_id" validate:"min=1"`
+	UserType  string  `json:"user_type" validate:"enum=student|lecturer"`
+	Status    string  `json:"status"`
+	CopyID    *int    `json:"copy_id"`
+	Position  int     `json:"position,omitempty"` // place in the queue while waiting
+	CreatedAt string  `json:"created_at"`
+	ExpiresAt *string `json:"expires_at"`
+}
+
+const holdSelect = "SELECT id, book_id, user_id, user_type, status, copy_id, DATE_FORMAT(created_at, '%Y-%m-%d %H:%i:%s'), DATE_FORMAT(expires_at, '%Y-%m-%d %H:%i:%s') FROM holds"
+
+func scanHold(row interface{ Scan(...any) error }, hold *Hold) error {
+	return row.Scan(&hold.ID, &hold.BookID, &hold.UserID, &hold.UserType, &hold.Status, &hold.CopyID, &hold.CreatedAt, &hold.ExpiresAt)
+}
+
+// loadHold reads a hold and its place in the queue, locking it when q is a
+// transaction.
+func loadHold(q querier, id int) (*Hold, error) {
+	var hold Hold
+	if err := scanHold(q.QueryRow(holdSelect+" WHERE id=? FOR UPDATE", id), &hold); err != nil {
+		return nil, err
+	}
+	if hold.Status == holdWaiting {
+		err := q.QueryRow("SELECT COUNT(*) + 1 FROM holds WHERE book_id=? AND status=? AND id<?", hold.BookID, holdWaiting, hold.ID).Scan(&hold.Position)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &hold, nil
+}
+
+// refreshHolds expires ready holds nobody picked up in time, then sets
+// available copies of the book aside for waiting holds, oldest first.
+// Copies on course reserve are never set aside.
+func refreshHolds(tx querier, bookID int) error {
+	_, err := tx.Exec("UPDATE holds h JOIN book_copies c ON c.copy_id = h.copy_id SET h.status=?, c.status=? WHERE h.book_id=? AND h.status=? AND h.expires_at < NOW()", holdExpired, copyAvailable, bookID, holdReady)
+	if err != nil {
+		return err
+	}
+	for {
+		var holdID int
+		var userType string
+		err := tx.QueryRow("SELECT id, user_type FROM holds WHERE book_id=? AND status=? ORDER BY id LIMIT 1 FOR UPDATE", bookID, holdWaiting).Scan(&holdID, &userType)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		var copyID int
+		err = tx.QueryRow("SELECT copy_id FROM book_copies WHERE book_id=? AND status=? AND reserve_id IS NULL ORDER BY copy_id LIMIT 1 FOR UPDATE", bookID, copyAvailable).Scan(&copyID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		policy, err := loanPolicy(tx, userType)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec("UPDATE book_copies SET status=? WHERE copy_id=?", copyOnHold, copyID); err != nil {
+			return err
+		}
+		if _, err := tx.Exec("UPDATE holds SET status=?, copy_id=?, ready_at=NOW(), expires_at=NOW() + INTERVAL ? DAY WHERE id=?", holdReady, copyID, policy.PickupDays, holdID); err != nil {
+			return err
+		}
+	}
+}
+
+// Place a hold on a book
+func (h *HybridHandler5) PlaceHoldHandler(w http.ResponseWriter, r *http.Request) {
+	bookID, ok := bookResource.id(w, r)
+	if !ok {
+		return
+	}
+	var hold Hold
+	if err := json.NewDecoder(r.Body).Decode(&hold); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := validation.Struct(hold); err != nil {
+		writeValidationError(w, r, err)
+		return
+	}
+	tx, err := h.MySQL.DB.Begin()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	// locking the book keeps its queue in order
+	var id int
+	if err := tx.QueryRow("SELECT book_id FROM books WHERE book_id=? FOR UPDATE", bookID).Scan(&id); err != nil {
+		bookResource.writeLoadError(w, err)
+		return
+	}
+	borrower, err := loadBorrower(tx, hold.UserType, hold.UserID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !borrower.Exists {
+		http.Error(w, "no such "+hold.UserType, http.StatusNotFound)
+		return
+	}
+	var holding bool
+	err = tx.QueryRow("SELECT EXISTS(SELECT 1 FROM holds WHERE book_id=? AND user_id=? AND user_type=? AND status IN (?, ?))", bookID, hold.UserID, hold.UserType, holdWaiting, holdReady).Scan(&holding)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if holding {
+		http.Error(w, "already holding this book", http.StatusConflict)
+		return
+	}
+	res, err := tx.Exec("INSERT INTO holds (book_id, user_id, user_type, status) VALUES (?, ?, ?, ?)", bookID, hold.UserID, hold.UserType, holdWaiting)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+	holdID, err := res.LastInsertId()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := refreshHolds(tx, bookID); err != nil {
+		writeError(w, err)
+		return
+	}
+	placed, err := loadHold(tx, int(holdID))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	h.cacheDel(bookResource.cacheKey(bookID))
+	writeJSON(w, http.StatusCreated, placed)
+}
+
+// Hold queue of a book
+func (h *HybridHandler5) BookHoldsHandler(w http.ResponseWriter, r *http.Request) {
+	bookID, ok := bookResource.id(w, r)
+	if !ok {
+		return
+	}
+	if _, err := bookResource.load(h, bookID); err != nil {
+		bookResource.writeLoadError(w, err)
+		return
+	}
+	if err := h.refreshHolds(bookID); err != nil {
+		writeError(w, err)
+		return
+	}
+	rows, err := h.MySQL.DB.Query(holdSelect+" WHERE book_id=? AND status IN (?, ?) ORDER BY status='waiting', id", bookID, holdReady, holdWaiting)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	holds := []Hold{}
+	position := 0
+	for rows.Next() {
+		var hold Hold
+		if err := scanHold(rows, &hold); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if hold.Status == holdWaiting {
+			position++
+			hold.Position = position
+		}
+		holds = append(holds, hold)
+	}
+	if err := rows.Err(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, holds)
+}
+
+// Get hold with its queue position
+func (h *HybridHandler5) GetHoldHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "invalid hold id", http.StatusBadRequest)
+		return
+	}
+	var bookID int
+	if err := h.MySQL.DB.QueryRow("SELECT book_id FROM holds WHERE id=?", id).Scan(&bookID); err != nil {
+		writeLoadError(w, "hold", err)
+		return
+	}
+	if err := h.refreshHolds(bookID); err != nil {
+		writeError(w, err)
+		return
+	}
+	hold, err := loadHold(h.MySQL.DB, id)
+	if err != nil {
+		writeLoadError(w, "hold", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, hold)
+}
+
+// Cancel hold
+func (h *HybridHandler5) CancelHoldHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "invalid hold id", http.StatusBadRequest)
+		return
+	}
+	tx, err := h.MySQL.DB.Begin()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	hold, err := loadHold(tx, id)
+	if err != nil {
+		writeLoadError(w, "hold", err)
+		return
+	}
+	if hold.Status != holdWaiting && hold.Status != holdReady {
+		http.Error(w, "hold is already "+hold.Status, http.StatusConflict)
+		return
+	}
+	if _, err := tx.Exec("UPDATE holds SET status=? WHERE id=?", holdCancelled, id); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	// a copy set aside goes to the next in the queue
+	if hold.Status == holdReady && hold.CopyID != nil {
+		if _, err := tx.Exec("UPDATE book_copies SET status=? WHERE copy_id=? AND status=?", copyAvailable, *hold.CopyID, copyOnHold); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	if err := refreshHolds(tx, hold.BookID); err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	h.cacheDel(bookResource.cacheKey(hold.BookID))
+	hold.Status = holdCancelled
+	hold.Position = 0
+	writeJSON(w, http.StatusOK, hold)
+}
+
+// refreshHolds runs refreshHolds for a book in a transaction of its own.
+func (h *HybridHandler5) refreshHolds(bookID int) error {
+	tx, err := h.MySQL.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := refreshHolds(tx, bookID); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	h.cacheDel(bookResource.cacheKey(bookID))
+	return nil
+}
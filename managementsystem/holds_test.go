@@ -0,0 +1,168 @@
+package managementsystem_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	managementsystem "managementsystem/managementsystem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"testing"
+)
+
+func TestHybridHandler5_Holds(t *testing.T) {
+
+	os.Setenv("REDIS_ADDR", "localhost:6379")
+	os.Setenv("MySQL_DSN", "root:root@tcp(127.0.0.1:3306)/management_sys")
+
+	redisInstance, err := managementsystem.ConnectRedis()
+	if err != nil {
+		panic(err)
+	}
+	mysqlinstance, err := managementsystem.ConnectMySQL()
+	if err != nil {
+		panic(err)
+	}
+	handler := &managementsystem.HybridHandler5{MySQL: mysqlinstance, Redis: redisInstance, Ctx: context.Background()}
+	router := handler.Router()
+
+	mysqlinstance.DB.Exec("DELETE FROM holds")
+	mysqlinstance.DB.Exec("DELETE FROM fines")
+	mysqlinstance.DB.Exec("DELETE FROM borrow_records")
+	mysqlinstance.DB.Exec("DELETE FROM book_copies")
+	mysqlinstance.DB.Exec("DELETE FROM books")
+	redisInstance.Client.FlushAll(context.Background())
+
+	for _, id := range []int{301, 302, 303} {
+		mysqlinstance.DB.Exec("INSERT IGNORE INTO students (id, name, email, age, year) VALUES (?, ?, ?, ?, ?)", id, "reader", "reader"+strconv.Itoa(id)+"@gmail.com", 20, 1)
+	}
+
+	// one copy, already out with 301
+	res, err := mysqlinstance.DB.Exec("INSERT INTO books(title, author) VALUES (?, ?)", "GoLang", "Alice")
+	if err != nil {
+		t.Fatalf("insert book fail: %v", err)
+	}
+	book_id, _ := res.LastInsertId()
+	bookPath := "/books/" + strconv.FormatInt(book_id, 10)
+	body, _ := json.Marshal(managementsystem.BookCopy{Barcode: "GO-1"})
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, bookPath+"/copies", bytes.NewBuffer(body)))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected created status , got %d: %s", w.Code, w.Body.String())
+	}
+	borrow := func(userID int) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(managementsystem.Borrow_records{User_id: userID, User_type: "student", Book_id: int(book_id)})
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/borrow", bytes.NewBuffer(body)))
+		return w
+	}
+	if w := borrow(301); w.Code != http.StatusCreated {
+		t.Fatalf("Expected created status , got %d: %s", w.Code, w.Body.String())
+	}
+
+	holdIDs := map[int]int{}
+	tests := []struct {
+		name     string // description of this test case
+		run      func() *httptest.ResponseRecorder
+		status   int
+		holder   int    // user whose hold is checked afterwards
+		holdWant string // status of that hold
+		position int
+	}{
+		{
+			name: "302 joins the queue",
+			run: func() *httptest.ResponseRecorder {
+				return placeHold(router, bookPath, 302)
+			},
+			status: http.StatusCreated, holder: 302, holdWant: "waiting", position: 1,
+		},
+		{
+			name: "303 queues behind 302",
+			run: func() *httptest.ResponseRecorder {
+				return placeHold(router, bookPath, 303)
+			},
+			status: http.StatusCreated, holder: 303, holdWant: "waiting", position: 2,
+		},
+		{
+			name: "303 cannot hold twice",
+			run: func() *httptest.ResponseRecorder {
+				return placeHold(router, bookPath, 303)
+			},
+			status: http.StatusConflict, holder: 303, holdWant: "waiting", position: 2,
+		},
+		{
+			name: "return sets the copy aside for 302",
+			run: func() *httptest.ResponseRecorder {
+				w := httptest.NewRecorder()
+				router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/return", bytes.NewBufferString(`{"user_id":301,"user_type":"student","barcode":"GO-1"}`)))
+				return w
+			},
+			status: http.StatusCreated, holder: 302, holdWant: "ready",
+		},
+		{
+			name: "walk-in cannot take the held copy",
+			run: func() *httptest.ResponseRecorder {
+				return borrow(303)
+			},
+			status: http.StatusBadRequest, holder: 303, holdWant: "waiting", position: 1,
+		},
+		{
+			name: "302 picks up the held copy",
+			run: func() *httptest.ResponseRecorder {
+				return borrow(302)
+			},
+			status: http.StatusCreated, holder: 302, holdWant: "fulfilled",
+		},
+		{
+			name: "303 cancels",
+			run: func() *httptest.ResponseRecorder {
+				w := httptest.NewRecorder()
+				router.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/holds/"+strconv.Itoa(holdIDs[303]), nil))
+				return w
+			},
+			status: http.StatusOK, holder: 303, holdWant: "cancelled",
+		},
+		{
+			name: "303 cannot cancel twice",
+			run: func() *httptest.ResponseRecorder {
+				w := httptest.NewRecorder()
+				router.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/holds/"+strconv.Itoa(holdIDs[303]), nil))
+				return w
+			},
+			status: http.StatusConflict, holder: 303, holdWant: "cancelled",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := tt.run()
+			if w.Code != tt.status {
+				t.Fatalf("Expected status %d, got %d: %s", tt.status, w.Code, w.Body.String())
+			}
+			if w.Code == http.StatusCreated && w.Body.Len() > 0 {
+				var hold managementsystem.Hold
+				if json.Unmarshal(w.Body.Bytes(), &hold) == nil && hold.ID > 0 && hold.UserID > 0 {
+					holdIDs[hold.UserID] = hold.ID
+				}
+			}
+
+			w = httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/holds/"+strconv.Itoa(holdIDs[tt.holder]), nil))
+			var hold managementsystem.Hold
+			if err := json.NewDecoder(w.Body).Decode(&hold); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if hold.Status != tt.holdWant || hold.Position != tt.position {
+				t.Fatalf("Expected hold %s at %d, got %s at %d", tt.holdWant, tt.position, hold.Status, hold.Position)
+			}
+		})
+	}
+}
+
+func placeHold(router http.Handler, bookPath string, userID int) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(managementsystem.Hold{UserID: userID, UserType: "student"})
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, bookPath+"/holds", bytes.NewBuffer(body)))
+	return w
+}
@@ -0,0 +1,119 @@
+package managementsystem
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+
+	"managementsystem/validation"
+)
+
+func init() {
+	validation.RegisterRule("isbn10", func(value reflect.Value, _ string) bool {
+		return value.Kind() == reflect.String && ValidISBN10(value.String())
+	}, "{field} is not a valid ISBN-10")
+	validation.RegisterRule("isbn13", func(value reflect.Value, _ string) bool {
+		return value.Kind() == reflect.String && ValidISBN13(value.String())
+	}, "{field} is not a valid ISBN-13")
+}
+
+// NormalizeISBN drops the hyphens and spaces people type into ISBNs and
+// upper-cases a trailing x.
+func NormalizeISBN(isbn string) string {
+	isbn = strings.NewReplacer("-", "", " ", "").Replace(strings.TrimSpace(isbn))
+	return strings.ToUpper(isbn)
+}
+
+// ValidISBN10 reports whether isbn is ten characters with a correct
+// mod 11 check digit, which may be X.
+func ValidISBN10(isbn string) bool {
+	if len(isbn) != 10 {
+		return false
+	}
+	sum := 0
+	for i := 0; i < 10; i++ {
+		var d int
+		switch c := isbn[i]; {
+		case c >= '0' && c <= '9':
+			d = int(c - '0')
+		case c == 'X' && i == 9:
+			d = 10
+		default:
+			return false
+		}
+		sum += (10 - i) * d
+	}
+	return sum%11 == 0
+}
+
+// ValidISBN13 reports whether isbn is thirteen digits with a correct
+// mod 10 check digit.
+func ValidISBN13(isbn string) bool {
+	if len(isbn) != 13 || !digits(isbn) {
+		return false
+	}
+	return isbn13Check(isbn[:12]) == isbn[12]
+}
+
+// ISBN10To13 converts a valid ISBN-10 to its 978-prefixed ISBN-13.
+func ISBN10To13(isbn string) (string, error) {
+	if !ValidISBN10(isbn) {
+		return "", errors.New("invalid ISBN-10")
+	}
+	core := "978" + isbn[:9]
+	return core + string(isbn13Check(core)), nil
+}
+
+// ISBN13To10 converts a valid 978-prefixed ISBN-13 to ISBN-10. 979 numbers
+// have no ISBN-10.
+func ISBN13To10(isbn string) (string, error) {
+	if !ValidISBN13(isbn) {
+		return "", errors.New("invalid ISBN-13")
+	}
+	if !strings.HasPrefix(isbn, "978") {
+		return "", errors.New("only 978 ISBN-13s have an ISBN-10")
+	}
+	core := isbn[3:12]
+	sum := 0
+	for i := 0; i < 9; i++ {
+		sum += (10 - i) * int(core[i]-'0')
+	}
+	check := (11 - sum%11) % 11
+	if check == 10 {
+		return core + "X", nil
+	}
+	return core + string(rune('0'+check)), nil
+}
+
+// ISBNTo13 accepts either form and returns the ISBN-13.
+func ISBNTo13(isbn string) (string, error) {
+	isbn = NormalizeISBN(isbn)
+	if len(isbn) == 10 {
+		return ISBN10To13(isbn)
+	}
+	if !ValidISBN13(isbn) {
+		return "", errors.New("invalid ISBN")
+	}
+	return isbn, nil
+}
+
+func isbn13Check(first12 string) byte {
+	sum := 0
+	for i := 0; i < 12; i++ {
+		d := int(first12[i] - '0')
+		if i%2 == 1 {
+			d *= 3
+		}
+		sum += d
+	}
+	return byte('0' + (10-sum%10)%10)
+}
+
+func digits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
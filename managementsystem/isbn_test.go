@@ -0,0 +1,166 @@
+package managementsystem_test
+
+import (
+	"context"
+	"encoding/json"
+	managementsystem "managementsystem/managementsystem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestValidateLibraryISBN(t *testing.T) {
+	tests := []struct {
+		name     string // description of this test case
+		isbn10   string
+		isbn13   string
+		want10   string
+		want13   string
+		willpass bool
+	}{
+		{
+			name:     "isbn10 with hyphens fills isbn13",
+			isbn10:   "0-306-40615-2",
+			want10:   "0306406152",
+			want13:   "9780306406157",
+			willpass: true,
+		},
+		{
+			name:     "isbn10 with X check digit",
+			isbn10:   "080442957x",
+			want10:   "080442957X",
+			want13:   "9780804429573",
+			willpass: true,
+		},
+		{
+			name:     "isbn13 fills isbn10",
+			isbn13:   "978-0-306-40615-7",
+			want10:   "0306406152",
+			want13:   "9780306406157",
+			willpass: true,
+		},
+		{
+			name:     "979 isbn13 has no isbn10",
+			isbn13:   "9791234567896",
+			want13:   "9791234567896",
+			willpass: true,
+		},
+		{
+			name:     "no isbn",
+			willpass: true,
+		},
+		{
+			name:     "bad isbn10 checksum",
+			isbn10:   "0306406153",
+			willpass: false,
+		},
+		{
+			name:     "bad isbn13 checksum",
+			isbn13:   "9780306406158",
+			willpass: false,
+		},
+		{
+			name:     "isbn10 and isbn13 of different books",
+			isbn10:   "0306406152",
+			isbn13:   "9780804429573",
+			willpass: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			book := managementsystem.Book{Title: "comics", Author: "kunal", Available_copies: 1, ISBN10: tt.isbn10, ISBN13: tt.isbn13}
+			err := managementsystem.ValidateLibrary(&book)
+			if !tt.willpass {
+				if err == nil {
+					t.Fatalf("Expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if book.ISBN10 != tt.want10 || book.ISBN13 != tt.want13 {
+				t.Fatalf("Expected %q/%q, got %q/%q", tt.want10, tt.want13, book.ISBN10, book.ISBN13)
+			}
+		})
+	}
+}
+
+func TestHybridHandler5_GetBookByISBNHandler(t *testing.T) {
+
+	os.Setenv("REDIS_ADDR", "localhost:6379")
+	os.Setenv("MySQL_DSN", "root:root@tcp(127.0.0.1:3306)/management_sys")
+
+	redisInstance, err := managementsystem.ConnectRedis()
+	if err != nil {
+		panic(err)
+	}
+	mysqlinstance, err := managementsystem.ConnectMySQL()
+	if err != nil {
+		panic(err)
+	}
+	handler := &managementsystem.HybridHandler5{MySQL: mysqlinstance, Redis: redisInstance, Ctx: context.Background()}
+	router := handler.Router()
+
+	mysqlinstance.DB.Exec("DELETE FROM books")
+	mysqlinstance.DB.Exec("ALTER TABLE books AUTO_INCREMENT=1")
+	redisInstance.Client.FlushAll(context.Background())
+
+	_, err = mysqlinstance.DB.Exec("INSERT INTO books (title , author , isbn10 , isbn13) VALUES (?, ?, ?, ?)", "comics", "sujan", "0306406152", "9780306406157")
+	if err != nil {
+		t.Fatalf("insert fail: %v", err)
+	}
+	// the same author may now have a second book
+	_, err = mysqlinstance.DB.Exec("INSERT INTO books (title , author) VALUES (?, ?)", "more comics", "sujan")
+	if err != nil {
+		t.Fatalf("insert second book by the same author fail: %v", err)
+	}
+
+	tests := []struct {
+		name   string // description of this test case
+		isbn   string
+		status int
+	}{
+		{
+			name:   "by isbn13",
+			isbn:   "9780306406157",
+			status: http.StatusOK,
+		},
+		{
+			name:   "by isbn10 with hyphens",
+			isbn:   "0-306-40615-2",
+			status: http.StatusOK,
+		},
+		{
+			name:   "unknown isbn",
+			isbn:   "9780804429573",
+			status: http.StatusNotFound,
+		},
+		{
+			name:   "invalid isbn",
+			isbn:   "12345",
+			status: http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/books/isbn/"+tt.isbn, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, r)
+
+			if w.Code != tt.status {
+				t.Fatalf("Expected status %d, got %d", tt.status, w.Code)
+			}
+			if tt.status == http.StatusOK {
+				var book managementsystem.Book
+				if err := json.NewDecoder(w.Body).Decode(&book); err != nil {
+					t.Fatalf("failed to decode response: %v", err)
+				}
+				if book.Title != "comics" {
+					t.Fatalf("Expected title comics, got %s", book.Title)
+				}
+			}
+		})
+	}
+}
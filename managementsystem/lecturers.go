@@ -1,171 +1,69 @@
 package managementsystem
 
 import (
-	"encoding/json"
-	"fmt"
-	"log"
 	"net/http"
-	"strconv"
 	"strings"
 	"time"
 
 	_ "github.com/go-sql-driver/mysql"
-	"github.com/gorilla/mux"
+	"managementsystem/validation"
 )
 
 type Lecturer struct {
 	ID          int    `json:"id"`
-	Name        string `json:"name"`
-	Email       string `json:"email"`
-	Dept        string `json:"dept"`
-	Designation string `json:"designation"`
+	Name        string `json:"name" validate:"trimmed,required"`
+	Email       string `json:"email" validate:"required,email=gmail.com"`
+	Dept        string `json:"dept" validate:"trimmed,required"`
+	DeptID      *int   `json:"dept_id"`
+	Designation string `json:"designation" validate:"trimmed,required"`
 }
 
 // validation
 func Validatelecturer(lecturer Lecturer) error {
-	if lecturer.Email == "" {
-		return fmt.Errorf("email is invalid and empty")
-	}
-	if strings.TrimSpace(lecturer.Name) == "" {
-		return fmt.Errorf("name is invalid and empty")
-	}
-	if !strings.HasSuffix(lecturer.Email, "@gmail.com") {
-		return fmt.Errorf("email is invalid and does not contain @gmail.com")
-	}
-	prefix := strings.TrimSuffix(lecturer.Email, "@gmail.com")
-	if prefix == "" {
-		return fmt.Errorf("email must contains a prefix before the @gmail.com ")
-	}
-	if lecturer.Dept == "" {
-		return fmt.Errorf("Dept is invalid!")
-	}
-	if lecturer.Designation == "" {
-		return fmt.Errorf("Year is invalid, please enter a valid year")
-	}
-	return nil
+	return validation.Struct(lecturer)
 }
 
-// create students
+var lecturerResource = &Resource[Lecturer]{
+	Name:        "lecturer",
+	Path:        "/lecturers",
+	Table:       "lecturers",
+	Key:         "id",
+	Columns:     []string{"name", "email", "dept", "dept_id", "designation"},
+	CachePrefix: "lecturer:",
+	CacheTTL:    10 * time.Minute,
+	Fields: func(l *Lecturer) []any {
+		return []any{&l.ID, &l.Name, &l.Email, &l.Dept, &l.DeptID, &l.Designation}
+	},
+	Validate: func(l *Lecturer) error { return Validatelecturer(*l) },
+	Prepare: func(h *HybridHandler5, l *Lecturer) error {
+		if strings.TrimSpace(l.Designation) != "" {
+			if _, err := resolveDesignation(h.MySQL.DB, &l.Designation); err != nil {
+				return err
+			}
+		}
+		return h.resolveDepartment(&l.DeptID, &l.Dept)
+	},
+	AfterSave: func(h *HybridHandler5, tx querier, l *Lecturer) error {
+		return recordDesignation(tx, l)
+	},
+}
+
+// create lecturers
 func (h *HybridHandler5) CreateLecturersHandler(w http.ResponseWriter, r *http.Request) {
-	var lecturers Lecturer
-	if err := json.NewDecoder(r.Body).Decode(&lecturers); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
-	}
-	if err := Validatelecturer(lecturers); err != nil {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"Error": err.Error()})
-		return
-	}
-	res, err := h.MySQL.DB.Exec("INSERT INTO lecturers (name , email , dept , designation) VALUES (? , ? , ? , ? )", lecturers.Name, lecturers.Email, lecturers.Dept, lecturers.Designation)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-	id, err := res.LastInsertId()
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-	lecturers.ID = int(id)
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(lecturers)
+	lecturerResource.Create(h, w, r)
 }
 
-// Get students
+// Get lecturers
 func (h *HybridHandler5) GetLecturersHandler(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	id := vars["id"]
-
-	value, err := h.Redis.Client.Get(h.Ctx, id).Result()
-	if err == nil {
-		log.Println("Cache hit!")
-		w.Header().Set("Content-Type", "application/json")
-		w.Write([]byte(value))
-		return
-	}
-	fmt.Println("Cache miss Quering MySQL ...")
-	row := h.MySQL.DB.QueryRow("SELECT id , name , email , dept , designation FROM lecturers WHERE  id=?", id)
-
-	var lecturers Lecturer
-	if err := row.Scan(&lecturers.ID, &lecturers.Name, &lecturers.Email, &lecturers.Dept, &lecturers.Designation); err != nil {
-		http.Error(w, "User not found", http.StatusNotFound)
-		return
-	}
-	jsondata, err := json.Marshal(lecturers)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
-	}
-	h.Redis.Client.Set(h.Ctx, id, jsondata, 10*time.Second)
-	w.Header().Set("Content-Type", "application/json")
-	w.Write(jsondata)
+	lecturerResource.Get(h, w, r)
 }
 
-// update  students
+// update  lecturers
 func (h *HybridHandler5) UpdateLecturersHandler(w http.ResponseWriter, r *http.Request) {
-	var lecturers Lecturer
-	if err := json.NewDecoder(r.Body).Decode(&lecturers); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
-	}
-	if err := Validatelecturer(lecturers); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"Error": err.Error()})
-		return
-	}
-	res, err := h.MySQL.DB.Exec("UPDATE lecturers SET name=?,email=?,dept=?,designation=? WHERE id=?", lecturers.Name, lecturers.Email, lecturers.Dept, lecturers.Designation, lecturers.ID)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-	rows, err := res.RowsAffected()
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-	if rows == 0 {
-		http.Error(w, "user not found", http.StatusNotFound)
-		return
-	}
-	jsonData, err := json.Marshal(lecturers)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-	}
-	h.Redis.Client.Set(h.Ctx, fmt.Sprint(lecturers.ID), jsonData, 10*time.Minute)
-
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	w.Write(jsonData)
+	lecturerResource.Update(h, w, r)
 }
 
-// Delete student
+// Delete lecturer
 func (h *HybridHandler5) DeleteLecturersHandler3(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	id := vars["id"]
-	idInt, _ := strconv.Atoi(id)
-
-	res, err := h.MySQL.DB.Exec("DELETE FROM lecturers WHERE id=?", idInt)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-	rows, err := res.RowsAffected()
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-	if rows == 0 {
-		http.Error(w, "lecturer not found", http.StatusNotFound)
-		return
-	}
-
-	h.Redis.Client.Del(h.Ctx, id)
-
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-
-	w.Write([]byte("lecturer deleted"))
+	lecturerResource.Delete(h, w, r)
 }
@@ -42,7 +42,7 @@ func TestHybridHandler5_CreateLecturersHandler(t *testing.T) {
 				Name:        "Ramesh",
 				Email:       "ramesh@gmail.com",
 				Dept:        "CSE",
-				Designation: "senior lecturer",
+				Designation: "Senior Lecturer",
 			},
 			willpass: true,
 		},
@@ -52,7 +52,7 @@ func TestHybridHandler5_CreateLecturersHandler(t *testing.T) {
 				Name:        "",
 				Email:       "akash@gmail.com",
 				Dept:        "CSE",
-				Designation: "senior lecturer",
+				Designation: "Senior Lecturer",
 			},
 			willpass: false,
 		},
@@ -62,7 +62,7 @@ func TestHybridHandler5_CreateLecturersHandler(t *testing.T) {
 				Name:        "Akash",
 				Email:       "",
 				Dept:        "CSE",
-				Designation: "senior lecturer",
+				Designation: "Senior Lecturer",
 			},
 			willpass: false,
 		},
@@ -72,7 +72,7 @@ func TestHybridHandler5_CreateLecturersHandler(t *testing.T) {
 				Name:        "Akash",
 				Email:       "akash@gmail.com",
 				Dept:        "",
-				Designation: "senior lecturer",
+				Designation: "Senior Lecturer",
 			},
 			willpass: false,
 		},
@@ -92,7 +92,7 @@ func TestHybridHandler5_CreateLecturersHandler(t *testing.T) {
 				Name:        "Akash",
 				Email:       "@gmail.com",
 				Dept:        "CSE",
-				Designation: "senior lecturer",
+				Designation: "Senior Lecturer",
 			},
 			willpass: false,
 		},
@@ -102,7 +102,7 @@ func TestHybridHandler5_CreateLecturersHandler(t *testing.T) {
 				Name:        "   ",
 				Email:       "akash@gmail.com",
 				Dept:        "CSE",
-				Designation: "senior lecturer",
+				Designation: "Senior Lecturer",
 			},
 			willpass: false,
 		},
@@ -174,7 +174,7 @@ func TestHybridHandler5_GetLecturersHandler(t *testing.T) {
 	mysqlinstance.DB.Exec("ALTER TABLE lecturers AUTO_INCREMENT=1")
 	redisInstance.Client.FlushAll(context.Background())
 
-	res, err := mysqlinstance.DB.Exec("INSERT INTO lecturers (name , email, dept , designation) VALUES (?, ?, ?, ?)", "Akash", "akash@gmail.com", "CSE", "senior lecturer")
+	res, err := mysqlinstance.DB.Exec("INSERT INTO lecturers (name , email, dept , designation) VALUES (?, ?, ?, ?)", "Akash", "akash@gmail.com", "CSE", "Senior Lecturer")
 	if err != nil {
 		t.Fatalf("insert fail: %v", err)
 	}
@@ -242,7 +242,7 @@ func TestHybridHandler5_UpdateLecturersHandler(t *testing.T) {
 	mysqlinstance.DB.Exec("DELETE FROM lecturers")
 	mysqlinstance.DB.Exec("ALTER TABLE lecturers AUTO_INCREMENT = 1")
 	redisInstance.Client.FlushAll(context.Background())
-	_, err = mysqlinstance.DB.Exec("INSERT INTO lecturers (id , name , email,  dept , designation) VALUES (1 , 'ramesh' , 'ramesh@gmail.com',  'CSE', 'senior lecturer') ON DUPLICATE KEY UPDATE name='ramesh',email='ramesh@gmail.com',dept='CSE',designation='senior lecturer' ")
+	_, err = mysqlinstance.DB.Exec("INSERT INTO lecturers (id , name , email,  dept , designation) VALUES (1 , 'ramesh' , 'ramesh@gmail.com',  'CSE', 'Senior Lecturer') ON DUPLICATE KEY UPDATE name='ramesh',email='ramesh@gmail.com',dept='CSE',designation='Senior Lecturer' ")
 	if err != nil {
 		t.Fatalf("insert fail: %v", err)
 	}
@@ -258,7 +258,7 @@ func TestHybridHandler5_UpdateLecturersHandler(t *testing.T) {
 				Name:        "ramesh bhadwa",
 				Email:       "rameshbhadwa@gmail.com",
 				Dept:        "CSE",
-				Designation: "s senior lecturer",
+				Designation: "Professor",
 			},
 			willpass: true,
 		},
@@ -269,7 +269,7 @@ func TestHybridHandler5_UpdateLecturersHandler(t *testing.T) {
 				Name:        "Akash",
 				Email:       "akash@gmail.com",
 				Dept:        "CSE",
-				Designation: "senior lecturer",
+				Designation: "Senior Lecturer",
 			},
 			willpass: false,
 		},
@@ -335,7 +335,7 @@ func TestHybridHandler5_DeleteLecturersHandler3(t *testing.T) {
 	mysqlinstance.DB.Exec("ALTER TABLE lecturers AUTO_INCREMENT=1")
 	redisInstance.Client.FlushAll(context.Background())
 
-	res, err := mysqlinstance.DB.Exec("INSERT INTO lecturers (id , name , email, dept , designation) VALUES (?, ?, ?, ?, ?)", 1, "ramesh", "ramesh@gmail.com", "CSE", "senior lecturer")
+	res, err := mysqlinstance.DB.Exec("INSERT INTO lecturers (id , name , email, dept , designation) VALUES (?, ?, ?, ?, ?)", 1, "ramesh", "ramesh@gmail.com", "CSE", "Senior Lecturer")
 	if err != nil {
 		t.Fatalf("insert fail: %v", err)
 	}
@@ -1,108 +1,205 @@
 package managementsystem
 
 import (
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
 	"net/http"
-	"strings"
 	"time"
 
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/gorilla/mux"
+	"managementsystem/validation"
 )
 
+// Book is a title in the library. Author is the names of its Authors joined
+// by commas, kept for clients that predate the authors list.
+// Available_copies counts every branch and Branches splits the copies up.
 type Book struct {
-	Book_id          int    `json:"book_id"`
-	Title            string `json:"title"`
-	Author           string `json:"author"`
-	Available_copies int    `json:"available_copies"`
+	Book_id          int            `json:"book_id" validate:"min=0"`
+	Title            string         `json:"title" validate:"trimmed,required"`
+	Author           string         `json:"author" validate:"trimmed,required,max=255"`
+	ISBN10           string         `json:"isbn10" validate:"omitempty,isbn10"`
+	ISBN13           string         `json:"isbn13" validate:"omitempty,isbn13"`
+	Available_copies int            `json:"available_copies" validate:"min=0"`
+	Authors          []Author       `json:"authors"`
+	Categories       []Category     `json:"categories"`
+	Branches         []BranchCopies `json:"branches"`
 }
 type Borrow_records struct {
-	Borrow_id   int        `json:"borrow_id"`
-	User_id     int        `json:"user_id"`
-	User_type   string     `json:"user_type"`
-	Book_id     int        `json:"book_id"`
-	Borrow_date time.Time  `json:"borrow_date"`
-	Return_date *time.Time `json:"time_date"`
+	Borrow_id        int     `json:"borrow_id"`
+	User_id          int     `json:"user_id" validate:"min=1"`
+	User_type        string  `json:"user_type" validate:"enum=student|lecturer"`
+	Book_id          int     `json:"book_id" validate:"min=0"`
+	Copy_id          int     `json:"copy_id"`
+	Barcode          string  `json:"barcode,omitempty"`
+	Branch_id        int     `json:"branch_id" validate:"min=0"` // where it was borrowed, any branch when 0
+	Borrow_date      string  `json:"borrow_date"`
+	Due_date         string  `json:"due_date"`
+	Due_at           *string `json:"due_at"` // the time a short loan is due
+	Renewals         int     `json:"renewals"`
+	Return_date      *string `json:"return_date"`
+	Returned_by      *string `json:"returned_by"`
+	Return_branch_id *int    `json:"return_branch_id"`
+	Outcome          string  `json:"outcome,omitempty"` // returned, lost or damaged once closed
 }
 
-// validation
-func ValidateLibrary(book Book) error {
-	if book.Book_id < 0 {
-		return fmt.Errorf("book_id is invalid")
+// ReturnRequest names the loan a return is for.
+type ReturnRequest struct {
+	Borrow_id    int    `json:"borrow_id" validate:"min=0"`
+	Barcode      string `json:"barcode" validate:"trimmed,max=32"`
+	User_id      int    `json:"user_id" validate:"min=0"`
+	User_type    string `json:"user_type" validate:"omitempty,enum=student|lecturer"`
+	Book_id      int    `json:"book_id" validate:"min=0"`
+	Branch_id    int    `json:"branch_id" validate:"min=0"`
+	Processed_by string `json:"processed_by" validate:"trimmed,max=100"`
+}
+
+// validation. ISBNs are normalized and whichever of ISBN-10 and ISBN-13 is
+// missing is filled in from the other.
+func ValidateLibrary(book *Book) error {
+	book.ISBN10 = NormalizeISBN(book.ISBN10)
+	book.ISBN13 = NormalizeISBN(book.ISBN13)
+	if err := validation.Struct(book); err != nil {
+		return err
 	}
-	if strings.TrimSpace(book.Title) == "" {
-		return fmt.Errorf("title is invalid and empty")
+	for _, author := range book.Authors {
+		if err := ValidateAuthor(author); err != nil {
+			return err
+		}
 	}
-	if strings.TrimSpace(book.Author) == "" {
-		return fmt.Errorf("Author is invalid and empty")
+	for _, category := range book.Categories {
+		if err := ValidateCategory(category); err != nil {
+			return err
+		}
 	}
-	if book.Available_copies <= 0 {
-		return fmt.Errorf("available_copies is less than 0")
+	if book.ISBN10 != "" {
+		isbn13, _ := ISBN10To13(book.ISBN10)
+		if book.ISBN13 == "" {
+			book.ISBN13 = isbn13
+		} else if book.ISBN13 != isbn13 {
+			return fmt.Errorf("isbn10 %s and isbn13 %s are different books", book.ISBN10, book.ISBN13)
+		}
 	}
+	if book.ISBN10 == "" && book.ISBN13 != "" {
+		// 979 ISBNs have no ISBN-10 and keep it empty
+		book.ISBN10, _ = ISBN13To10(book.ISBN13)
+	}
+	return nil
+}
 
+// ValidateBorrow checks the body of a borrow request.
+func ValidateBorrow(record Borrow_records) error {
+	if err := validation.Struct(record); err != nil {
+		return err
+	}
+	if record.Book_id == 0 && record.Barcode == "" {
+		return fmt.Errorf("book_id or barcode is required")
+	}
 	return nil
 }
 
-// create students
-func (h *HybridHandler5) CreateBookHandler(w http.ResponseWriter, r *http.Request) {
-	var books Book
-	if err := json.NewDecoder(r.Body).Decode(&books); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
+// ValidateReturn checks the body of a return request, which names the loan
+// by borrow id, by barcode, or by user and book.
+func ValidateReturn(req *ReturnRequest) error {
+	if err := validation.Struct(req); err != nil {
+		return err
 	}
-	if err := ValidateLibrary(books); err != nil {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"Error": err.Error()})
-		return
+	if req.Borrow_id == 0 && req.Barcode == "" && (req.User_id == 0 || req.User_type == "" || req.Book_id == 0) {
+		return fmt.Errorf("borrow_id, barcode or user_id, user_type and book_id is required")
 	}
-	res, err := h.MySQL.DB.Exec("INSERT INTO books (title , author , available_copies) VALUES ( ? , ? , ?)", books.Title, books.Author, books.Available_copies)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+	return nil
+}
+
+var bookResource = &Resource[Book]{
+	Name:        "book",
+	Path:        "/books",
+	Table:       "books",
+	Key:         "book_id",
+	Columns:     []string{"title", "author", "isbn10", "isbn13"},
+	Computed:    []string{availableCopiesSQL},
+	CachePrefix: "book:",
+	CacheTTL:    10 * time.Minute,
+	Fields: func(b *Book) []any {
+		return []any{&b.Book_id, &b.Title, &b.Author, &nullString{&b.ISBN10}, &nullString{&b.ISBN13}, &b.Available_copies}
+	},
+	Validate: ValidateLibrary,
+	Prepare:  resolveBookLinks,
+	AfterSave: func(h *HybridHandler5, tx querier, b *Book) error {
+		return linkBook(tx, b)
+	},
+	Expand: expandBook,
+	// available_copies is how many copies a new book starts with
+	ValidateCreate: func(b *Book) error {
+		return validation.Struct(struct {
+			Copies int `json:"available_copies" validate:"min=1"`
+		}{b.Available_copies})
+	},
+	AfterCreate: func(h *HybridHandler5, tx querier, b *Book) error {
+		_, err := addCopies(tx, b.Book_id, b.Available_copies, BookCopy{})
+		return err
+	},
+}
+
+// checkNewBook prepares and validates a book to create the way
+// CreateBookHandler does.
+func checkNewBook(h *HybridHandler5, book *Book) error {
+	if err := bookResource.Prepare(h, book); err != nil {
+		return err
+	}
+	if err := bookResource.Validate(book); err != nil {
+		return err
+	}
+	return bookResource.ValidateCreate(book)
+}
+
+// stockBook adds a checked book with its available copies, or only adds
+// the copies when a book with its ISBN-13 is already in the library, and
+// reports whether it did the latter. Either way book.Book_id is set.
+func stockBook(h *HybridHandler5, tx querier, book *Book) (bool, error) {
+	err := tx.QueryRow("SELECT book_id FROM books WHERE isbn13=? FOR UPDATE", nullString{&book.ISBN13}).Scan(&book.Book_id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, bookResource.insert(h, tx, book)
 	}
-	id, err := res.LastInsertId()
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+		return false, err
 	}
-	books.Book_id = int(id)
+	if _, err := addCopies(tx, book.Book_id, book.Available_copies, BookCopy{}); err != nil {
+		return true, err
+	}
+	// the new copies go to the first waiting holds, if any
+	return true, refreshHolds(tx, book.Book_id)
+}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(books)
+// create books
+func (h *HybridHandler5) CreateBookHandler(w http.ResponseWriter, r *http.Request) {
+	bookResource.Create(h, w, r)
 }
 
-// Get students
+// Get books
 func (h *HybridHandler5) GetBookHandler(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	id := vars["id"]
-
-	value, err := h.Redis.Client.Get(h.Ctx, id).Result()
-	if err == nil {
-		log.Println("Cache hit!")
-		w.Header().Set("Content-Type", "application/json")
-		w.Write([]byte(value))
-		return
-	}
-	fmt.Println("Cache miss Quering MySQL ...")
-	row := h.MySQL.DB.QueryRow("SELECT book_id , title , author , available_copies FROM books WHERE  book_id=?", id)
+	bookResource.Get(h, w, r)
+}
 
-	var books Book
-	if err := row.Scan(&books.Book_id, &books.Title, &books.Author, &books.Available_copies); err != nil {
-		http.Error(w, "Book not found", http.StatusNotFound)
+// Get book by ISBN-10 or ISBN-13
+func (h *HybridHandler5) GetBookByISBNHandler(w http.ResponseWriter, r *http.Request) {
+	isbn13, err := ISBNTo13(mux.Vars(r)["isbn"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	jsondata, err := json.Marshal(books)
+	var book Book
+	err = h.MySQL.DB.QueryRow(bookResource.selectSQL()+" WHERE isbn13=?", isbn13).Scan(bookResource.Fields(&book)...)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		bookResource.writeLoadError(w, err)
+		return
+	}
+	if err := expandBook(h.MySQL.DB, &book); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	h.Redis.Client.Set(h.Ctx, id, jsondata, 10*time.Second)
-	w.Header().Set("Content-Type", "application/json")
-	w.Write(jsondata)
+	writeJSON(w, http.StatusOK, book)
 }
 
 // Borrow books
@@ -112,69 +209,247 @@ func (h *HybridHandler5) BorrowBook(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "invalid json", http.StatusBadRequest)
 		return
 	}
-	// validate user type
-	if record.User_type != "student" && record.User_type != "lecturer" {
-		http.Error(w, "invalid user_type, must be 'student' or 'lecturer'", http.StatusBadRequest)
+	if err := ValidateBorrow(record); err != nil {
+		http.Error(w, validation.Translate(err, r.Header.Get("Accept-Language")).Error(), http.StatusBadRequest)
 		return
 	}
-	//  Check if Book is available
-	var available int
-	err := h.MySQL.DB.QueryRow("SELECT available_copies FROM books WHERE book_id=?", record.Book_id).Scan(&available)
+	if err := h.updateSuspension(record.User_type, record.User_id); err != nil {
+		writeError(w, err)
+		return
+	}
+	tx, err := h.MySQL.DB.Begin()
 	if err != nil {
-		http.Error(w, "Book not found", http.StatusNotFound)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	if available <= 0 {
-		http.Error(w, " Book not available", http.StatusBadRequest)
+	defer tx.Rollback()
+
+	if err := borrow(tx, &record); err != nil {
+		writeError(w, err)
 		return
 	}
-	// Insert borrow record
-	_, err = h.MySQL.DB.Exec("INSERT INTO borrow_records(user_id, user_type,book_id ,borrow_date)VALUES (? , ? , ? , CURDATE())", record.User_id, record.User_type, record.Book_id)
-	if err != nil {
+	if err := tx.Commit(); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	//  decrement available copies
-	_, err = h.MySQL.DB.Exec("UPDATE books SET available_copies = available_copies-1 WHERE book_id=?", record.Book_id)
+	h.cacheDel(bookResource.cacheKey(record.Book_id))
+	writeJSON(w, http.StatusCreated, record)
+}
+
+// borrow lends a copy of the record's book, or the copy with its barcode,
+// at the record's branch if it has one, and fills in the record. The loan is
+// due back after the loan period of the user type, or after the short loan
+// period for a copy on course reserve, on a day the library is open. The
+// loan policy must allow the user another book.
+func borrow(tx querier, record *Borrow_records) error {
+	policy, err := loanPolicy(tx, record.User_type)
+	if err != nil {
+		return err
+	}
+	if err := checkBorrower(tx, policy, record.User_id); err != nil {
+		return err
+	}
+	c, err := pickCopy(tx, record)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+		return err
+	}
+	// copies on course reserve go out on a short loan until the end of term
+	hours := 0
+	if c.Reserve_id != nil {
+		err := tx.QueryRow("SELECT IFNULL(loan_hours, ?) FROM course_reserves WHERE id=? AND status=? AND ends_on >= CURDATE()", policy.ShortLoanHours, *c.Reserve_id, reserveActive).Scan(&hours)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
 	}
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(map[string]string{"status": "Book borrowed"})
+	calendar, err := loadCalendar(tx)
+	if err != nil {
+		return err
+	}
+	var res sql.Result
+	if hours > 0 {
+		due := calendar.ShortLoanDue(calendar.Now, hours)
+		res, err = tx.Exec("INSERT INTO borrow_records (user_id, user_type, book_id, copy_id, branch_id, borrow_date, due_date, due_at) VALUES (?, ?, ?, ?, ?, CURDATE(), ?, ?)", record.User_id, record.User_type, c.Book_id, c.Copy_id, c.Branch_id, due.Format(time.DateOnly), due.Format(time.DateTime))
+	} else {
+		due := calendar.DueDate(calendar.Today, policy.LoanDays).Format(time.DateOnly)
+		res, err = tx.Exec("INSERT INTO borrow_records (user_id, user_type, book_id, copy_id, branch_id, borrow_date, due_date) VALUES (?, ?, ?, ?, ?, CURDATE(), ?)", record.User_id, record.User_type, c.Book_id, c.Copy_id, c.Branch_id, due)
+	}
+	if err != nil {
+		return err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec("UPDATE book_copies SET status=? WHERE copy_id=?", copyBorrowed, c.Copy_id); err != nil {
+		return err
+	}
+	if c.Status == copyOnHold {
+		if _, err := tx.Exec("UPDATE holds SET status=? WHERE copy_id=? AND status=?", holdFulfilled, c.Copy_id, holdReady); err != nil {
+			return err
+		}
+	}
+	loan, err := loadLoan(tx, int(id))
+	if err != nil {
+		return err
+	}
+	*record = *loan
+	return nil
 }
 
 // Return book
 func (h *HybridHandler5) ReturnBook(w http.ResponseWriter, r *http.Request) {
-	var record Borrow_records
-	if err := json.NewDecoder(r.Body).Decode(&record); err != nil {
-		http.Error(w, "invalid json", http.StatusInternalServerError)
+	var req ReturnRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid json", http.StatusBadRequest)
 		return
 	}
-	// validate user type
-	if record.User_type != "student" && record.User_type != "lecturer" {
-		http.Error(w, "invalid user_type, must be 'student' or 'lecturer'", http.StatusBadRequest)
+	if err := ValidateReturn(&req); err != nil {
+		http.Error(w, validation.Translate(err, r.Header.Get("Accept-Language")).Error(), http.StatusBadRequest)
 		return
 	}
-	// Update borrow_books record with return date
-	res, err := h.MySQL.DB.Exec("UPDATE borrow_records SET return_date=CURDATE() WHERE user_id=? AND book_id=? AND return_date IS NULL", record.User_id, record.Book_id)
+	tx, err := h.MySQL.DB.Begin()
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	rows, _ := res.RowsAffected()
-	if rows == 0 {
-		http.Error(w, "no active borrow record found", http.StatusNotFound)
+	defer tx.Rollback()
+
+	record, err := findReturn(tx, req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	// a copy returned away from its branch travels back to it
+	status, home := copyAvailable, 0
+	if req.Branch_id != 0 {
+		if err := checkBranch(tx, req.Branch_id); err != nil {
+			writeError(w, err)
+			return
+		}
+		if record.Copy_id != 0 {
+			if err := tx.QueryRow("SELECT branch_id FROM book_copies WHERE copy_id=?", record.Copy_id).Scan(&home); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+		if home != 0 && home != req.Branch_id {
+			status = copyInTransit
+		}
 	}
-	//  increment available copies
-	_, err = h.MySQL.DB.Exec("UPDATE books SET available_copies = available_copies+1 WHERE book_id=?", record.Book_id)
+	fine, err := closeLoan(tx, record, loanReturned, status, req.Branch_id, req.Processed_by)
 	if err != nil {
+		writeError(w, err)
+		return
+	}
+	var transfer *Transfer
+	if status == copyInTransit {
+		if transfer, err = startTransfer(tx, record.Copy_id, req.Branch_id, home, "returned at another branch", req.Processed_by); err != nil {
+			writeError(w, err)
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(map[string]string{"status": "Book return"})
+	h.cacheDel(bookResource.cacheKey(record.Book_id))
+	response := map[string]any{"status": "Book return", "loan": record}
+	if fine != nil {
+		response["fine"] = fine
+	}
+	if transfer != nil {
+		response["transfer"] = transfer
+	}
+	writeJSON(w, http.StatusCreated, response)
+}
+
+// Loan outcomes.
+const (
+	loanReturned = "returned"
+	loanLost     = "lost"
+	loanDamaged  = "damaged"
+)
 
+// closeLoan ends a loan with an outcome at a branch (none when 0), leaves
+// its copy with copyStatus, hands the copy to the hold queue when it is
+// available again and charges the overdue fine, if any, for the days the
+// library was open. record is reloaded.
+func closeLoan(tx querier, record *Borrow_records, outcome, copyStatus string, branchID int, processedBy string) (*Fine, error) {
+	var due, dueAt *string
+	if err := tx.QueryRow("SELECT DATE_FORMAT(due_date, '%Y-%m-%d'), DATE_FORMAT(due_at, '%Y-%m-%d %H:%i:%s') FROM borrow_records WHERE borrow_id=?", record.Borrow_id).Scan(&due, &dueAt); err != nil {
+		return nil, err
+	}
+	calendar, err := loadCalendar(tx)
+	if err != nil {
+		return nil, err
+	}
+	daysOverdue, err := calendar.overdueDays(due)
+	if err != nil {
+		return nil, err
+	}
+	hoursOverdue, err := calendar.overdueHours(dueAt)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec("UPDATE borrow_records SET return_date=CURDATE(), return_branch_id=NULLIF(?, 0), returned_by=NULLIF(?, ''), outcome=? WHERE borrow_id=?", branchID, processedBy, outcome, record.Borrow_id); err != nil {
+		return nil, err
+	}
+	// loans from before per-copy inventory have no copy
+	if record.Copy_id != 0 {
+		if _, err := tx.Exec("UPDATE book_copies SET status=? WHERE copy_id=? AND status=?", copyStatus, record.Copy_id, copyBorrowed); err != nil {
+			return nil, err
+		}
+	}
+	// an available copy goes to the first waiting hold, if any
+	if err := refreshHolds(tx, record.Book_id); err != nil {
+		return nil, err
+	}
+	fine, err := chargeOverdue(tx, record, daysOverdue, hoursOverdue)
+	if err != nil {
+		return nil, err
+	}
+	loan, err := loadLoan(tx, record.Borrow_id)
+	if err != nil {
+		return nil, err
+	}
+	*record = *loan
+	return fine, nil
+}
+
+// findReturn locks the one loan a return is for: the loan with the borrow
+// id, or the open loan of the copy with the barcode. Older clients name the
+// user and the book instead, which returns the user's oldest open loan of
+// the book.
+func findReturn(tx querier, req ReturnRequest) (*Borrow_records, error) {
+	var where string
+	var args []any
+	switch {
+	case req.Borrow_id != 0:
+		where, args = "r.borrow_id=?", []any{req.Borrow_id}
+	case req.Barcode != "":
+		where, args = "c.barcode=? AND r.return_date IS NULL", []any{req.Barcode}
+	default:
+		where, args = "r.user_id=? AND r.user_type=? AND r.book_id=? AND r.return_date IS NULL", []any{req.User_id, req.User_type, req.Book_id}
+	}
+	var record Borrow_records
+	err := scanLoan(tx.QueryRow(loanSelect+" WHERE "+where+" ORDER BY r.borrow_id LIMIT 1 FOR UPDATE", args...), &record)
+	if errors.Is(err, sql.ErrNoRows) {
+		if req.Borrow_id == 0 && req.Barcode != "" {
+			var exists bool
+			if err := tx.QueryRow("SELECT EXISTS(SELECT 1 FROM book_copies WHERE barcode=?)", req.Barcode).Scan(&exists); err != nil {
+				return nil, err
+			}
+			if exists {
+				return nil, newHTTPError(http.StatusConflict, "copy %s is not on loan", req.Barcode)
+			}
+		}
+		return nil, newHTTPError(http.StatusNotFound, "no active borrow record found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if record.Return_date != nil {
+		return nil, newHTTPError(http.StatusConflict, "loan %d was already returned on %s", record.Borrow_id, *record.Return_date)
+	}
+	return &record, nil
 }
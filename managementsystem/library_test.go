@@ -170,7 +170,7 @@ func TestHybridHandler5_GetBookHandler(t *testing.T) {
 	mysqlinstance.DB.Exec("ALTER TABLE books AUTO_INCREMENT=1")
 	redisInstance.Client.FlushAll(context.Background())
 
-	res, err := mysqlinstance.DB.Exec("INSERT INTO books (title , author , available_copies) VALUES (?, ?, ?)", "comics", "sujan", 10)
+	res, err := mysqlinstance.DB.Exec("INSERT INTO books (title , author) VALUES (?, ?)", "comics", "sujan")
 	if err != nil {
 		t.Fatalf("insert fail: %v", err)
 	}
@@ -235,17 +235,28 @@ func TestHybridHandler5_BorrowBook(t *testing.T) {
 
 	handler := &managementsystem.HybridHandler5{MySQL: mysqlinstance, Redis: redisInstance, Ctx: context.Background()}
 
-	mysqlinstance.DB.Exec("DELETE FROM books")
+	mysqlinstance.DB.Exec("DELETE FROM fines")
 	mysqlinstance.DB.Exec("DELETE FROM borrow_records")
+	mysqlinstance.DB.Exec("DELETE FROM book_copies")
+	mysqlinstance.DB.Exec("DELETE FROM books")
 	mysqlinstance.DB.Exec("ALTER TABLE books AUTO_INCREMENT=1")
 	redisInstance.Client.FlushAll(context.Background())
 
-	res, err := mysqlinstance.DB.Exec("INSERT INTO books(title, author, available_copies) VALUES (?, ?, ?)", "GoLang", "Alice", 2)
+	// the borrowers must exist
+	mysqlinstance.DB.Exec("INSERT IGNORE INTO students (id, name, email, age, year) VALUES (?, ?, ?, ?, ?)", 101, "borrower", "borrower101@gmail.com", 20, 1)
+	mysqlinstance.DB.Exec("INSERT IGNORE INTO lecturers (id, name, email, designation) VALUES (?, ?, ?, ?)", 101, "borrower", "borrower101@gmail.com", "Lecturer")
+
+	res, err := mysqlinstance.DB.Exec("INSERT INTO books(title, author) VALUES (?, ?)", "GoLang", "Alice")
 	if err != nil {
 		log.Panic(err)
 	}
 	book_id, _ := res.LastInsertId()
 
+	_, err = mysqlinstance.DB.Exec("INSERT INTO book_copies(book_id, branch_id, barcode) VALUES (?, (SELECT id FROM branches WHERE code='MAIN'), ?), (?, (SELECT id FROM branches WHERE code='MAIN'), ?)", book_id, "GO-1", book_id, "GO-2")
+	if err != nil {
+		log.Panic(err)
+	}
+
 	tests := []struct {
 		name     string // description of this test case
 		body     managementsystem.Borrow_records
@@ -290,7 +301,7 @@ func TestHybridHandler5_BorrowBook(t *testing.T) {
 		{
 			name: "book unavailable",
 			body: managementsystem.Borrow_records{
-				User_id:   1045,
+				User_id:   101,
 				User_type: "student",
 				Book_id:   int(book_id),
 			},
@@ -339,18 +350,26 @@ func TestHybridHandler5_ReturnBook(t *testing.T) {
 
 	handler := &managementsystem.HybridHandler5{MySQL: mysqlinstance, Redis: redisInstance, Ctx: context.Background()}
 
-	mysqlinstance.DB.Exec("DELETE FROM books")
+	mysqlinstance.DB.Exec("DELETE FROM fines")
 	mysqlinstance.DB.Exec("DELETE FROM borrow_records")
+	mysqlinstance.DB.Exec("DELETE FROM book_copies")
+	mysqlinstance.DB.Exec("DELETE FROM books")
 	mysqlinstance.DB.Exec("ALTER TABLE books AUTO_INCREMENT=1")
 	redisInstance.Client.FlushAll(context.Background())
 
-	res, err := mysqlinstance.DB.Exec("INSERT INTO books(title, author, available_copies) VALUES (?, ?, ?)", "GoLang", "Alice", 2)
+	res, err := mysqlinstance.DB.Exec("INSERT INTO books(title, author) VALUES (?, ?)", "GoLang", "Alice")
 	if err != nil {
 		log.Panic(err)
 	}
 	book_id, _ := res.LastInsertId()
 
-	_, err = mysqlinstance.DB.Exec("INSERT INTO borrow_records(user_id, user_type,book_id ,borrow_date, return_date)VALUES (? , ? , ? ,CURDATE(), NULL)", 101, "student", book_id)
+	copyRes, err := mysqlinstance.DB.Exec("INSERT INTO book_copies(book_id, branch_id, barcode, status) VALUES (?, (SELECT id FROM branches WHERE code='MAIN'), ?, ?)", book_id, "GO-1", "borrowed")
+	if err != nil {
+		log.Panic(err)
+	}
+	copy_id, _ := copyRes.LastInsertId()
+
+	_, err = mysqlinstance.DB.Exec("INSERT INTO borrow_records(user_id, user_type,book_id ,copy_id ,borrow_date, return_date)VALUES (? , ? , ? , ? ,CURDATE(), NULL)", 101, "student", book_id, copy_id)
 	if err != nil {
 		t.Fatalf("insert borrow fail: %v", err)
 	}
@@ -0,0 +1,120 @@
+package managementsystem
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+const loanSelect = `SELECT r.borrow_id, r.user_id, r.user_type, r.book_id, IFNULL(r.copy_id, 0), IFNULL(c.barcode, ''), IFNULL(r.branch_id, 0),
+	IFNULL(DATE_FORMAT(r.borrow_date, '%Y-%m-%d'), ''), IFNULL(DATE_FORMAT(r.due_date, '%Y-%m-%d'), ''), DATE_FORMAT(r.due_at, '%Y-%m-%d %H:%i:%s'), r.renewals, DATE_FORMAT(r.return_date, '%Y-%m-%d'), r.returned_by, r.return_branch_id, IFNULL(r.outcome, '')
+	FROM borrow_records r LEFT JOIN book_copies c ON c.copy_id = r.copy_id`
+
+func scanLoan(row interface{ Scan(...any) error }, record *Borrow_records) error {
+	return row.Scan(&record.Borrow_id, &record.User_id, &record.User_type, &record.Book_id, &record.Copy_id, &record.Barcode, &record.Branch_id,
+		&record.Borrow_date, &record.Due_date, &record.Due_at, &record.Renewals, &record.Return_date, &record.Returned_by, &record.Return_branch_id, &record.Outcome)
+}
+
+func loadLoan(q querier, id int) (*Borrow_records, error) {
+	var record Borrow_records
+	if err := scanLoan(q.QueryRow(loanSelect+" WHERE r.borrow_id=?", id), &record); err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// Get loan
+func (h *HybridHandler5) GetLoanHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "invalid borrow id", http.StatusBadRequest)
+		return
+	}
+	record, err := loadLoan(h.MySQL.DB, id)
+	if err != nil {
+		writeLoadError(w, "loan", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, record)
+}
+
+// Loans of a student
+func (h *HybridHandler5) StudentLoansHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := studentResource.id(w, r)
+	if !ok {
+		return
+	}
+	if _, err := studentResource.load(h, id); err != nil {
+		studentResource.writeLoadError(w, err)
+		return
+	}
+	h.listLoans(w, r, "r.user_type='student' AND r.user_id=?", id)
+}
+
+// Loans of a lecturer
+func (h *HybridHandler5) LecturerLoansHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := lecturerResource.id(w, r)
+	if !ok {
+		return
+	}
+	if _, err := lecturerResource.load(h, id); err != nil {
+		lecturerResource.writeLoadError(w, err)
+		return
+	}
+	h.listLoans(w, r, "r.user_type='lecturer' AND r.user_id=?", id)
+}
+
+// Loans of a book
+func (h *HybridHandler5) BookLoansHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := bookResource.id(w, r)
+	if !ok {
+		return
+	}
+	if _, err := bookResource.load(h, id); err != nil {
+		bookResource.writeLoadError(w, err)
+		return
+	}
+	h.listLoans(w, r, "r.book_id=?", id)
+}
+
+// listLoans writes the loans matching where, newest first and paginated.
+// ?status=active keeps loans not yet returned, ?status=returned the others.
+func (h *HybridHandler5) listLoans(w http.ResponseWriter, r *http.Request, where string, id int) {
+	limit, offset, err := pagination(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	switch r.URL.Query().Get("status") {
+	case "", "all":
+	case "active":
+		where += " AND r.return_date IS NULL"
+	case "returned":
+		where += " AND r.return_date IS NOT NULL"
+	default:
+		http.Error(w, "status must be active, returned or all", http.StatusBadRequest)
+		return
+	}
+	rows, err := h.MySQL.DB.Query(loanSelect+" WHERE "+where+" ORDER BY r.borrow_id DESC LIMIT ? OFFSET ?", id, limit, offset)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	loans := []Borrow_records{}
+	for rows.Next() {
+		var record Borrow_records
+		if err := scanLoan(rows, &record); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		loans = append(loans, record)
+	}
+	if err := rows.Err(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, loans)
+}
@@ -0,0 +1,283 @@
+package managementsystem_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	managementsystem "managementsystem/managementsystem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"testing"
+)
+
+func TestHybridHandler5_Loans(t *testing.T) {
+
+	os.Setenv("REDIS_ADDR", "localhost:6379")
+	os.Setenv("MySQL_DSN", "root:root@tcp(127.0.0.1:3306)/management_sys")
+
+	redisInstance, err := managementsystem.ConnectRedis()
+	if err != nil {
+		panic(err)
+	}
+	mysqlinstance, err := managementsystem.ConnectMySQL()
+	if err != nil {
+		panic(err)
+	}
+	handler := &managementsystem.HybridHandler5{MySQL: mysqlinstance, Redis: redisInstance, Ctx: context.Background()}
+	router := handler.Router()
+
+	mysqlinstance.DB.Exec("DELETE FROM holds")
+	mysqlinstance.DB.Exec("DELETE FROM fines")
+	mysqlinstance.DB.Exec("DELETE FROM borrow_records")
+	mysqlinstance.DB.Exec("DELETE FROM book_copies")
+	mysqlinstance.DB.Exec("DELETE FROM books")
+	redisInstance.Client.FlushAll(context.Background())
+	mysqlinstance.DB.Exec("INSERT IGNORE INTO students (id, name, email, age, year) VALUES (?, ?, ?, ?, ?)", 501, "reader", "reader501@gmail.com", 20, 1)
+
+	// 501 borrows two books and returns the first
+	var loans []managementsystem.Borrow_records
+	for _, title := range []string{"GoLang", "Rust"} {
+		body, _ := json.Marshal(managementsystem.Book{Title: title, Author: "Alice", Available_copies: 1})
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/books", bytes.NewBuffer(body)))
+		var book managementsystem.Book
+		if err := json.NewDecoder(w.Body).Decode(&book); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+
+		body, _ = json.Marshal(managementsystem.Borrow_records{User_id: 501, User_type: "student", Book_id: book.Book_id})
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/borrow", bytes.NewBuffer(body)))
+		if w.Code != http.StatusCreated {
+			t.Fatalf("Expected created status , got %d: %s", w.Code, w.Body.String())
+		}
+		var loan managementsystem.Borrow_records
+		if err := json.NewDecoder(w.Body).Decode(&loan); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		if loan.Borrow_id <= 0 || loan.Borrow_date == "" || loan.Due_date <= loan.Borrow_date || loan.Return_date != nil {
+			t.Fatalf("Expected a new loan with its dates, got %+v", loan)
+		}
+		loans = append(loans, loan)
+	}
+	body, _ := json.Marshal(managementsystem.Borrow_records{User_id: 501, User_type: "student", Book_id: loans[0].Book_id})
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/return", bytes.NewBuffer(body)))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected created status , got %d: %s", w.Code, w.Body.String())
+	}
+
+	tests := []struct {
+		name   string // description of this test case
+		path   string
+		status int
+		ids    []int // borrow ids expected, newest first
+	}{
+		{
+			name:   "all loans of the student",
+			path:   "/students/501/borrows",
+			status: http.StatusOK,
+			ids:    []int{loans[1].Borrow_id, loans[0].Borrow_id},
+		},
+		{
+			name:   "active loans",
+			path:   "/students/501/borrows?status=active",
+			status: http.StatusOK,
+			ids:    []int{loans[1].Borrow_id},
+		},
+		{
+			name:   "returned loans",
+			path:   "/students/501/borrows?status=returned",
+			status: http.StatusOK,
+			ids:    []int{loans[0].Borrow_id},
+		},
+		{
+			name:   "second page",
+			path:   "/students/501/borrows?limit=1&offset=1",
+			status: http.StatusOK,
+			ids:    []int{loans[0].Borrow_id},
+		},
+		{
+			name:   "loans of a book",
+			path:   "/books/" + strconv.Itoa(loans[1].Book_id) + "/borrows",
+			status: http.StatusOK,
+			ids:    []int{loans[1].Borrow_id},
+		},
+		{
+			name:   "invalid status",
+			path:   "/students/501/borrows?status=lost",
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "unknown lecturer",
+			path:   "/lecturers/987654/borrows",
+			status: http.StatusNotFound,
+		},
+		{
+			name:   "one loan",
+			path:   "/borrows/" + strconv.Itoa(loans[0].Borrow_id),
+			status: http.StatusOK,
+		},
+		{
+			name:   "unknown loan",
+			path:   "/borrows/987654",
+			status: http.StatusNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			if w.Code != tt.status {
+				t.Fatalf("Expected status %d, got %d: %s", tt.status, w.Code, w.Body.String())
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+			if tt.ids == nil {
+				var loan managementsystem.Borrow_records
+				if err := json.NewDecoder(w.Body).Decode(&loan); err != nil {
+					t.Fatalf("failed to decode response: %v", err)
+				}
+				if loan.Return_date == nil || loan.Barcode == "" {
+					t.Fatalf("Expected a returned loan with its barcode, got %+v", loan)
+				}
+				return
+			}
+			var got []managementsystem.Borrow_records
+			if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if len(got) != len(tt.ids) {
+				t.Fatalf("Expected %d loans, got %d", len(tt.ids), len(got))
+			}
+			for i, id := range tt.ids {
+				if got[i].Borrow_id != id {
+					t.Fatalf("Expected loan %d at %d, got %d", id, i, got[i].Borrow_id)
+				}
+			}
+		})
+	}
+}
+
+func TestHybridHandler5_ReturnByBorrowID(t *testing.T) {
+
+	os.Setenv("REDIS_ADDR", "localhost:6379")
+	os.Setenv("MySQL_DSN", "root:root@tcp(127.0.0.1:3306)/management_sys")
+
+	redisInstance, err := managementsystem.ConnectRedis()
+	if err != nil {
+		panic(err)
+	}
+	mysqlinstance, err := managementsystem.ConnectMySQL()
+	if err != nil {
+		panic(err)
+	}
+	handler := &managementsystem.HybridHandler5{MySQL: mysqlinstance, Redis: redisInstance, Ctx: context.Background()}
+	router := handler.Router()
+
+	mysqlinstance.DB.Exec("DELETE FROM holds")
+	mysqlinstance.DB.Exec("DELETE FROM fines")
+	mysqlinstance.DB.Exec("DELETE FROM borrow_records")
+	mysqlinstance.DB.Exec("DELETE FROM book_copies")
+	mysqlinstance.DB.Exec("DELETE FROM books")
+	redisInstance.Client.FlushAll(context.Background())
+	mysqlinstance.DB.Exec("INSERT IGNORE INTO students (id, name, email, age, year) VALUES (?, ?, ?, ?, ?)", 501, "reader", "reader501@gmail.com", 20, 1)
+
+	// 501 borrows both copies of one book, and a third copy stays on the shelf
+	body, _ := json.Marshal(managementsystem.Book{Title: "GoLang", Author: "Alice", Available_copies: 3})
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/books", bytes.NewBuffer(body)))
+	var book managementsystem.Book
+	if err := json.NewDecoder(w.Body).Decode(&book); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	var loans []managementsystem.Borrow_records
+	for i := 0; i < 2; i++ {
+		body, _ := json.Marshal(managementsystem.Borrow_records{User_id: 501, User_type: "student", Book_id: book.Book_id})
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/borrow", bytes.NewBuffer(body)))
+		var loan managementsystem.Borrow_records
+		if err := json.NewDecoder(w.Body).Decode(&loan); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		loans = append(loans, loan)
+	}
+	var shelved string
+	mysqlinstance.DB.QueryRow("SELECT barcode FROM book_copies WHERE book_id=? AND status='available'", book.Book_id).Scan(&shelved)
+
+	tests := []struct {
+		name      string // description of this test case
+		body      string
+		status    int
+		available int // available_copies of the book afterwards
+	}{
+		{
+			name:      "return one loan by borrow id",
+			body:      `{"borrow_id":` + strconv.Itoa(loans[1].Borrow_id) + `,"processed_by":"desk 1"}`,
+			status:    http.StatusCreated,
+			available: 2,
+		},
+		{
+			name:      "the same loan twice",
+			body:      `{"borrow_id":` + strconv.Itoa(loans[1].Borrow_id) + `}`,
+			status:    http.StatusConflict,
+			available: 2,
+		},
+		{
+			name:      "a copy that is not on loan",
+			body:      `{"barcode":"` + shelved + `"}`,
+			status:    http.StatusConflict,
+			available: 2,
+		},
+		{
+			name:      "unknown loan",
+			body:      `{"borrow_id":987654}`,
+			status:    http.StatusNotFound,
+			available: 2,
+		},
+		{
+			name:      "nothing named",
+			body:      `{"processed_by":"desk 1"}`,
+			status:    http.StatusBadRequest,
+			available: 2,
+		},
+		{
+			name:      "the other loan by barcode",
+			body:      `{"barcode":"` + loans[0].Barcode + `"}`,
+			status:    http.StatusCreated,
+			available: 3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/return", bytes.NewBufferString(tt.body)))
+			if w.Code != tt.status {
+				t.Fatalf("Expected status %d, got %d: %s", tt.status, w.Code, w.Body.String())
+			}
+
+			w = httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/books/"+strconv.Itoa(book.Book_id), nil))
+			var got managementsystem.Book
+			if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if got.Available_copies != tt.available {
+				t.Fatalf("Expected %d available copies, got %d", tt.available, got.Available_copies)
+			}
+		})
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/borrows/"+strconv.Itoa(loans[1].Borrow_id), nil))
+	var loan managementsystem.Borrow_records
+	if err := json.NewDecoder(w.Body).Decode(&loan); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if loan.Returned_by == nil || *loan.Returned_by != "desk 1" {
+		t.Fatalf("Expected the return to be processed by desk 1, got %v", loan.Returned_by)
+	}
+}
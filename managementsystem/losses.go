@@ -0,0 +1,229 @@
+package managementsystem
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"managementsystem/validation"
+)
+
+// LostReport closes a loan whose book will not come back. Fee overrides the
+// replacement fee of the loan policy.
+type LostReport struct {
+	Fee          *int   `json:"fee" validate:"omitempty,min=0"`
+	Note         string `json:"note" validate:"trimmed,max=255"`
+	Processed_by string `json:"processed_by" validate:"trimmed,max=100"`
+}
+
+// DamageReport returns a loan damaged. The copy goes to repair when Repair
+// is set and back on the shelf otherwise; Fee, if any, is charged to the
+// borrower.
+type DamageReport struct {
+	Condition_note string `json:"condition_note" validate:"trimmed,required,max=255"`
+	Repair         bool   `json:"repair"`
+	Fee            int    `json:"fee" validate:"min=0"`
+	Processed_by   string `json:"processed_by" validate:"trimmed,max=100"`
+}
+
+// LoanClosed is a loan closed as lost or damaged with the fines it cost.
+type LoanClosed struct {
+	Status string          `json:"status"`
+	Loan   *Borrow_records `json:"loan"`
+	Fines  []Fine          `json:"fines"`
+}
+
+// openLoan locks the loan of the {id} route, answering 404 when there is
+// none and 409 when it is closed.
+func openLoan(w http.ResponseWriter, r *http.Request, tx querier) (*Borrow_records, bool) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "invalid borrow id", http.StatusBadRequest)
+		return nil, false
+	}
+	record, err := findReturn(tx, ReturnRequest{Borrow_id: id})
+	if err != nil {
+		writeError(w, err)
+		return nil, false
+	}
+	return record, true
+}
+
+// Report a loan lost
+func (h *HybridHandler5) LostLoanHandler(w http.ResponseWriter, r *http.Request) {
+	var report LostReport
+	if err := json.NewDecoder(r.Body).Decode(&report); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := validation.Struct(report); err != nil {
+		writeValidationError(w, r, err)
+		return
+	}
+	tx, err := h.MySQL.DB.Begin()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	record, ok := openLoan(w, r, tx)
+	if !ok {
+		return
+	}
+	policy, err := loanPolicy(tx, record.User_type)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	fee := policy.ReplacementFee
+	if report.Fee != nil {
+		fee = *report.Fee
+	}
+	closed := LoanClosed{Status: "Book lost", Loan: record, Fines: []Fine{}}
+	// the copy leaves the inventory, and overdue days up to now still count
+	overdue, err := closeLoan(tx, record, loanLost, copyLost, 0, report.Processed_by)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if overdue != nil {
+		closed.Fines = append(closed.Fines, *overdue)
+	}
+	if record.Copy_id != 0 {
+		// a loss reported without a note keeps the note the copy had
+		if _, err := tx.Exec("UPDATE book_copies SET condition_note=IFNULL(NULLIF(?, ''), condition_note) WHERE copy_id=?", report.Note, record.Copy_id); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	if fee > 0 {
+		fine, err := chargeFine(tx, record, fineLost, fee, report.Note)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		closed.Fines = append(closed.Fines, *fine)
+	}
+	if err := tx.Commit(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	h.cacheDel(bookResource.cacheKey(record.Book_id))
+	writeJSON(w, http.StatusOK, closed)
+}
+
+// Return a loan damaged
+func (h *HybridHandler5) DamagedReturnHandler(w http.ResponseWriter, r *http.Request) {
+	var report DamageReport
+	if err := json.NewDecoder(r.Body).Decode(&report); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := validation.Struct(report); err != nil {
+		writeValidationError(w, r, err)
+		return
+	}
+	tx, err := h.MySQL.DB.Begin()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	record, ok := openLoan(w, r, tx)
+	if !ok {
+		return
+	}
+	status := copyAvailable
+	if report.Repair {
+		status = copyInRepair
+	}
+	// the condition is set first so a copy back on the shelf goes to the hold
+	// queue already marked damaged
+	if record.Copy_id != 0 {
+		if _, err := tx.Exec("UPDATE book_copies SET copy_condition='damaged', condition_note=? WHERE copy_id=?", report.Condition_note, record.Copy_id); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	closed := LoanClosed{Status: "Book returned damaged", Loan: record, Fines: []Fine{}}
+	overdue, err := closeLoan(tx, record, loanDamaged, status, 0, report.Processed_by)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if overdue != nil {
+		closed.Fines = append(closed.Fines, *overdue)
+	}
+	if report.Fee > 0 {
+		fine, err := chargeFine(tx, record, fineDamage, report.Fee, report.Condition_note)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		closed.Fines = append(closed.Fines, *fine)
+	}
+	if err := tx.Commit(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	h.cacheDel(bookResource.cacheKey(record.Book_id))
+	writeJSON(w, http.StatusOK, closed)
+}
+
+// Put a repaired copy back on the shelf
+func (h *HybridHandler5) RepairedCopyHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := copyResource.id(w, r)
+	if !ok {
+		return
+	}
+	body := struct {
+		Condition      string `json:"condition" validate:"enum=new|good|fair|poor|damaged"`
+		Condition_note string `json:"condition_note" validate:"trimmed,max=255"`
+	}{Condition: "good"}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := validation.Struct(body); err != nil {
+		writeValidationError(w, r, err)
+		return
+	}
+	tx, err := h.MySQL.DB.Begin()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	c, err := loadCopy(tx, "copy_id=?", id)
+	if err != nil {
+		copyResource.writeLoadError(w, err)
+		return
+	}
+	if c.Status != copyInRepair {
+		http.Error(w, "copy is "+c.Status+", not in repair", http.StatusConflict)
+		return
+	}
+	if _, err := tx.Exec("UPDATE book_copies SET status=?, copy_condition=?, condition_note=NULLIF(?, '') WHERE copy_id=?", copyAvailable, body.Condition, body.Condition_note, id); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	// the copy goes to the first waiting hold, if any
+	if err := refreshHolds(tx, c.Book_id); err != nil {
+		writeError(w, err)
+		return
+	}
+	if c, err = loadCopy(tx, "copy_id=?", id); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	h.cacheDel(bookResource.cacheKey(c.Book_id))
+	writeJSON(w, http.StatusOK, c)
+}
@@ -0,0 +1,160 @@
+package managementsystem_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	managementsystem "managementsystem/managementsystem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"testing"
+)
+
+func TestHybridHandler5_LostAndDamaged(t *testing.T) {
+
+	os.Setenv("REDIS_ADDR", "localhost:6379")
+	os.Setenv("MySQL_DSN", "root:root@tcp(127.0.0.1:3306)/management_sys")
+
+	redisInstance, err := managementsystem.ConnectRedis()
+	if err != nil {
+		panic(err)
+	}
+	mysqlinstance, err := managementsystem.ConnectMySQL()
+	if err != nil {
+		panic(err)
+	}
+	handler := &managementsystem.HybridHandler5{MySQL: mysqlinstance, Redis: redisInstance, Ctx: context.Background()}
+	router := handler.Router()
+
+	mysqlinstance.DB.Exec("DELETE FROM holds")
+	mysqlinstance.DB.Exec("DELETE FROM fines")
+	mysqlinstance.DB.Exec("DELETE FROM borrow_records")
+	mysqlinstance.DB.Exec("DELETE FROM book_copies")
+	mysqlinstance.DB.Exec("DELETE FROM books")
+	redisInstance.Client.FlushAll(context.Background())
+	mysqlinstance.DB.Exec("INSERT IGNORE INTO students (id, name, email, age, year) VALUES (?, ?, ?, ?, ?)", 501, "reader", "reader501@gmail.com", 20, 1)
+
+	// 501 borrows two of three copies
+	body, _ := json.Marshal(managementsystem.Book{Title: "GoLang", Author: "Alice", Available_copies: 3})
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/books", bytes.NewBuffer(body)))
+	var book managementsystem.Book
+	if err := json.NewDecoder(w.Body).Decode(&book); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	var loans []managementsystem.Borrow_records
+	for i := 0; i < 2; i++ {
+		body, _ := json.Marshal(managementsystem.Borrow_records{User_id: 501, User_type: "student", Book_id: book.Book_id})
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/borrow", bytes.NewBuffer(body)))
+		var loan managementsystem.Borrow_records
+		if err := json.NewDecoder(w.Body).Decode(&loan); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		loans = append(loans, loan)
+	}
+
+	tests := []struct {
+		name       string // description of this test case
+		path       string
+		body       string
+		status     int
+		fines      []string // kinds of the fines charged
+		copyID     int
+		copyStatus string // status of the copy afterwards
+		available  int    // available_copies of the book afterwards
+	}{
+		{
+			name:       "lost at the policy fee",
+			path:       "/borrows/" + strconv.Itoa(loans[0].Borrow_id) + "/lost",
+			body:       `{"note":"left on a train"}`,
+			status:     http.StatusOK,
+			fines:      []string{"lost"},
+			copyID:     loans[0].Copy_id,
+			copyStatus: "lost",
+			available:  1,
+		},
+		{
+			name:      "lost twice",
+			path:      "/borrows/" + strconv.Itoa(loans[0].Borrow_id) + "/lost",
+			body:      `{}`,
+			status:    http.StatusConflict,
+			available: 1,
+		},
+		{
+			name:      "damaged without a note",
+			path:      "/borrows/" + strconv.Itoa(loans[1].Borrow_id) + "/damaged",
+			body:      `{"repair":true}`,
+			status:    http.StatusBadRequest,
+			available: 1,
+		},
+		{
+			name:       "damaged and sent to repair",
+			path:       "/borrows/" + strconv.Itoa(loans[1].Borrow_id) + "/damaged",
+			body:       `{"condition_note":"water damage","repair":true,"fee":500}`,
+			status:     http.StatusOK,
+			fines:      []string{"damage"},
+			copyID:     loans[1].Copy_id,
+			copyStatus: "in_repair",
+			available:  1,
+		},
+		{
+			name:       "repaired",
+			path:       "/copies/" + strconv.Itoa(loans[1].Copy_id) + "/repaired",
+			body:       `{}`,
+			status:     http.StatusOK,
+			copyID:     loans[1].Copy_id,
+			copyStatus: "available",
+			available:  2,
+		},
+		{
+			name:      "unknown loan",
+			path:      "/borrows/987654/lost",
+			body:      `{}`,
+			status:    http.StatusNotFound,
+			available: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, tt.path, bytes.NewBufferString(tt.body)))
+			if w.Code != tt.status {
+				t.Fatalf("Expected status %d, got %d: %s", tt.status, w.Code, w.Body.String())
+			}
+			if tt.fines != nil {
+				var closed managementsystem.LoanClosed
+				if err := json.NewDecoder(w.Body).Decode(&closed); err != nil {
+					t.Fatalf("failed to decode response: %v", err)
+				}
+				if closed.Loan.Return_date == nil || len(closed.Fines) != len(tt.fines) {
+					t.Fatalf("Expected a closed loan with fines %v, got %+v", tt.fines, closed)
+				}
+				for i, kind := range tt.fines {
+					if closed.Fines[i].Kind != kind || closed.Fines[i].Amount <= 0 {
+						t.Fatalf("Expected fines %v, got %+v", tt.fines, closed.Fines)
+					}
+				}
+			}
+			if tt.copyStatus != "" {
+				var status string
+				mysqlinstance.DB.QueryRow("SELECT status FROM book_copies WHERE copy_id=?", tt.copyID).Scan(&status)
+				if status != tt.copyStatus {
+					t.Fatalf("Expected the copy %s, got %s", tt.copyStatus, status)
+				}
+			}
+
+			w = httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/books/"+strconv.Itoa(book.Book_id), nil))
+			var got managementsystem.Book
+			if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if got.Available_copies != tt.available {
+				t.Fatalf("Expected %d available copies, got %d", tt.available, got.Available_copies)
+			}
+		})
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"net/http"
 	"os"
+	"time"
 
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/gorilla/mux"
@@ -39,6 +40,114 @@ func ConnectRedis() (*RedisInstance5, error) {
 	})
 	return &RedisInstance5{Client: rdb}, nil
 }
+
+// Router returns the mux router with every route of the service.
+func (h *HybridHandler5) Router() *mux.Router {
+	r := mux.NewRouter()
+	// students, lecturers and books get create/get/list/update/patch/delete
+	studentResource.Register(r, h)
+	lecturerResource.Register(r, h)
+	r.HandleFunc("/books/isbn/{isbn}", h.GetBookByISBNHandler).Methods("GET")
+	r.HandleFunc("/books/import", h.ImportBooksHandler).Methods("POST")
+	bookResource.Register(r, h)
+	r.HandleFunc("/books/{id}/copies", h.ListCopiesHandler).Methods("GET")
+	r.HandleFunc("/books/{id}/copies", h.AddCopyHandler).Methods("POST")
+	r.HandleFunc("/books/{id}/availability", h.BookAvailabilityHandler).Methods("GET")
+	r.HandleFunc("/copies/{id}", h.GetCopyHandler).Methods("GET")
+	r.HandleFunc("/copies/{id}", h.UpdateCopyHandler).Methods("PATCH")
+	r.HandleFunc("/copies/{id}/retire", h.RetireCopyHandler).Methods("POST")
+	r.HandleFunc("/copies/{id}/repaired", h.RepairedCopyHandler).Methods("POST")
+	r.HandleFunc("/books/{id}/holds", h.BookHoldsHandler).Methods("GET")
+	r.HandleFunc("/books/{id}/holds", h.PlaceHoldHandler).Methods("POST")
+	r.HandleFunc("/holds/{id}", h.GetHoldHandler).Methods("GET")
+	r.HandleFunc("/holds/{id}", h.CancelHoldHandler).Methods("DELETE")
+	branchResource.Register(r, h)
+	r.HandleFunc("/transfers", h.CreateTransferHandler).Methods("POST")
+	r.HandleFunc("/transfers", h.ListTransfersHandler).Methods("GET")
+	r.HandleFunc("/transfers/{id}", h.GetTransferHandler).Methods("GET")
+	r.HandleFunc("/transfers/{id}/receive", h.ReceiveTransferHandler).Methods("POST")
+	r.HandleFunc("/transfers/{id}/cancel", h.CancelTransferHandler).Methods("POST")
+	authorResource.Register(r, h)
+	r.HandleFunc("/authors/{id}/books", h.AuthorBooksHandler).Methods("GET")
+	categoryResource.Register(r, h)
+	r.HandleFunc("/categories/{id}/books", h.CategoryBooksHandler).Methods("GET")
+	r.HandleFunc("/acquisitions", h.SuggestAcquisitionHandler).Methods("POST")
+	r.HandleFunc("/acquisitions", h.ListAcquisitionsHandler).Methods("GET")
+	r.HandleFunc("/acquisitions/{id}", h.GetAcquisitionHandler).Methods("GET")
+	r.HandleFunc("/acquisitions/{id}/approve", h.ApproveAcquisitionHandler).Methods("POST")
+	r.HandleFunc("/acquisitions/{id}/reject", h.RejectAcquisitionHandler).Methods("POST")
+	r.HandleFunc("/acquisitions/{id}/order", h.OrderAcquisitionHandler).Methods("POST")
+	r.HandleFunc("/acquisitions/{id}/receive", h.ReceiveAcquisitionHandler).Methods("POST")
+
+	// for departments
+	departmentResource.Register(r, h)
+	r.HandleFunc("/departments/{id}/summary", h.DepartmentSummaryHandler).Methods("GET")
+
+	// for designations and promotions
+	designationResource.Register(r, h)
+	r.HandleFunc("/lecturers/{id}/history", h.LecturerHistoryHandler).Methods("GET")
+	r.HandleFunc("/lecturers/{id}/promotions", h.PromoteLecturerHandler).Methods("POST")
+	r.HandleFunc("/reports/promotions", h.PromotionReportHandler).Methods("GET")
+
+	// for timetable
+	courseResource.Register(r, h)
+	roomResource.Register(r, h)
+	r.HandleFunc("/sessions/check", h.CheckSessionHandler).Methods("POST")
+	sessionResource.Register(r, h)
+	r.HandleFunc("/courses/{id}/enrollments", h.EnrollStudentHandler).Methods("POST")
+	r.HandleFunc("/courses/{id}/enrollments/{student_id}", h.UnenrollStudentHandler).Methods("DELETE")
+	r.HandleFunc("/timetable/conflicts", h.TimetableConflictsHandler).Methods("GET")
+	r.HandleFunc("/students/{id}/timetable", h.StudentTimetableHandler).Methods("GET")
+	r.HandleFunc("/students/{id}/timetable.ics", h.StudentTimetableHandler).Methods("GET")
+	r.HandleFunc("/lecturers/{id}/timetable", h.LecturerTimetableHandler).Methods("GET")
+	r.HandleFunc("/lecturers/{id}/timetable.ics", h.LecturerTimetableHandler).Methods("GET")
+
+	// for library
+	r.HandleFunc("/borrow", h.BorrowBook).Methods("POST")
+	r.HandleFunc("/return", h.ReturnBook).Methods("POST")
+	r.HandleFunc("/borrows/batch", h.CheckoutHandler).Methods("POST")
+	r.HandleFunc("/borrows/{id}", h.GetLoanHandler).Methods("GET")
+	r.HandleFunc("/borrows/{id}/renew", h.RenewLoanHandler).Methods("POST")
+	r.HandleFunc("/borrows/{id}/lost", h.LostLoanHandler).Methods("POST")
+	r.HandleFunc("/borrows/{id}/damaged", h.DamagedReturnHandler).Methods("POST")
+	r.HandleFunc("/students/{id}/borrows", h.StudentLoansHandler).Methods("GET")
+	r.HandleFunc("/lecturers/{id}/borrows", h.LecturerLoansHandler).Methods("GET")
+	r.HandleFunc("/books/{id}/borrows", h.BookLoansHandler).Methods("GET")
+	r.HandleFunc("/users/{type}/{id}/fines", h.UserFinesHandler).Methods("GET")
+	r.HandleFunc("/fines/{id}/pay", h.PayFineHandler).Methods("POST")
+	r.HandleFunc("/fines/{id}/waive", h.WaiveFineHandler).Methods("POST")
+	r.HandleFunc("/courses/{id}/reserves", h.CreateReserveHandler).Methods("POST")
+	r.HandleFunc("/courses/{id}/reserves", h.CourseReservesHandler).Methods("GET")
+	r.HandleFunc("/reserves/{id}/release", h.ReleaseReserveHandler).Methods("POST")
+	r.HandleFunc("/users/{type}/{id}/notifications", h.UserNotificationsHandler).Methods("GET")
+	r.HandleFunc("/notifications/{id}/read", h.ReadNotificationHandler).Methods("POST")
+	r.HandleFunc("/memberships", h.ListMembershipsHandler).Methods("GET")
+	r.HandleFunc("/users/{type}/{id}/membership", h.GetMembershipHandler).Methods("GET")
+	r.HandleFunc("/users/{type}/{id}/membership", h.MembershipExpiryHandler).Methods("PUT")
+	r.HandleFunc("/users/{type}/{id}/membership/suspend", h.SuspendMembershipHandler).Methods("POST")
+	r.HandleFunc("/users/{type}/{id}/membership/reinstate", h.ReinstateMembershipHandler).Methods("POST")
+	r.HandleFunc("/reports/library/most-borrowed", h.MostBorrowedReportHandler).Methods("GET")
+	r.HandleFunc("/reports/library/most-borrowed.csv", h.MostBorrowedReportHandler).Methods("GET")
+	r.HandleFunc("/reports/library/departments", h.DepartmentBorrowsReportHandler).Methods("GET")
+	r.HandleFunc("/reports/library/departments.csv", h.DepartmentBorrowsReportHandler).Methods("GET")
+	r.HandleFunc("/reports/library/loan-duration", h.LoanDurationReportHandler).Methods("GET")
+	r.HandleFunc("/reports/library/loan-duration.csv", h.LoanDurationReportHandler).Methods("GET")
+	r.HandleFunc("/reports/library/overdue", h.OverdueRateReportHandler).Methods("GET")
+	r.HandleFunc("/reports/library/overdue.csv", h.OverdueRateReportHandler).Methods("GET")
+	r.HandleFunc("/reports/library/utilization", h.UtilizationReportHandler).Methods("GET")
+	r.HandleFunc("/reports/library/utilization.csv", h.UtilizationReportHandler).Methods("GET")
+	r.HandleFunc("/library/calendar", h.LibraryCalendarHandler).Methods("GET")
+	r.HandleFunc("/library/calendar/weekdays", h.ClosedWeekdaysHandler).Methods("PUT")
+	holidayResource.Register(r, h)
+	r.HandleFunc("/library/audit", h.AuditHandler).Methods("GET")
+	r.HandleFunc("/library/audit/repair", h.RepairAuditHandler).Methods("POST")
+	r.HandleFunc("/stocktakes", h.StartStocktakeHandler).Methods("POST")
+	r.HandleFunc("/stocktakes/{id}/scans", h.ScanStocktakeHandler).Methods("POST")
+	r.HandleFunc("/stocktakes/{id}/report", h.StocktakeReportHandler).Methods("GET")
+	r.HandleFunc("/stocktakes/{id}/close", h.CloseStocktakeHandler).Methods("POST")
+	return r
+}
+
 func Managementsystem() {
 	godotenv.Load()
 
@@ -52,24 +161,10 @@ func Managementsystem() {
 	}
 	handler := HybridHandler5{MySQL: mysqlinstance, Redis: redisInstance, Ctx: context.Background()}
 
-	r := mux.NewRouter()
-	// for students
-	r.HandleFunc("/students", handler.CreateStudentsHandler).Methods("POST")
-	r.HandleFunc("/students/{id}", handler.GetStudentsHandler).Methods("GET")
-	r.HandleFunc("/students/{id}", handler.UpdatestudentsHandler).Methods("PUT")
-	r.HandleFunc("/students/{id}", handler.DeleteStudentsHandler).Methods("DELETE")
-
-	// for lecturers
-	r.HandleFunc("/lecturers", handler.CreateLecturersHandler).Methods("POST")
-	r.HandleFunc("/lecturers/{id}", handler.GetLecturersHandler).Methods("GET")
-	r.HandleFunc("/lecturers/{id}", handler.UpdateLecturersHandler).Methods("PUT")
-	r.HandleFunc("/lecturers/{id}", handler.DeleteLecturersHandler3).Methods("DELETE")
-
-	// for library
-	r.HandleFunc("/books", handler.CreateBookHandler).Methods("POST")
-	r.HandleFunc("/books/{id}", handler.GetBookHandler).Methods("GET")
-	r.HandleFunc("/borrow", handler.BorrowBook).Methods("POST")
-	r.HandleFunc("/return", handler.ReturnBook).Methods("POST")
+	r := handler.Router()
+	go handler.AuditJob(24 * time.Hour)
+	go handler.ReserveReleaseJob(time.Hour)
+	go handler.MembershipJob(24 * time.Hour)
 
 	fmt.Println("Server running on port :8080")
 	http.ListenAndServe(":8080", r)
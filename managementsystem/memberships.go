@@ -0,0 +1,349 @@
+package managementsystem
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"managementsystem/validation"
+)
+
+// Membership statuses. A suspended member may not borrow until staff, or
+// for an automatic suspension the loan policy, reinstate them; an expired
+// membership is an active one past its expiry date.
+const (
+	membershipActive    = "active"
+	membershipSuspended = "suspended"
+	membershipExpired   = "expired"
+)
+
+// Membership is the library membership of a student or lecturer. Users
+// without one on record are active members with no expiry date.
+type Membership struct {
+	UserType    string  `json:"user_type"`
+	UserID      int     `json:"user_id"`
+	Status      string  `json:"status"`
+	Automatic   bool    `json:"automatic"` // suspended by the loan policy, lifted once settled
+	Reason      string  `json:"reason"`
+	SuspendedBy string  `json:"suspended_by"`
+	SuspendedAt *string `json:"suspended_at"`
+	ExpiresOn   *string `json:"expires_on"`
+}
+
+// Suspension suspends a membership by hand.
+type Suspension struct {
+	Reason      string `json:"reason" validate:"trimmed,required,max=255"`
+	SuspendedBy string `json:"suspended_by" validate:"trimmed,max=100"`
+}
+
+// MembershipExpiry sets the day a membership expires, or clears it.
+type MembershipExpiry struct {
+	ExpiresOn *string `json:"expires_on"`
+}
+
+const membershipSelect = `SELECT user_type, user_id, status, automatic, IFNULL(reason, ''), IFNULL(suspended_by, ''),
+	DATE_FORMAT(suspended_at, '%Y-%m-%d %H:%i:%s'), DATE_FORMAT(expires_on, '%Y-%m-%d')
+	FROM memberships`
+
+func scanMembership(row interface{ Scan(...any) error }, m *Membership) error {
+	if err := row.Scan(&m.UserType, &m.UserID, &m.Status, &m.Automatic, &m.Reason, &m.SuspendedBy, &m.SuspendedAt, &m.ExpiresOn); err != nil {
+		return err
+	}
+	if m.Status == membershipActive && m.ExpiresOn != nil && *m.ExpiresOn < time.Now().Format(time.DateOnly) {
+		m.Status = membershipExpired
+	}
+	return nil
+}
+
+// loadMembership reads the membership of a user, locking it when q is a
+// transaction.
+func loadMembership(q querier, userType string, userID int) (*Membership, error) {
+	var m Membership
+	err := scanMembership(q.QueryRow(membershipSelect+" WHERE user_type=? AND user_id=? FOR UPDATE", userType, userID), &m)
+	if errors.Is(err, sql.ErrNoRows) {
+		return &Membership{UserType: userType, UserID: userID, Status: membershipActive}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// setMembershipStatus suspends or reinstates a membership, keeping its
+// expiry date.
+func setMembershipStatus(q querier, userType string, userID int, status string, automatic bool, reason, by string) error {
+	_, err := q.Exec(`INSERT INTO memberships (user_type, user_id, status, automatic, reason, suspended_by, suspended_at)
+		VALUES (?, ?, ?, ?, NULLIF(?, ''), NULLIF(?, ''), IF(? = 'suspended', NOW(), NULL))
+		ON DUPLICATE KEY UPDATE status=VALUES(status), automatic=VALUES(automatic), reason=VALUES(reason),
+		suspended_by=VALUES(suspended_by), suspended_at=VALUES(suspended_at)`,
+		userType, userID, status, automatic, reason, by, status)
+	return err
+}
+
+// suspendAutomatically suspends the active membership of a borrower the
+// loan policy says should be, and reinstates an automatic suspension once
+// the borrower has settled up, then updates b to match.
+func suspendAutomatically(tx querier, policy LoanPolicy, userID int, b *Borrower) error {
+	m, err := loadMembership(tx, policy.UserType, userID)
+	if err != nil {
+		return err
+	}
+	reason := policy.Suspends(*b)
+	switch {
+	case reason != "" && m.Status == membershipActive:
+		if err := setMembershipStatus(tx, policy.UserType, userID, membershipSuspended, true, reason, ""); err != nil {
+			return err
+		}
+		if err := notify(tx, policy.UserType, userID, "membership_suspended", "Your library membership is suspended: "+reason+"."); err != nil {
+			return err
+		}
+		b.Membership, b.Reason = membershipSuspended, reason
+	case reason == "" && m.Status == membershipSuspended && m.Automatic:
+		if err := setMembershipStatus(tx, policy.UserType, userID, membershipActive, false, "", ""); err != nil {
+			return err
+		}
+		if err := notify(tx, policy.UserType, userID, "membership_reinstated", "Your library membership is active again."); err != nil {
+			return err
+		}
+		b.Membership, b.Reason = membershipActive, ""
+	}
+	return nil
+}
+
+// changeMembership runs change on the membership of the user in the path,
+// in a transaction after an automatic suspension is brought up to date,
+// and answers the membership it leaves.
+func (h *HybridHandler5) changeMembership(w http.ResponseWriter, r *http.Request, change func(tx querier, policy LoanPolicy, b Borrower, m *Membership) error) {
+	userType, userID, ok := userPath(w, r)
+	if !ok {
+		return
+	}
+	tx, err := h.MySQL.DB.Begin()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	policy, err := loanPolicy(tx, userType)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	b, err := loadBorrower(tx, userType, userID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !b.Exists {
+		http.Error(w, "no such "+userType, http.StatusNotFound)
+		return
+	}
+	if err := suspendAutomatically(tx, policy, userID, &b); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	m, err := loadMembership(tx, userType, userID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := change(tx, policy, b, m); err != nil {
+		writeError(w, err)
+		return
+	}
+	if m, err = loadMembership(tx, userType, userID); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, m)
+}
+
+// Membership of a user
+func (h *HybridHandler5) GetMembershipHandler(w http.ResponseWriter, r *http.Request) {
+	h.changeMembership(w, r, func(querier, LoanPolicy, Borrower, *Membership) error { return nil })
+}
+
+// Suspend a membership
+func (h *HybridHandler5) SuspendMembershipHandler(w http.ResponseWriter, r *http.Request) {
+	var s Suspension
+	if err := json.NewDecoder(r.Body).Decode(&s); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := validation.Struct(s); err != nil {
+		writeValidationError(w, r, err)
+		return
+	}
+	h.changeMembership(w, r, func(tx querier, _ LoanPolicy, _ Borrower, m *Membership) error {
+		if m.Status == membershipSuspended && !m.Automatic {
+			return newHTTPError(http.StatusConflict, "membership is already suspended")
+		}
+		if err := setMembershipStatus(tx, m.UserType, m.UserID, membershipSuspended, false, s.Reason, s.SuspendedBy); err != nil {
+			return err
+		}
+		return notify(tx, m.UserType, m.UserID, "membership_suspended", "Your library membership is suspended: "+s.Reason+".")
+	})
+}
+
+// Reinstate a suspended membership
+func (h *HybridHandler5) ReinstateMembershipHandler(w http.ResponseWriter, r *http.Request) {
+	h.changeMembership(w, r, func(tx querier, policy LoanPolicy, b Borrower, m *Membership) error {
+		if m.Status != membershipSuspended {
+			return newHTTPError(http.StatusConflict, "membership is %s, not suspended", m.Status)
+		}
+		// an automatic suspension would only come back on the next borrow
+		if reason := policy.Suspends(b); reason != "" {
+			return newHTTPError(http.StatusConflict, "membership stays suspended: %s", reason)
+		}
+		if err := setMembershipStatus(tx, m.UserType, m.UserID, membershipActive, false, "", ""); err != nil {
+			return err
+		}
+		return notify(tx, m.UserType, m.UserID, "membership_reinstated", "Your library membership is active again.")
+	})
+}
+
+// Set the expiry date of a membership
+func (h *HybridHandler5) MembershipExpiryHandler(w http.ResponseWriter, r *http.Request) {
+	var e MembershipExpiry
+	if err := json.NewDecoder(r.Body).Decode(&e); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if e.ExpiresOn != nil {
+		if _, err := time.Parse(time.DateOnly, *e.ExpiresOn); err != nil {
+			http.Error(w, "expires_on must be YYYY-MM-DD", http.StatusBadRequest)
+			return
+		}
+	}
+	h.changeMembership(w, r, func(tx querier, _ LoanPolicy, _ Borrower, m *Membership) error {
+		_, err := tx.Exec("INSERT INTO memberships (user_type, user_id, expires_on) VALUES (?, ?, ?) ON DUPLICATE KEY UPDATE expires_on=VALUES(expires_on)", m.UserType, m.UserID, e.ExpiresOn)
+		return err
+	})
+}
+
+// List suspended or expired memberships, by ?status=
+func (h *HybridHandler5) ListMembershipsHandler(w http.ResponseWriter, r *http.Request) {
+	limit, offset, err := pagination(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var where string
+	switch r.URL.Query().Get("status") {
+	case "", membershipSuspended:
+		where = "status='suspended'"
+	case membershipExpired:
+		where = "status='active' AND expires_on < CURDATE()"
+	default:
+		http.Error(w, "status must be suspended or expired", http.StatusBadRequest)
+		return
+	}
+	rows, err := h.MySQL.DB.Query(membershipSelect+" WHERE "+where+" ORDER BY updated_at DESC, user_type, user_id LIMIT ? OFFSET ?", limit, offset)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	memberships := []Membership{}
+	for rows.Next() {
+		var m Membership
+		if err := scanMembership(rows, &m); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		memberships = append(memberships, m)
+	}
+	if err := rows.Err(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, memberships)
+}
+
+// suspendMembers brings the automatic suspensions of everyone with overdue
+// books or unpaid fines, or suspended automatically, up to date, each in a
+// transaction of its own.
+func (h *HybridHandler5) suspendMembers() error {
+	rows, err := h.MySQL.DB.Query(`SELECT user_type, user_id FROM borrow_records WHERE return_date IS NULL AND due_date < CURDATE()
+		UNION SELECT user_type, user_id FROM fines WHERE status=?
+		UNION SELECT user_type, user_id FROM memberships WHERE status=? AND automatic`, fineUnpaid, membershipSuspended)
+	if err != nil {
+		return err
+	}
+	type user struct {
+		userType string
+		id       int
+	}
+	var users []user
+	for rows.Next() {
+		var u user
+		if err := rows.Scan(&u.userType, &u.id); err != nil {
+			rows.Close()
+			return err
+		}
+		users = append(users, u)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, u := range users {
+		if err := h.updateSuspension(u.userType, u.id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// updateSuspension brings the automatic suspension of a user up to date in
+// a transaction of its own, so that it is kept even when what follows, like
+// the borrow it refuses, is rolled back.
+func (h *HybridHandler5) updateSuspension(userType string, userID int) error {
+	tx, err := h.MySQL.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	policy, err := loanPolicy(tx, userType)
+	if err != nil {
+		return err
+	}
+	b, err := loadBorrower(tx, userType, userID)
+	if err != nil {
+		return err
+	}
+	if b.Exists {
+		if err := suspendAutomatically(tx, policy, userID, &b); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// MembershipJob brings automatic suspensions up to date every interval
+// until the handler's context is done.
+func (h *HybridHandler5) MembershipJob(every time.Duration) {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-h.Ctx.Done():
+			return
+		case <-ticker.C:
+			if err := h.suspendMembers(); err != nil {
+				log.Println("memberships:", err)
+			}
+		}
+	}
+}
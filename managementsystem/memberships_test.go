@@ -0,0 +1,181 @@
+package managementsystem_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	managementsystem "managementsystem/managementsystem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestHybridHandler5_Memberships(t *testing.T) {
+
+	os.Setenv("REDIS_ADDR", "localhost:6379")
+	os.Setenv("MySQL_DSN", "root:root@tcp(127.0.0.1:3306)/management_sys")
+
+	redisInstance, err := managementsystem.ConnectRedis()
+	if err != nil {
+		panic(err)
+	}
+	mysqlinstance, err := managementsystem.ConnectMySQL()
+	if err != nil {
+		panic(err)
+	}
+	handler := &managementsystem.HybridHandler5{MySQL: mysqlinstance, Redis: redisInstance, Ctx: context.Background()}
+	router := handler.Router()
+
+	mysqlinstance.DB.Exec("DELETE FROM memberships")
+	mysqlinstance.DB.Exec("DELETE FROM notifications")
+	mysqlinstance.DB.Exec("DELETE FROM holds")
+	mysqlinstance.DB.Exec("DELETE FROM fines")
+	mysqlinstance.DB.Exec("DELETE FROM borrow_records")
+	mysqlinstance.DB.Exec("DELETE FROM transfers")
+	mysqlinstance.DB.Exec("DELETE FROM book_copies")
+	mysqlinstance.DB.Exec("DELETE FROM books")
+	redisInstance.Client.FlushAll(context.Background())
+	mysqlinstance.DB.Exec("INSERT IGNORE INTO students (id, name, email, age, year) VALUES (?, ?, ?, ?, ?), (?, ?, ?, ?, ?), (?, ?, ?, ?, ?)",
+		1001, "reader", "reader1001@gmail.com", 20, 1, 1002, "reader", "reader1002@gmail.com", 20, 1, 1004, "reader", "reader1004@gmail.com", 20, 1)
+	mysqlinstance.DB.Exec("DELETE FROM students WHERE id=1003")
+
+	res, err := mysqlinstance.DB.Exec("INSERT INTO books(title, author) VALUES (?, ?)", "GoLang", "Alice")
+	if err != nil {
+		t.Fatalf("insert book fail: %v", err)
+	}
+	book_id, _ := res.LastInsertId()
+	_, err = mysqlinstance.DB.Exec("INSERT INTO book_copies(book_id, branch_id, barcode) VALUES (?, (SELECT id FROM branches WHERE code='MAIN'), ?), (?, (SELECT id FROM branches WHERE code='MAIN'), ?)", book_id, "GO-1", book_id, "GO-2")
+	if err != nil {
+		t.Fatalf("insert copies fail: %v", err)
+	}
+	// 1002 and 1004 kept a book forty days past its due date
+	mysqlinstance.DB.Exec("INSERT INTO borrow_records(user_id, user_type, book_id, borrow_date, due_date) VALUES (?, ?, ?, CURDATE() - INTERVAL 54 DAY, CURDATE() - INTERVAL 40 DAY), (?, ?, ?, CURDATE() - INTERVAL 54 DAY, CURDATE() - INTERVAL 40 DAY)", 1002, "student", book_id, 1004, "student", book_id)
+
+	send := func(method, path string, v any) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(v)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(method, path, bytes.NewBuffer(body)))
+		return w
+	}
+	borrow := func(userID int) *httptest.ResponseRecorder {
+		return send(http.MethodPost, "/borrow", managementsystem.Borrow_records{User_id: userID, User_type: "student", Book_id: int(book_id)})
+	}
+	yesterday := time.Now().AddDate(0, 0, -1).Format(time.DateOnly)
+
+	tests := []struct {
+		name   string // description of this test case
+		run    func() *httptest.ResponseRecorder
+		status int
+		want   string // membership status, or the reason a borrow is refused
+	}{
+		{
+			name:   "suspended by a refused borrow",
+			run:    func() *httptest.ResponseRecorder { return borrow(1004) },
+			status: http.StatusForbidden,
+			want:   "suspended",
+		},
+		{
+			name:   "suspended for an overdue book",
+			run:    func() *httptest.ResponseRecorder { return send(http.MethodGet, "/users/student/1002/membership", nil) },
+			status: http.StatusOK,
+			want:   "suspended",
+		},
+		{
+			name:   "suspended member borrows",
+			run:    func() *httptest.ResponseRecorder { return borrow(1002) },
+			status: http.StatusForbidden,
+			want:   "suspended",
+		},
+		{
+			name: "reinstate with the book still out",
+			run: func() *httptest.ResponseRecorder {
+				return send(http.MethodPost, "/users/student/1002/membership/reinstate", nil)
+			},
+			status: http.StatusConflict,
+		},
+		{
+			name:   "unknown student",
+			run:    func() *httptest.ResponseRecorder { return send(http.MethodGet, "/users/student/1003/membership", nil) },
+			status: http.StatusNotFound,
+		},
+		{
+			name: "suspend without a reason",
+			run: func() *httptest.ResponseRecorder {
+				return send(http.MethodPost, "/users/student/1001/membership/suspend", managementsystem.Suspension{})
+			},
+			status: http.StatusBadRequest,
+		},
+		{
+			name: "suspend",
+			run: func() *httptest.ResponseRecorder {
+				return send(http.MethodPost, "/users/student/1001/membership/suspend", managementsystem.Suspension{Reason: "damaged a reading room", SuspendedBy: "librarian"})
+			},
+			status: http.StatusOK,
+			want:   "suspended",
+		},
+		{
+			name: "suspend twice",
+			run: func() *httptest.ResponseRecorder {
+				return send(http.MethodPost, "/users/student/1001/membership/suspend", managementsystem.Suspension{Reason: "again"})
+			},
+			status: http.StatusConflict,
+		},
+		{
+			name:   "manually suspended member borrows",
+			run:    func() *httptest.ResponseRecorder { return borrow(1001) },
+			status: http.StatusForbidden,
+			want:   "suspended",
+		},
+		{
+			name: "reinstate",
+			run: func() *httptest.ResponseRecorder {
+				return send(http.MethodPost, "/users/student/1001/membership/reinstate", nil)
+			},
+			status: http.StatusOK,
+			want:   "active",
+		},
+		{
+			name: "expired",
+			run: func() *httptest.ResponseRecorder {
+				return send(http.MethodPut, "/users/student/1001/membership", managementsystem.MembershipExpiry{ExpiresOn: &yesterday})
+			},
+			status: http.StatusOK,
+			want:   "expired",
+		},
+		{
+			name:   "expired member borrows",
+			run:    func() *httptest.ResponseRecorder { return borrow(1001) },
+			status: http.StatusForbidden,
+			want:   "membership_expired",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := tt.run()
+			if w.Code != tt.status {
+				t.Fatalf("Expected status %d, got %d: %s", tt.status, w.Code, w.Body.String())
+			}
+			if tt.want == "" {
+				return
+			}
+			var got struct {
+				Status string `json:"status"`
+				Reason string `json:"reason"`
+			}
+			if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if (w.Code == http.StatusForbidden && got.Reason != tt.want) || (w.Code == http.StatusOK && got.Status != tt.want) {
+				t.Fatalf("Expected %s, got %+v", tt.want, got)
+			}
+		})
+	}
+
+	// the borrow was rolled back but the suspension it made was not
+	var status string
+	if err := mysqlinstance.DB.QueryRow("SELECT status FROM memberships WHERE user_type='student' AND user_id=1004").Scan(&status); err != nil || status != "suspended" {
+		t.Fatalf("Expected 1004's membership saved as suspended, got %q: %v", status, err)
+	}
+}
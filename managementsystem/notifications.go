@@ -0,0 +1,89 @@
+package managementsystem
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// Notification is a message for a student or lecturer, unread until they
+// mark it read.
+type Notification struct {
+	ID        int     `json:"id"`
+	UserType  string  `json:"user_type"`
+	UserID    int     `json:"user_id"`
+	Kind      string  `json:"kind"`
+	Message   string  `json:"message"`
+	CreatedAt string  `json:"created_at"`
+	ReadAt    *string `json:"read_at"`
+}
+
+const notificationSelect = "SELECT id, user_type, user_id, kind, message, DATE_FORMAT(created_at, '%Y-%m-%d %H:%i:%s'), DATE_FORMAT(read_at, '%Y-%m-%d %H:%i:%s') FROM notifications"
+
+func scanNotification(row interface{ Scan(...any) error }, n *Notification) error {
+	return row.Scan(&n.ID, &n.UserType, &n.UserID, &n.Kind, &n.Message, &n.CreatedAt, &n.ReadAt)
+}
+
+// notify leaves a message for a user.
+func notify(q querier, userType string, userID int, kind, message string) error {
+	_, err := q.Exec("INSERT INTO notifications (user_type, user_id, kind, message) VALUES (?, ?, ?, ?)", userType, userID, kind, message)
+	return err
+}
+
+// Notifications of a user, only the unread ones with ?unread=true
+func (h *HybridHandler5) UserNotificationsHandler(w http.ResponseWriter, r *http.Request) {
+	userType, userID, ok := userPath(w, r)
+	if !ok {
+		return
+	}
+	limit, offset, err := pagination(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	where := "user_type=? AND user_id=?"
+	if r.URL.Query().Get("unread") == "true" {
+		where += " AND read_at IS NULL"
+	}
+	rows, err := h.MySQL.DB.Query(notificationSelect+" WHERE "+where+" ORDER BY id DESC LIMIT ? OFFSET ?", userType, userID, limit, offset)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	notifications := []Notification{}
+	for rows.Next() {
+		var n Notification
+		if err := scanNotification(rows, &n); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		notifications = append(notifications, n)
+	}
+	if err := rows.Err(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, notifications)
+}
+
+// Mark a notification read
+func (h *HybridHandler5) ReadNotificationHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "invalid notification id", http.StatusBadRequest)
+		return
+	}
+	if _, err := h.MySQL.DB.Exec("UPDATE notifications SET read_at=NOW() WHERE id=? AND read_at IS NULL", id); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	var n Notification
+	if err := scanNotification(h.MySQL.DB.QueryRow(notificationSelect+" WHERE id=?", id), &n); err != nil {
+		writeLoadError(w, "notification", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, n)
+}
@@ -0,0 +1,190 @@
+package managementsystem
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// LoanPolicy is how long a user type may keep a book, what it costs to keep
+// it longer and who may borrow at all.
+type LoanPolicy struct {
+	UserType    string `json:"user_type"`
+	LoanDays    int    `json:"loan_days"`
+	FinePerDay  int    `json:"fine_per_day"`
+	MaxFine     int    `json:"max_fine"`
+	MaxLoans    int    `json:"max_loans"`
+	FineLimit   int    `json:"fine_limit"`  // unpaid fines above this block borrowing
+	PickupDays  int    `json:"pickup_days"` // how long a held copy is kept
+	MaxRenewals int    `json:"max_renewals"`
+	GraceDays   int    `json:"grace_days"` // a loan this many days overdue may still be renewed
+	// ReplacementFee is charged for a lost book unless staff set the fee
+	ReplacementFee int `json:"replacement_fee"`
+	// short loans of copies on course reserve
+	ShortLoanHours   int `json:"short_loan_hours"`
+	ShortFinePerHour int `json:"short_fine_per_hour"`
+	// a loan this many days overdue, or unpaid fines above SuspendFines,
+	// suspend the membership until they are settled
+	SuspendOverdueDays int `json:"suspend_overdue_days"`
+	SuspendFines       int `json:"suspend_fines"`
+}
+
+// Borrower is what the loan policy needs to know about a user.
+type Borrower struct {
+	Exists       bool
+	OpenLoans    int
+	OverdueLoans int
+	DaysOverdue  int // open days the longest overdue loan is overdue
+	UnpaidFines  int
+	Membership   string // status of the membership, active when empty
+	Reason       string // why the membership is suspended
+}
+
+// Reasons a borrow is refused.
+const (
+	refusedUnknownBorrower = "unknown_borrower"
+	refusedLoanLimit       = "loan_limit"
+	refusedOverdue         = "overdue_items"
+	refusedUnpaidFines     = "unpaid_fines"
+	refusedSuspended       = "suspended"
+	refusedExpired         = "membership_expired"
+)
+
+// BorrowRefusal explains why a user may not borrow.
+type BorrowRefusal struct {
+	Error  string `json:"Error"`
+	Reason string `json:"reason"`
+	Limit  int    `json:"limit,omitempty"`
+	Actual int    `json:"actual,omitempty"`
+}
+
+// OverdueFine is the fine for a book returned daysOverdue days late.
+func (p LoanPolicy) OverdueFine(daysOverdue int) int {
+	if daysOverdue <= 0 {
+		return 0
+	}
+	return min(daysOverdue*p.FinePerDay, p.MaxFine)
+}
+
+// ShortLoanFine is the fine for a short loan returned hoursOverdue hours
+// late, counting every hour begun.
+func (p LoanPolicy) ShortLoanFine(hoursOverdue int) int {
+	if hoursOverdue <= 0 {
+		return 0
+	}
+	return min(hoursOverdue*p.ShortFinePerHour, p.MaxFine)
+}
+
+// Check returns why b may not borrow another book, or nil if they may.
+func (p LoanPolicy) Check(b Borrower) *BorrowRefusal {
+	switch {
+	case !b.Exists:
+		return &BorrowRefusal{Error: "no such " + p.UserType, Reason: refusedUnknownBorrower}
+	case b.Membership == membershipSuspended:
+		return &BorrowRefusal{Error: "library membership is suspended: " + b.Reason, Reason: refusedSuspended}
+	case b.Membership == membershipExpired:
+		return &BorrowRefusal{Error: "library membership has expired", Reason: refusedExpired}
+	case b.OverdueLoans > 0:
+		return &BorrowRefusal{Error: fmt.Sprintf("%d overdue books must be returned first", b.OverdueLoans), Reason: refusedOverdue, Actual: b.OverdueLoans}
+	case b.UnpaidFines > p.FineLimit:
+		return &BorrowRefusal{Error: "unpaid fines must be settled first", Reason: refusedUnpaidFines, Limit: p.FineLimit, Actual: b.UnpaidFines}
+	case b.OpenLoans >= p.MaxLoans:
+		return &BorrowRefusal{Error: fmt.Sprintf("a %s may borrow at most %d books", p.UserType, p.MaxLoans), Reason: refusedLoanLimit, Limit: p.MaxLoans, Actual: b.OpenLoans}
+	}
+	return nil
+}
+
+// Suspends returns why b's membership is suspended automatically, or ""
+// if it is not.
+func (p LoanPolicy) Suspends(b Borrower) string {
+	switch {
+	case p.SuspendOverdueDays > 0 && b.DaysOverdue >= p.SuspendOverdueDays:
+		return fmt.Sprintf("a book is %d days overdue", b.DaysOverdue)
+	case b.UnpaidFines > p.SuspendFines:
+		return fmt.Sprintf("unpaid fines of %d are above %d", b.UnpaidFines, p.SuspendFines)
+	}
+	return ""
+}
+
+func loanPolicy(q querier, userType string) (LoanPolicy, error) {
+	p := LoanPolicy{UserType: userType}
+	err := q.QueryRow("SELECT loan_days, fine_per_day, max_fine, max_loans, fine_limit, pickup_days, max_renewals, grace_days, replacement_fee, short_loan_hours, short_fine_per_hour, suspend_overdue_days, suspend_fines FROM loan_policies WHERE user_type=?", userType).Scan(&p.LoanDays, &p.FinePerDay, &p.MaxFine, &p.MaxLoans, &p.FineLimit, &p.PickupDays, &p.MaxRenewals, &p.GraceDays, &p.ReplacementFee, &p.ShortLoanHours, &p.ShortFinePerHour, &p.SuspendOverdueDays, &p.SuspendFines)
+	if errors.Is(err, sql.ErrNoRows) {
+		return p, newHTTPError(http.StatusBadRequest, "no loan policy for %s", userType)
+	}
+	return p, err
+}
+
+// userTables maps a user type to the table of its users.
+var userTables = map[string]string{
+	"student":  "students",
+	"lecturer": "lecturers",
+}
+
+// loadBorrower reads what the loan policy needs about a user, locking the
+// user's row so concurrent borrows by the same user are counted in turn,
+// and the user's membership.
+func loadBorrower(tx querier, userType string, userID int) (Borrower, error) {
+	var b Borrower
+	table, ok := userTables[userType]
+	if !ok {
+		return b, nil
+	}
+	var id int
+	err := tx.QueryRow("SELECT id FROM "+table+" WHERE id=? FOR UPDATE", userID).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return b, nil
+	}
+	if err != nil {
+		return b, err
+	}
+	b.Exists = true
+	var earliestDue *string
+	err = tx.QueryRow(`SELECT
+		(SELECT COUNT(*) FROM borrow_records WHERE user_type=? AND user_id=? AND return_date IS NULL),
+		(SELECT COUNT(*) FROM borrow_records WHERE user_type=? AND user_id=? AND return_date IS NULL AND IFNULL(due_at < NOW(), due_date < CURDATE())),
+		(SELECT DATE_FORMAT(MIN(due_date), '%Y-%m-%d') FROM borrow_records WHERE user_type=? AND user_id=? AND return_date IS NULL AND due_date < CURDATE()),
+		(SELECT IFNULL(SUM(amount), 0) FROM fines WHERE user_type=? AND user_id=? AND status=?)`,
+		userType, userID, userType, userID, userType, userID, userType, userID, fineUnpaid).Scan(&b.OpenLoans, &b.OverdueLoans, &earliestDue, &b.UnpaidFines)
+	if err != nil {
+		return b, err
+	}
+	// the longest overdue loan is overdue by the days the library was open
+	calendar, err := loadCalendar(tx)
+	if err != nil {
+		return b, err
+	}
+	if b.DaysOverdue, err = calendar.overdueDays(earliestDue); err != nil {
+		return b, err
+	}
+	m, err := loadMembership(tx, userType, userID)
+	if err != nil {
+		return b, err
+	}
+	b.Membership, b.Reason = m.Status, m.Reason
+	return b, nil
+}
+
+// checkBorrower refuses a borrow the loan policy does not allow. It only
+// reads: the borrow handlers save automatic suspensions beforehand with
+// updateSuspension, as the borrow's own transaction is rolled back when it
+// is refused.
+func checkBorrower(tx querier, policy LoanPolicy, userID int) error {
+	b, err := loadBorrower(tx, policy.UserType, userID)
+	if err != nil {
+		return err
+	}
+	if reason := policy.Suspends(b); reason != "" && b.Membership == membershipActive {
+		b.Membership, b.Reason = membershipSuspended, reason
+	}
+	refusal := policy.Check(b)
+	if refusal == nil {
+		return nil
+	}
+	status := http.StatusForbidden
+	if refusal.Reason == refusedUnknownBorrower {
+		status = http.StatusNotFound
+	}
+	return &httpError{Status: status, Message: refusal.Error, Body: refusal}
+}
@@ -0,0 +1,170 @@
+package managementsystem_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	managementsystem "managementsystem/managementsystem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"testing"
+)
+
+func TestLoanPolicy_Check(t *testing.T) {
+	policy := managementsystem.LoanPolicy{UserType: "student", MaxLoans: 5, FineLimit: 100}
+	tests := []struct {
+		name     string // description of this test case
+		borrower managementsystem.Borrower
+		reason   string // empty when the borrow is allowed
+	}{
+		{
+			name:     "allowed",
+			borrower: managementsystem.Borrower{Exists: true, OpenLoans: 4, UnpaidFines: 100},
+		},
+		{
+			name:     "unknown borrower",
+			borrower: managementsystem.Borrower{},
+			reason:   "unknown_borrower",
+		},
+		{
+			name:     "at the loan limit",
+			borrower: managementsystem.Borrower{Exists: true, OpenLoans: 5},
+			reason:   "loan_limit",
+		},
+		{
+			name:     "overdue books",
+			borrower: managementsystem.Borrower{Exists: true, OpenLoans: 1, OverdueLoans: 1},
+			reason:   "overdue_items",
+		},
+		{
+			name:     "unpaid fines above the limit",
+			borrower: managementsystem.Borrower{Exists: true, UnpaidFines: 101},
+			reason:   "unpaid_fines",
+		},
+		{
+			name:     "suspended",
+			borrower: managementsystem.Borrower{Exists: true, Membership: "suspended", Reason: "lost books"},
+			reason:   "suspended",
+		},
+		{
+			name:     "expired membership",
+			borrower: managementsystem.Borrower{Exists: true, Membership: "expired"},
+			reason:   "membership_expired",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			refusal := policy.Check(tt.borrower)
+			if tt.reason == "" {
+				if refusal != nil {
+					t.Fatalf("Expected the borrow to be allowed, got %+v", refusal)
+				}
+				return
+			}
+			if refusal == nil || refusal.Reason != tt.reason {
+				t.Fatalf("Expected refusal %s, got %+v", tt.reason, refusal)
+			}
+		})
+	}
+}
+
+func TestLoanPolicy_Suspends(t *testing.T) {
+	policy := managementsystem.LoanPolicy{UserType: "student", SuspendOverdueDays: 30, SuspendFines: 5000}
+	tests := []struct {
+		name      string // description of this test case
+		borrower  managementsystem.Borrower
+		suspended bool
+	}{
+		{name: "settled", borrower: managementsystem.Borrower{Exists: true, OpenLoans: 2}},
+		{name: "a little overdue", borrower: managementsystem.Borrower{Exists: true, OverdueLoans: 1, DaysOverdue: 29, UnpaidFines: 5000}},
+		{name: "long overdue", borrower: managementsystem.Borrower{Exists: true, OverdueLoans: 1, DaysOverdue: 30}, suspended: true},
+		{name: "fines above the threshold", borrower: managementsystem.Borrower{Exists: true, UnpaidFines: 5001}, suspended: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if reason := policy.Suspends(tt.borrower); (reason != "") != tt.suspended {
+				t.Fatalf("Expected suspended %v, got %q", tt.suspended, reason)
+			}
+		})
+	}
+}
+
+func TestHybridHandler5_BorrowEligibility(t *testing.T) {
+
+	os.Setenv("REDIS_ADDR", "localhost:6379")
+	os.Setenv("MySQL_DSN", "root:root@tcp(127.0.0.1:3306)/management_sys")
+
+	redisInstance, err := managementsystem.ConnectRedis()
+	if err != nil {
+		panic(err)
+	}
+	mysqlinstance, err := managementsystem.ConnectMySQL()
+	if err != nil {
+		panic(err)
+	}
+	handler := &managementsystem.HybridHandler5{MySQL: mysqlinstance, Redis: redisInstance, Ctx: context.Background()}
+	router := handler.Router()
+
+	mysqlinstance.DB.Exec("DELETE FROM fines")
+	mysqlinstance.DB.Exec("DELETE FROM borrow_records")
+	mysqlinstance.DB.Exec("DELETE FROM book_copies")
+	mysqlinstance.DB.Exec("DELETE FROM books")
+	redisInstance.Client.FlushAll(context.Background())
+
+	for _, id := range []int{201, 202, 203, 204} {
+		mysqlinstance.DB.Exec("INSERT IGNORE INTO students (id, name, email, age, year) VALUES (?, ?, ?, ?, ?)", id, "borrower", "borrower"+strconv.Itoa(id)+"@gmail.com", 20, 1)
+	}
+	mysqlinstance.DB.Exec("DELETE FROM students WHERE id=205")
+
+	res, err := mysqlinstance.DB.Exec("INSERT INTO books(title, author) VALUES (?, ?)", "GoLang", "Alice")
+	if err != nil {
+		t.Fatalf("insert book fail: %v", err)
+	}
+	book_id, _ := res.LastInsertId()
+	for i := 0; i < 10; i++ {
+		if _, err := mysqlinstance.DB.Exec("INSERT INTO book_copies(book_id, branch_id, barcode) VALUES (?, (SELECT id FROM branches WHERE code='MAIN'), ?)", book_id, "GO-"+strconv.Itoa(i)); err != nil {
+			t.Fatalf("insert copy fail: %v", err)
+		}
+	}
+
+	// 202 has five books out, 203 an overdue one and 204 an unpaid fine
+	for i := 0; i < 5; i++ {
+		mysqlinstance.DB.Exec("INSERT INTO borrow_records(user_id, user_type, book_id, borrow_date, due_date) VALUES (?, ?, ?, CURDATE(), CURDATE() + INTERVAL 14 DAY)", 202, "student", book_id)
+	}
+	mysqlinstance.DB.Exec("INSERT INTO borrow_records(user_id, user_type, book_id, borrow_date, due_date) VALUES (?, ?, ?, CURDATE() - INTERVAL 20 DAY, CURDATE() - INTERVAL 6 DAY)", 203, "student", book_id)
+	mysqlinstance.DB.Exec("INSERT INTO fines(user_id, user_type, amount) VALUES (?, ?, ?)", 204, "student", 300)
+
+	tests := []struct {
+		name   string // description of this test case
+		userID int
+		status int
+		reason string
+	}{
+		{name: "eligible", userID: 201, status: http.StatusCreated},
+		{name: "loan limit", userID: 202, status: http.StatusForbidden, reason: "loan_limit"},
+		{name: "overdue book", userID: 203, status: http.StatusForbidden, reason: "overdue_items"},
+		{name: "unpaid fine", userID: 204, status: http.StatusForbidden, reason: "unpaid_fines"},
+		{name: "no such student", userID: 205, status: http.StatusNotFound, reason: "unknown_borrower"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, _ := json.Marshal(managementsystem.Borrow_records{User_id: tt.userID, User_type: "student", Book_id: int(book_id)})
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/borrow", bytes.NewBuffer(body)))
+			if w.Code != tt.status {
+				t.Fatalf("Expected status %d, got %d: %s", tt.status, w.Code, w.Body.String())
+			}
+			if tt.reason != "" {
+				var refusal managementsystem.BorrowRefusal
+				if err := json.NewDecoder(w.Body).Decode(&refusal); err != nil {
+					t.Fatalf("failed to decode response: %v", err)
+				}
+				if refusal.Reason != tt.reason {
+					t.Fatalf("Expected reason %s, got %s", tt.reason, refusal.Reason)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,184 @@
+package managementsystem
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// Reasons a renewal is refused.
+const (
+	refusedReturned     = "returned"
+	refusedRenewalLimit = "renewal_limit"
+	refusedOnHold       = "on_hold"
+	refusedShortLoan    = "short_loan"
+)
+
+type Renewal struct {
+	ID         int    `json:"id"`
+	BorrowID   int    `json:"borrow_id"`
+	OldDueDate string `json:"old_due_date"`
+	NewDueDate string `json:"new_due_date"`
+	RenewedAt  string `json:"renewed_at"`
+}
+
+// RenewedLoan is a loan after a renewal, with all its renewals.
+type RenewedLoan struct {
+	BorrowID int       `json:"borrow_id"`
+	DueDate  string    `json:"due_date"`
+	Renewals int       `json:"renewals"`
+	History  []Renewal `json:"history"`
+	Fine     *Fine     `json:"fine,omitempty"` // for the days overdue when renewed in the grace period
+}
+
+// CheckRenewal returns why a loan renewed renewals times, daysOverdue days
+// overdue and with holds waiting by other users may not be renewed again,
+// or nil if it may.
+func (p LoanPolicy) CheckRenewal(renewals, daysOverdue, holds int) *BorrowRefusal {
+	switch {
+	case renewals >= p.MaxRenewals:
+		return &BorrowRefusal{Error: fmt.Sprintf("a loan may be renewed at most %d times", p.MaxRenewals), Reason: refusedRenewalLimit, Limit: p.MaxRenewals, Actual: renewals}
+	case daysOverdue > p.GraceDays:
+		return &BorrowRefusal{Error: fmt.Sprintf("the loan is %d days overdue and must be returned", daysOverdue), Reason: refusedOverdue, Limit: p.GraceDays, Actual: daysOverdue}
+	case holds > 0:
+		return &BorrowRefusal{Error: "other readers are waiting for this book", Reason: refusedOnHold, Actual: holds}
+	}
+	return nil
+}
+
+// Renew a loan
+func (h *HybridHandler5) RenewLoanHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "invalid borrow id", http.StatusBadRequest)
+		return
+	}
+	tx, err := h.MySQL.DB.Begin()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	var record Borrow_records
+	var returned, short bool
+	var due *string
+	err = tx.QueryRow("SELECT user_id, user_type, book_id, renewals, return_date IS NOT NULL, due_at IS NOT NULL, DATE_FORMAT(due_date, '%Y-%m-%d') FROM borrow_records WHERE borrow_id=? FOR UPDATE", id).Scan(&record.User_id, &record.User_type, &record.Book_id, &record.Renewals, &returned, &short, &due)
+	if err != nil {
+		writeLoadError(w, "loan", err)
+		return
+	}
+	if returned {
+		writeJSON(w, http.StatusConflict, &BorrowRefusal{Error: "the book was already returned", Reason: refusedReturned})
+		return
+	}
+	if short {
+		writeJSON(w, http.StatusConflict, &BorrowRefusal{Error: "short loans of course reserves cannot be renewed", Reason: refusedShortLoan})
+		return
+	}
+	policy, err := loanPolicy(tx, record.User_type)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := refreshHolds(tx, record.Book_id); err != nil {
+		writeError(w, err)
+		return
+	}
+	var holds int
+	err = tx.QueryRow("SELECT COUNT(*) FROM holds WHERE book_id=? AND status IN (?, ?) AND NOT (user_id=? AND user_type=?)", record.Book_id, holdWaiting, holdReady, record.User_id, record.User_type).Scan(&holds)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	// the grace period counts the days the library was open, as fines do
+	calendar, err := loadCalendar(tx)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	daysOverdue, err := calendar.overdueDays(due)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if refusal := policy.CheckRenewal(record.Renewals, daysOverdue, holds); refusal != nil {
+		writeJSON(w, http.StatusConflict, refusal)
+		return
+	}
+
+	// a loan renewed during the grace period is fined for the days it was
+	// overdue, as it would be when returned, since the new due date hides them
+	record.Borrow_id = id
+	fine, err := chargeOverdue(tx, &record, daysOverdue, 0)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	// a renewal runs a full loan period from the due date, or from today
+	// when renewed during the grace period, to a day the library is open
+	from := calendar.Today
+	if due != nil {
+		if day, err := time.Parse(time.DateOnly, *due); err == nil && day.After(from) {
+			from = day
+		}
+	}
+	newDue := calendar.DueDate(from, policy.LoanDays).Format(time.DateOnly)
+	_, err = tx.Exec("INSERT INTO loan_renewals (borrow_id, old_due_date, new_due_date) SELECT borrow_id, due_date, ? FROM borrow_records WHERE borrow_id=?", newDue, id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	_, err = tx.Exec("UPDATE borrow_records SET due_date=?, renewals=renewals+1 WHERE borrow_id=?", newDue, id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	loan, err := renewedLoan(tx, id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	loan.Fine = fine
+	if err := tx.Commit(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, loan)
+}
+
+func renewedLoan(q querier, id int) (*RenewedLoan, error) {
+	loan := RenewedLoan{BorrowID: id, History: []Renewal{}}
+	err := q.QueryRow("SELECT DATE_FORMAT(due_date, '%Y-%m-%d'), renewals FROM borrow_records WHERE borrow_id=?", id).Scan(&loan.DueDate, &loan.Renewals)
+	if err != nil {
+		return nil, err
+	}
+	loan.History, err = renewals(q, id)
+	if err != nil {
+		return nil, err
+	}
+	return &loan, nil
+}
+
+// renewals lists the renewals of a loan, oldest first.
+func renewals(q querier, borrowID int) ([]Renewal, error) {
+	rows, err := q.Query("SELECT id, borrow_id, IFNULL(DATE_FORMAT(old_due_date, '%Y-%m-%d'), ''), DATE_FORMAT(new_due_date, '%Y-%m-%d'), DATE_FORMAT(renewed_at, '%Y-%m-%d %H:%i:%s') FROM loan_renewals WHERE borrow_id=? ORDER BY id", borrowID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	history := []Renewal{}
+	for rows.Next() {
+		var renewal Renewal
+		if err := rows.Scan(&renewal.ID, &renewal.BorrowID, &renewal.OldDueDate, &renewal.NewDueDate, &renewal.RenewedAt); err != nil {
+			return nil, err
+		}
+		history = append(history, renewal)
+	}
+	return history, rows.Err()
+}
@@ -0,0 +1,153 @@
+package managementsystem_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	managementsystem "managementsystem/managementsystem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"testing"
+)
+
+func TestLoanPolicy_CheckRenewal(t *testing.T) {
+	policy := managementsystem.LoanPolicy{UserType: "student", MaxRenewals: 2, GraceDays: 3}
+	tests := []struct {
+		name     string // description of this test case
+		renewals int
+		overdue  int
+		holds    int
+		reason   string // empty when the renewal is allowed
+	}{
+		{name: "first renewal", renewals: 0, overdue: -5},
+		{name: "within the grace period", renewals: 1, overdue: 3},
+		{name: "renewal limit", renewals: 2, overdue: -5, reason: "renewal_limit"},
+		{name: "overdue beyond grace", renewals: 0, overdue: 4, reason: "overdue_items"},
+		{name: "held by someone else", renewals: 0, overdue: -5, holds: 1, reason: "on_hold"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			refusal := policy.CheckRenewal(tt.renewals, tt.overdue, tt.holds)
+			if tt.reason == "" {
+				if refusal != nil {
+					t.Fatalf("Expected the renewal to be allowed, got %+v", refusal)
+				}
+				return
+			}
+			if refusal == nil || refusal.Reason != tt.reason {
+				t.Fatalf("Expected refusal %s, got %+v", tt.reason, refusal)
+			}
+		})
+	}
+}
+
+func TestHybridHandler5_RenewLoanHandler(t *testing.T) {
+
+	os.Setenv("REDIS_ADDR", "localhost:6379")
+	os.Setenv("MySQL_DSN", "root:root@tcp(127.0.0.1:3306)/management_sys")
+
+	redisInstance, err := managementsystem.ConnectRedis()
+	if err != nil {
+		panic(err)
+	}
+	mysqlinstance, err := managementsystem.ConnectMySQL()
+	if err != nil {
+		panic(err)
+	}
+	handler := &managementsystem.HybridHandler5{MySQL: mysqlinstance, Redis: redisInstance, Ctx: context.Background()}
+	router := handler.Router()
+
+	mysqlinstance.DB.Exec("DELETE FROM holds")
+	mysqlinstance.DB.Exec("DELETE FROM fines")
+	mysqlinstance.DB.Exec("DELETE FROM borrow_records")
+	mysqlinstance.DB.Exec("DELETE FROM book_copies")
+	mysqlinstance.DB.Exec("DELETE FROM books")
+	redisInstance.Client.FlushAll(context.Background())
+
+	for _, id := range []int{401, 402} {
+		mysqlinstance.DB.Exec("INSERT IGNORE INTO students (id, name, email, age, year) VALUES (?, ?, ?, ?, ?)", id, "reader", "reader"+strconv.Itoa(id)+"@gmail.com", 20, 1)
+	}
+
+	// three books out with 401: one due in three days, one long overdue and
+	// one two days overdue, inside a three day grace period
+	mysqlinstance.DB.Exec("UPDATE loan_policies SET grace_days=3 WHERE user_type='student'")
+	defer mysqlinstance.DB.Exec("UPDATE loan_policies SET grace_days=0 WHERE user_type='student'")
+	loans := map[string]int{}
+	for _, title := range []string{"GoLang", "Rust", "Zig"} {
+		res, err := mysqlinstance.DB.Exec("INSERT INTO books(title, author) VALUES (?, ?)", title, "Alice")
+		if err != nil {
+			t.Fatalf("insert book fail: %v", err)
+		}
+		book_id, _ := res.LastInsertId()
+		res, err = mysqlinstance.DB.Exec("INSERT INTO book_copies(book_id, branch_id, barcode, status) VALUES (?, (SELECT id FROM branches WHERE code='MAIN'), ?, ?)", book_id, title+"-1", "borrowed")
+		if err != nil {
+			t.Fatalf("insert copy fail: %v", err)
+		}
+		copy_id, _ := res.LastInsertId()
+		due := 3
+		switch title {
+		case "Rust":
+			due = -10
+		case "Zig":
+			due = -2
+		}
+		res, err = mysqlinstance.DB.Exec("INSERT INTO borrow_records(user_id, user_type, book_id, copy_id, borrow_date, due_date) VALUES (?, ?, ?, ?, CURDATE(), CURDATE() + INTERVAL ? DAY)", 401, "student", book_id, copy_id, due)
+		if err != nil {
+			t.Fatalf("insert borrow fail: %v", err)
+		}
+		borrow_id, _ := res.LastInsertId()
+		loans[title] = int(borrow_id)
+		if title == "Rust" {
+			// and 402 waits for it
+			body, _ := json.Marshal(managementsystem.Hold{UserID: 402, UserType: "student"})
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/books/"+strconv.FormatInt(book_id, 10)+"/holds", bytes.NewBuffer(body)))
+			if w.Code != http.StatusCreated {
+				t.Fatalf("Expected created status , got %d: %s", w.Code, w.Body.String())
+			}
+		}
+	}
+
+	tests := []struct {
+		name     string // description of this test case
+		borrowID int
+		status   int
+		renewals int
+		fine     int // charged for the days overdue
+	}{
+		{name: "renew", borrowID: loans["GoLang"], status: http.StatusOK, renewals: 1},
+		{name: "renew again", borrowID: loans["GoLang"], status: http.StatusOK, renewals: 2},
+		{name: "renewal limit", borrowID: loans["GoLang"], status: http.StatusConflict},
+		{name: "overdue and on hold", borrowID: loans["Rust"], status: http.StatusConflict},
+		{name: "renew in the grace period", borrowID: loans["Zig"], status: http.StatusOK, renewals: 1, fine: 100},
+		{name: "unknown loan", borrowID: 987654, status: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/borrows/"+strconv.Itoa(tt.borrowID)+"/renew", nil))
+			if w.Code != tt.status {
+				t.Fatalf("Expected status %d, got %d: %s", tt.status, w.Code, w.Body.String())
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+			var loan managementsystem.RenewedLoan
+			if err := json.NewDecoder(w.Body).Decode(&loan); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if loan.Renewals != tt.renewals || len(loan.History) != tt.renewals {
+				t.Fatalf("Expected %d renewals, got %d with %d in history", tt.renewals, loan.Renewals, len(loan.History))
+			}
+			if (loan.Fine == nil && tt.fine != 0) || (loan.Fine != nil && loan.Fine.Amount != tt.fine) {
+				t.Fatalf("Expected a fine of %d, got %+v", tt.fine, loan.Fine)
+			}
+			last := loan.History[len(loan.History)-1]
+			if last.NewDueDate != loan.DueDate || last.OldDueDate >= last.NewDueDate {
+				t.Fatalf("Expected the due date to move later, got %+v", last)
+			}
+		})
+	}
+}
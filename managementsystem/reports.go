@@ -0,0 +1,272 @@
+package managementsystem
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Library usage reports cover the loans borrowed between ?from= and ?to=
+// (YYYY-MM-DD, both included), by default the current month up to today.
+// Each is JSON, or CSV when its path ends in .csv.
+
+type BorrowedBookRow struct {
+	BookID    int    `json:"book_id"`
+	Title     string `json:"title"`
+	Author    string `json:"author"`
+	Borrows   int    `json:"borrows"`
+	Borrowers int    `json:"borrowers"`
+}
+
+type DepartmentBorrowsRow struct {
+	Department string `json:"department"` // code, empty for users without one
+	UserType   string `json:"user_type"`
+	Borrows    int    `json:"borrows"`
+	Borrowers  int    `json:"borrowers"`
+}
+
+type LoanDurationRow struct {
+	UserType    string  `json:"user_type"`
+	Returned    int     `json:"returned"`
+	AverageDays float64 `json:"average_days"`
+	LongestDays int     `json:"longest_days"`
+}
+
+type OverdueRateRow struct {
+	UserType string  `json:"user_type"`
+	Loans    int     `json:"loans"`
+	Overdue  int     `json:"overdue"` // returned late, or out past the due date
+	Rate     float64 `json:"rate"`
+}
+
+// UtilizationRow is how much of the period the copies of a book spent on
+// loan: LoanDays over Copies times the days in the period.
+type UtilizationRow struct {
+	BookID      int     `json:"book_id"`
+	Title       string  `json:"title"`
+	Copies      int     `json:"copies"`
+	LoanDays    int     `json:"loan_days"`
+	Utilization float64 `json:"utilization"`
+}
+
+type reportPeriod struct {
+	From, To string
+	Days     int
+}
+
+func parsePeriod(r *http.Request) (reportPeriod, error) {
+	now := time.Now()
+	from := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.Local)
+	to := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	var err error
+	if v := r.URL.Query().Get("from"); v != "" {
+		if from, err = time.ParseInLocation(time.DateOnly, v, time.Local); err != nil {
+			return reportPeriod{}, fmt.Errorf("from must be YYYY-MM-DD")
+		}
+	}
+	if v := r.URL.Query().Get("to"); v != "" {
+		if to, err = time.ParseInLocation(time.DateOnly, v, time.Local); err != nil {
+			return reportPeriod{}, fmt.Errorf("to must be YYYY-MM-DD")
+		}
+	}
+	if to.Before(from) {
+		return reportPeriod{}, fmt.Errorf("to must not be before from")
+	}
+	return reportPeriod{
+		From: from.Format(time.DateOnly),
+		To:   to.Format(time.DateOnly),
+		Days: int(to.Sub(from).Hours()/24+0.5) + 1,
+	}, nil
+}
+
+// writeReport answers with rows as JSON, or as CSV under header when the
+// path ends in .csv.
+func writeReport[T any](w http.ResponseWriter, r *http.Request, name string, period reportPeriod, header []string, rows []T, record func(T) []string) {
+	if !strings.HasSuffix(r.URL.Path, ".csv") {
+		writeJSON(w, http.StatusOK, rows)
+		return
+	}
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s-%s-%s.csv", name, period.From, period.To))
+	out := csv.NewWriter(w)
+	out.Write(header)
+	for _, row := range rows {
+		out.Write(record(row))
+	}
+	out.Flush()
+}
+
+// queryReport runs query and scans each row with scan.
+func queryReport[T any](q querier, query string, args []any, scan func(scan func(...any) error, row *T) error) ([]T, error) {
+	rows, err := q.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	report := []T{}
+	for rows.Next() {
+		var row T
+		if err := scan(rows.Scan, &row); err != nil {
+			return nil, err
+		}
+		report = append(report, row)
+	}
+	return report, rows.Err()
+}
+
+func ratio(n, d float64) float64 {
+	if d == 0 {
+		return 0
+	}
+	return n / d
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', 4, 64)
+}
+
+// Most borrowed books
+func (h *HybridHandler5) MostBorrowedReportHandler(w http.ResponseWriter, r *http.Request) {
+	period, err := parsePeriod(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	limit, offset, err := pagination(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	report, err := queryReport(h.MySQL.DB, `SELECT b.book_id, b.title, b.author, COUNT(*), COUNT(DISTINCT r.user_type, r.user_id)
+		FROM borrow_records r JOIN books b ON b.book_id = r.book_id
+		WHERE r.borrow_date BETWEEN ? AND ?
+		GROUP BY b.book_id, b.title, b.author
+		ORDER BY COUNT(*) DESC, b.book_id LIMIT ? OFFSET ?`, []any{period.From, period.To, limit, offset},
+		func(scan func(...any) error, row *BorrowedBookRow) error {
+			return scan(&row.BookID, &row.Title, &row.Author, &row.Borrows, &row.Borrowers)
+		})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeReport(w, r, "most-borrowed", period, []string{"book_id", "title", "author", "borrows", "borrowers"}, report, func(row BorrowedBookRow) []string {
+		return []string{strconv.Itoa(row.BookID), row.Title, row.Author, strconv.Itoa(row.Borrows), strconv.Itoa(row.Borrowers)}
+	})
+}
+
+// Borrows per department
+func (h *HybridHandler5) DepartmentBorrowsReportHandler(w http.ResponseWriter, r *http.Request) {
+	period, err := parsePeriod(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	report, err := queryReport(h.MySQL.DB, `SELECT IFNULL(d.code, ''), r.user_type, COUNT(*), COUNT(DISTINCT r.user_id)
+		FROM borrow_records r
+		LEFT JOIN students s ON r.user_type = 'student' AND s.id = r.user_id
+		LEFT JOIN lecturers l ON r.user_type = 'lecturer' AND l.id = r.user_id
+		LEFT JOIN departments d ON d.id = COALESCE(s.dept_id, l.dept_id)
+		WHERE r.borrow_date BETWEEN ? AND ?
+		GROUP BY d.code, r.user_type
+		ORDER BY COUNT(*) DESC, 1, 2`, []any{period.From, period.To},
+		func(scan func(...any) error, row *DepartmentBorrowsRow) error {
+			return scan(&row.Department, &row.UserType, &row.Borrows, &row.Borrowers)
+		})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeReport(w, r, "department-borrows", period, []string{"department", "user_type", "borrows", "borrowers"}, report, func(row DepartmentBorrowsRow) []string {
+		return []string{row.Department, row.UserType, strconv.Itoa(row.Borrows), strconv.Itoa(row.Borrowers)}
+	})
+}
+
+// Average loan duration of returned loans
+func (h *HybridHandler5) LoanDurationReportHandler(w http.ResponseWriter, r *http.Request) {
+	period, err := parsePeriod(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	report, err := queryReport(h.MySQL.DB, `SELECT user_type, COUNT(*), AVG(DATEDIFF(return_date, borrow_date)), MAX(DATEDIFF(return_date, borrow_date))
+		FROM borrow_records
+		WHERE return_date IS NOT NULL AND borrow_date BETWEEN ? AND ?
+		GROUP BY user_type ORDER BY user_type`, []any{period.From, period.To},
+		func(scan func(...any) error, row *LoanDurationRow) error {
+			return scan(&row.UserType, &row.Returned, &row.AverageDays, &row.LongestDays)
+		})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeReport(w, r, "loan-duration", period, []string{"user_type", "returned", "average_days", "longest_days"}, report, func(row LoanDurationRow) []string {
+		return []string{row.UserType, strconv.Itoa(row.Returned), formatFloat(row.AverageDays), strconv.Itoa(row.LongestDays)}
+	})
+}
+
+// Overdue rates
+func (h *HybridHandler5) OverdueRateReportHandler(w http.ResponseWriter, r *http.Request) {
+	period, err := parsePeriod(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	report, err := queryReport(h.MySQL.DB, `SELECT user_type, COUNT(*), IFNULL(SUM(due_date < IFNULL(return_date, CURDATE())), 0)
+		FROM borrow_records
+		WHERE due_date IS NOT NULL AND borrow_date BETWEEN ? AND ?
+		GROUP BY user_type ORDER BY user_type`, []any{period.From, period.To},
+		func(scan func(...any) error, row *OverdueRateRow) error {
+			if err := scan(&row.UserType, &row.Loans, &row.Overdue); err != nil {
+				return err
+			}
+			row.Rate = ratio(float64(row.Overdue), float64(row.Loans))
+			return nil
+		})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeReport(w, r, "overdue-rates", period, []string{"user_type", "loans", "overdue", "rate"}, report, func(row OverdueRateRow) []string {
+		return []string{row.UserType, strconv.Itoa(row.Loans), strconv.Itoa(row.Overdue), formatFloat(row.Rate)}
+	})
+}
+
+// Copies utilization per book
+func (h *HybridHandler5) UtilizationReportHandler(w http.ResponseWriter, r *http.Request) {
+	period, err := parsePeriod(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	limit, offset, err := pagination(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// a loan counts the days of it inside the period, up to today while out
+	report, err := queryReport(h.MySQL.DB, `SELECT b.book_id, b.title,
+		(SELECT COUNT(*) FROM book_copies c WHERE c.book_id = b.book_id AND c.status NOT IN ('retired', 'lost')) AS copies,
+		IFNULL((SELECT SUM(GREATEST(DATEDIFF(LEAST(IFNULL(r.return_date, CURDATE()), ? + INTERVAL 1 DAY), GREATEST(r.borrow_date, ?)), 0))
+			FROM borrow_records r
+			WHERE r.book_id = b.book_id AND r.borrow_date <= ? AND IFNULL(r.return_date, CURDATE()) >= ?), 0) AS loan_days
+		FROM books b
+		ORDER BY loan_days / GREATEST(copies, 1) DESC, b.book_id LIMIT ? OFFSET ?`, []any{period.To, period.From, period.To, period.From, limit, offset},
+		func(scan func(...any) error, row *UtilizationRow) error {
+			if err := scan(&row.BookID, &row.Title, &row.Copies, &row.LoanDays); err != nil {
+				return err
+			}
+			row.Utilization = ratio(float64(row.LoanDays), float64(row.Copies*period.Days))
+			return nil
+		})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeReport(w, r, "utilization", period, []string{"book_id", "title", "copies", "loan_days", "utilization"}, report, func(row UtilizationRow) []string {
+		return []string{strconv.Itoa(row.BookID), row.Title, strconv.Itoa(row.Copies), strconv.Itoa(row.LoanDays), formatFloat(row.Utilization)}
+	})
+}
@@ -0,0 +1,119 @@
+package managementsystem_test
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	managementsystem "managementsystem/managementsystem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestHybridHandler5_LibraryReports(t *testing.T) {
+
+	os.Setenv("REDIS_ADDR", "localhost:6379")
+	os.Setenv("MySQL_DSN", "root:root@tcp(127.0.0.1:3306)/management_sys")
+
+	redisInstance, err := managementsystem.ConnectRedis()
+	if err != nil {
+		panic(err)
+	}
+	mysqlinstance, err := managementsystem.ConnectMySQL()
+	if err != nil {
+		panic(err)
+	}
+	handler := &managementsystem.HybridHandler5{MySQL: mysqlinstance, Redis: redisInstance, Ctx: context.Background()}
+	router := handler.Router()
+
+	mysqlinstance.DB.Exec("DELETE FROM holds")
+	mysqlinstance.DB.Exec("DELETE FROM fines")
+	mysqlinstance.DB.Exec("DELETE FROM borrow_records")
+	mysqlinstance.DB.Exec("DELETE FROM book_copies")
+	mysqlinstance.DB.Exec("DELETE FROM books")
+	redisInstance.Client.FlushAll(context.Background())
+
+	// in March 2024 one copy of GoLang was out 10 days and returned late,
+	// and another out 5 days on time; Rust was borrowed once in February
+	books := map[string]int64{}
+	for _, title := range []string{"GoLang", "Rust"} {
+		res, err := mysqlinstance.DB.Exec("INSERT INTO books(title, author) VALUES (?, ?)", title, "Alice")
+		if err != nil {
+			t.Fatalf("insert book fail: %v", err)
+		}
+		books[title], _ = res.LastInsertId()
+		mysqlinstance.DB.Exec("INSERT INTO book_copies(book_id, branch_id, barcode) VALUES (?, (SELECT id FROM branches WHERE code='MAIN'), ?)", books[title], title+"-1")
+	}
+	for _, loan := range []struct {
+		user                    int
+		book                    string
+		borrowed, due, returned string
+	}{
+		{101, "GoLang", "2024-03-01", "2024-03-08", "2024-03-11"},
+		{102, "GoLang", "2024-03-20", "2024-03-27", "2024-03-25"},
+		{101, "Rust", "2024-02-10", "2024-02-24", "2024-02-20"},
+	} {
+		_, err := mysqlinstance.DB.Exec("INSERT INTO borrow_records(user_id, user_type, book_id, borrow_date, due_date, return_date) VALUES (?, ?, ?, ?, ?, ?)", loan.user, "student", books[loan.book], loan.borrowed, loan.due, loan.returned)
+		if err != nil {
+			t.Fatalf("insert borrow fail: %v", err)
+		}
+	}
+	march := "?from=2024-03-01&to=2024-03-31"
+
+	t.Run("most borrowed", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/reports/library/most-borrowed"+march, nil))
+		var report []managementsystem.BorrowedBookRow
+		if err := json.NewDecoder(w.Body).Decode(&report); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		if len(report) != 1 || report[0].Title != "GoLang" || report[0].Borrows != 2 || report[0].Borrowers != 2 {
+			t.Fatalf("Expected GoLang borrowed twice, got %+v", report)
+		}
+	})
+	t.Run("loan duration", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/reports/library/loan-duration"+march, nil))
+		var report []managementsystem.LoanDurationRow
+		if err := json.NewDecoder(w.Body).Decode(&report); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		if len(report) != 1 || report[0].Returned != 2 || report[0].AverageDays != 7.5 || report[0].LongestDays != 10 {
+			t.Fatalf("Expected two loans of 7.5 days on average, got %+v", report)
+		}
+	})
+	t.Run("overdue rate", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/reports/library/overdue"+march, nil))
+		var report []managementsystem.OverdueRateRow
+		if err := json.NewDecoder(w.Body).Decode(&report); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		if len(report) != 1 || report[0].Overdue != 1 || report[0].Rate != 0.5 {
+			t.Fatalf("Expected half the loans overdue, got %+v", report)
+		}
+	})
+	t.Run("utilization as csv", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/reports/library/utilization.csv"+march, nil))
+		if w.Header().Get("Content-Type") != "text/csv; charset=utf-8" {
+			t.Fatalf("Expected CSV, got %s", w.Header().Get("Content-Type"))
+		}
+		records, err := csv.NewReader(w.Body).ReadAll()
+		if err != nil {
+			t.Fatalf("failed to read csv: %v", err)
+		}
+		// header, then GoLang out 15 of 31 days, then Rust not at all
+		if len(records) != 3 || records[1][1] != "GoLang" || records[1][3] != "15" || records[2][3] != "0" {
+			t.Fatalf("Expected GoLang used 15 days, got %v", records)
+		}
+	})
+	t.Run("invalid period", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/reports/library/departments?from=2024-03-31&to=2024-03-01", nil))
+		if w.Code != http.StatusBadRequest {
+			t.Fatalf("Expected status %d, got %d", http.StatusBadRequest, w.Code)
+		}
+	})
+}
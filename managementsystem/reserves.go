@@ -0,0 +1,322 @@
+package managementsystem
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"managementsystem/validation"
+)
+
+// Course reserve statuses. Copies of an active reserve circulate on short
+// loans; a released reserve gave its copies back to normal loans.
+const (
+	reserveActive   = "active"
+	reserveReleased = "released"
+)
+
+// CourseReserve puts copies of a book on reserve for a course until the end
+// of term, EndsOn, after which they are released. LoanHours overrides the
+// short loan period of the loan policy.
+type CourseReserve struct {
+	ID         int     `json:"id"`
+	CourseID   int     `json:"course_id"`
+	BookID     int     `json:"book_id" validate:"min=1"`
+	Title      string  `json:"title"`
+	LecturerID *int    `json:"lecturer_id"`
+	Copies     int     `json:"copies" validate:"min=0,max=20"` // asked for, then on reserve
+	LoanHours  *int    `json:"loan_hours"`
+	EndsOn     string  `json:"ends_on" validate:"trimmed,required"`
+	Status     string  `json:"status"`
+	CreatedAt  string  `json:"created_at"`
+	ReleasedAt *string `json:"released_at"`
+}
+
+const reserveSelect = `SELECT r.id, r.course_id, r.book_id, b.title, r.lecturer_id,
+	(SELECT COUNT(*) FROM book_copies c WHERE c.reserve_id = r.id), r.loan_hours, DATE_FORMAT(r.ends_on, '%Y-%m-%d'), r.status,
+	DATE_FORMAT(r.created_at, '%Y-%m-%d %H:%i:%s'), DATE_FORMAT(r.released_at, '%Y-%m-%d %H:%i:%s')
+	FROM course_reserves r JOIN books b ON b.book_id = r.book_id`
+
+func scanReserve(row interface{ Scan(...any) error }, cr *CourseReserve) error {
+	return row.Scan(&cr.ID, &cr.CourseID, &cr.BookID, &cr.Title, &cr.LecturerID, &cr.Copies, &cr.LoanHours, &cr.EndsOn, &cr.Status, &cr.CreatedAt, &cr.ReleasedAt)
+}
+
+// loadReserve reads a reserve, locking it when q is a transaction.
+func loadReserve(q querier, id int) (*CourseReserve, error) {
+	var cr CourseReserve
+	if err := scanReserve(q.QueryRow(reserveSelect+" WHERE r.id=? FOR UPDATE", id), &cr); err != nil {
+		return nil, err
+	}
+	return &cr, nil
+}
+
+// validation
+func ValidateReserve(cr *CourseReserve) error {
+	if cr.Copies == 0 {
+		cr.Copies = 1
+	}
+	if err := validation.Struct(cr); err != nil {
+		return err
+	}
+	if cr.LoanHours != nil && (*cr.LoanHours < 1 || *cr.LoanHours > 72) {
+		return fmt.Errorf("loan_hours must be between 1 and 72")
+	}
+	if _, err := time.Parse(time.DateOnly, cr.EndsOn); err != nil {
+		return fmt.Errorf("ends_on must be YYYY-MM-DD")
+	}
+	if cr.EndsOn < time.Now().Format(time.DateOnly) {
+		return fmt.Errorf("ends_on must not be in the past")
+	}
+	return nil
+}
+
+// Put a book on reserve for a course
+func (h *HybridHandler5) CreateReserveHandler(w http.ResponseWriter, r *http.Request) {
+	courseID, ok := courseResource.id(w, r)
+	if !ok {
+		return
+	}
+	var cr CourseReserve
+	if err := json.NewDecoder(r.Body).Decode(&cr); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := ValidateReserve(&cr); err != nil {
+		writeValidationError(w, r, err)
+		return
+	}
+	if _, err := courseResource.load(h, courseID); err != nil {
+		courseResource.writeLoadError(w, err)
+		return
+	}
+	tx, err := h.MySQL.DB.Begin()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	var bookID int
+	if err := tx.QueryRow("SELECT book_id FROM books WHERE book_id=? FOR UPDATE", cr.BookID).Scan(&bookID); err != nil {
+		bookResource.writeLoadError(w, err)
+		return
+	}
+	if cr.LecturerID != nil {
+		lecturer, err := loadBorrower(tx, "lecturer", *cr.LecturerID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !lecturer.Exists {
+			http.Error(w, "no such lecturer", http.StatusNotFound)
+			return
+		}
+	}
+	var reserved bool
+	if err := tx.QueryRow("SELECT EXISTS(SELECT 1 FROM course_reserves WHERE course_id=? AND book_id=? AND status=?)", courseID, bookID, reserveActive).Scan(&reserved); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if reserved {
+		http.Error(w, "book is already on reserve for this course", http.StatusConflict)
+		return
+	}
+	res, err := tx.Exec("INSERT INTO course_reserves (course_id, book_id, lecturer_id, loan_hours, ends_on, status) VALUES (?, ?, ?, ?, ?, ?)", courseID, bookID, cr.LecturerID, cr.LoanHours, cr.EndsOn, reserveActive)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	// copies on the shelf go first; copies on loan join the reserve when
+	// they come back
+	res, err = tx.Exec("UPDATE book_copies SET reserve_id=? WHERE book_id=? AND reserve_id IS NULL AND status IN (?, ?) ORDER BY status=? DESC, copy_id LIMIT ?", id, bookID, copyAvailable, copyBorrowed, copyAvailable, cr.Copies)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if n, _ := res.RowsAffected(); int(n) < cr.Copies {
+		http.Error(w, fmt.Sprintf("only %d copies of the book can go on reserve", n), http.StatusConflict)
+		return
+	}
+	created, err := loadReserve(tx, int(id))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusCreated, created)
+}
+
+// Reserve list of a course, with released reserves too when ?status=all
+func (h *HybridHandler5) CourseReservesHandler(w http.ResponseWriter, r *http.Request) {
+	courseID, ok := courseResource.id(w, r)
+	if !ok {
+		return
+	}
+	if _, err := courseResource.load(h, courseID); err != nil {
+		courseResource.writeLoadError(w, err)
+		return
+	}
+	if _, err := h.releaseEndedReserves(); err != nil {
+		writeError(w, err)
+		return
+	}
+	where := "r.course_id=? AND r.status='active'"
+	switch r.URL.Query().Get("status") {
+	case "", reserveActive:
+	case "all":
+		where = "r.course_id=?"
+	default:
+		http.Error(w, "status must be active or all", http.StatusBadRequest)
+		return
+	}
+	rows, err := h.MySQL.DB.Query(reserveSelect+" WHERE "+where+" ORDER BY r.status, b.title, r.id", courseID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	reserves := []CourseReserve{}
+	for rows.Next() {
+		var cr CourseReserve
+		if err := scanReserve(rows, &cr); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		reserves = append(reserves, cr)
+	}
+	if err := rows.Err(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, reserves)
+}
+
+// Release a reserve before the end of term
+func (h *HybridHandler5) ReleaseReserveHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "invalid reserve id", http.StatusBadRequest)
+		return
+	}
+	tx, err := h.MySQL.DB.Begin()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	cr, err := loadReserve(tx, id)
+	if err != nil {
+		writeLoadError(w, "reserve", err)
+		return
+	}
+	if cr.Status != reserveActive {
+		http.Error(w, "reserve is already released", http.StatusConflict)
+		return
+	}
+	if err := releaseReserve(tx, cr); err != nil {
+		writeError(w, err)
+		return
+	}
+	if cr, err = loadReserve(tx, id); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	h.cacheDel(bookResource.cacheKey(cr.BookID))
+	writeJSON(w, http.StatusOK, cr)
+}
+
+// releaseReserve takes the copies of a reserve off it, where the first
+// waiting holds can have them.
+func releaseReserve(tx querier, cr *CourseReserve) error {
+	if _, err := tx.Exec("UPDATE book_copies SET reserve_id=NULL WHERE reserve_id=?", cr.ID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("UPDATE course_reserves SET status=?, released_at=NOW() WHERE id=?", reserveReleased, cr.ID); err != nil {
+		return err
+	}
+	return refreshHolds(tx, cr.BookID)
+}
+
+// releaseEndedReserves releases the active reserves whose term has ended,
+// each in a transaction of its own, and returns how many it released.
+func (h *HybridHandler5) releaseEndedReserves() (int, error) {
+	rows, err := h.MySQL.DB.Query("SELECT id FROM course_reserves WHERE status=? AND ends_on < CURDATE()", reserveActive)
+	if err != nil {
+		return 0, err
+	}
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	released := 0
+	for _, id := range ids {
+		tx, err := h.MySQL.DB.Begin()
+		if err != nil {
+			return released, err
+		}
+		cr, err := loadReserve(tx, id)
+		if err == nil && cr.Status == reserveActive {
+			err = releaseReserve(tx, cr)
+		}
+		if err == nil {
+			err = tx.Commit()
+		}
+		if err != nil {
+			tx.Rollback()
+			return released, err
+		}
+		h.cacheDel(bookResource.cacheKey(cr.BookID))
+		released++
+	}
+	return released, nil
+}
+
+// ReserveReleaseJob releases the reserves of ended terms every interval
+// until the handler's context is done.
+func (h *HybridHandler5) ReserveReleaseJob(every time.Duration) {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-h.Ctx.Done():
+			return
+		case <-ticker.C:
+			released, err := h.releaseEndedReserves()
+			if err != nil {
+				log.Println("course reserves:", err)
+				continue
+			}
+			if released > 0 {
+				log.Printf("course reserves: released %d at the end of term", released)
+			}
+		}
+	}
+}
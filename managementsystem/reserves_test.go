@@ -0,0 +1,193 @@
+package managementsystem_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	managementsystem "managementsystem/managementsystem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestHybridHandler5_CourseReserves(t *testing.T) {
+
+	os.Setenv("REDIS_ADDR", "localhost:6379")
+	os.Setenv("MySQL_DSN", "root:root@tcp(127.0.0.1:3306)/management_sys")
+
+	redisInstance, err := managementsystem.ConnectRedis()
+	if err != nil {
+		panic(err)
+	}
+	mysqlinstance, err := managementsystem.ConnectMySQL()
+	if err != nil {
+		panic(err)
+	}
+	handler := &managementsystem.HybridHandler5{MySQL: mysqlinstance, Redis: redisInstance, Ctx: context.Background()}
+	router := handler.Router()
+
+	mysqlinstance.DB.Exec("DELETE FROM course_reserves")
+	mysqlinstance.DB.Exec("DELETE FROM holds")
+	mysqlinstance.DB.Exec("DELETE FROM fines")
+	mysqlinstance.DB.Exec("DELETE FROM borrow_records")
+	mysqlinstance.DB.Exec("DELETE FROM transfers")
+	mysqlinstance.DB.Exec("DELETE FROM book_copies")
+	mysqlinstance.DB.Exec("DELETE FROM books")
+	mysqlinstance.DB.Exec("DELETE FROM courses WHERE code='RES101'")
+	redisInstance.Client.FlushAll(context.Background())
+	mysqlinstance.DB.Exec("INSERT IGNORE INTO students (id, name, email, age, year) VALUES (?, ?, ?, ?, ?)", 801, "reader", "reader801@gmail.com", 20, 1)
+
+	post := func(path string, v any) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(v)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, path, bytes.NewBuffer(body)))
+		return w
+	}
+
+	w := post("/courses", managementsystem.Course{Code: "RES101", Title: "Reserves"})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected created status , got %d: %s", w.Code, w.Body.String())
+	}
+	var course managementsystem.Course
+	json.NewDecoder(w.Body).Decode(&course)
+	reservesPath := "/courses/" + strconv.Itoa(course.ID) + "/reserves"
+
+	w = post("/books", managementsystem.Book{Title: "GoLang", Author: "Alice", Available_copies: 2})
+	var book managementsystem.Book
+	if err := json.NewDecoder(w.Body).Decode(&book); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	endsOn := time.Now().AddDate(0, 3, 0).Format(time.DateOnly)
+
+	var reserve managementsystem.CourseReserve
+	var loan managementsystem.Borrow_records
+	tests := []struct {
+		name   string // description of this test case
+		run    func() *httptest.ResponseRecorder
+		status int
+	}{
+		{
+			name: "ends in the past",
+			run: func() *httptest.ResponseRecorder {
+				return post(reservesPath, managementsystem.CourseReserve{BookID: book.Book_id, EndsOn: "2020-01-01"})
+			},
+			status: http.StatusBadRequest,
+		},
+		{
+			name: "more copies than the library has",
+			run: func() *httptest.ResponseRecorder {
+				return post(reservesPath, managementsystem.CourseReserve{BookID: book.Book_id, Copies: 3, EndsOn: endsOn})
+			},
+			status: http.StatusConflict,
+		},
+		{
+			name: "reserve a copy",
+			run: func() *httptest.ResponseRecorder {
+				w := post(reservesPath, managementsystem.CourseReserve{BookID: book.Book_id, EndsOn: endsOn})
+				json.Unmarshal(w.Body.Bytes(), &reserve)
+				if reserve.Copies != 1 || reserve.Status != "active" {
+					t.Fatalf("Expected one copy on an active reserve, got %+v", reserve)
+				}
+				return w
+			},
+			status: http.StatusCreated,
+		},
+		{
+			name: "reserve the book twice",
+			run: func() *httptest.ResponseRecorder {
+				return post(reservesPath, managementsystem.CourseReserve{BookID: book.Book_id, EndsOn: endsOn})
+			},
+			status: http.StatusConflict,
+		},
+		{
+			name: "borrow by book lends the copy not on reserve",
+			run: func() *httptest.ResponseRecorder {
+				w := post("/borrow", managementsystem.Borrow_records{User_id: 801, User_type: "student", Book_id: book.Book_id})
+				var normal managementsystem.Borrow_records
+				json.Unmarshal(w.Body.Bytes(), &normal)
+				if normal.Due_at != nil {
+					t.Fatalf("Expected a normal loan, got %+v", normal)
+				}
+				return w
+			},
+			status: http.StatusCreated,
+		},
+		{
+			name: "a new copy is not put on reserve",
+			run: func() *httptest.ResponseRecorder {
+				w := post("/books/"+strconv.Itoa(book.Book_id)+"/copies", managementsystem.BookCopy{Reserve_id: &reserve.ID})
+				var c managementsystem.BookCopy
+				json.Unmarshal(w.Body.Bytes(), &c)
+				if c.Reserve_id != nil {
+					t.Fatalf("Expected a copy off reserve, got %+v", c)
+				}
+				return w
+			},
+			status: http.StatusCreated,
+		},
+		{
+			name: "borrow the reserve copy on a short loan",
+			run: func() *httptest.ResponseRecorder {
+				var copies []managementsystem.BookCopy
+				w := httptest.NewRecorder()
+				router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/books/"+strconv.Itoa(book.Book_id)+"/copies", nil))
+				json.NewDecoder(w.Body).Decode(&copies)
+				barcode := ""
+				for _, c := range copies {
+					if c.Reserve_id != nil {
+						barcode = c.Barcode
+					}
+				}
+				w = post("/borrow", managementsystem.Borrow_records{User_id: 801, User_type: "student", Barcode: barcode})
+				json.Unmarshal(w.Body.Bytes(), &loan)
+				if loan.Due_at == nil {
+					t.Fatalf("Expected a short loan, got %+v", loan)
+				}
+				return w
+			},
+			status: http.StatusCreated,
+		},
+		{
+			name: "renew the short loan",
+			run: func() *httptest.ResponseRecorder {
+				return post("/borrows/"+strconv.Itoa(loan.Borrow_id)+"/renew", nil)
+			},
+			status: http.StatusConflict,
+		},
+		{
+			name: "release",
+			run: func() *httptest.ResponseRecorder {
+				return post("/reserves/"+strconv.Itoa(reserve.ID)+"/release", nil)
+			},
+			status: http.StatusOK,
+		},
+		{
+			name: "release twice",
+			run: func() *httptest.ResponseRecorder {
+				return post("/reserves/"+strconv.Itoa(reserve.ID)+"/release", nil)
+			},
+			status: http.StatusConflict,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w := tt.run(); w.Code != tt.status {
+				t.Fatalf("Expected status %d, got %d: %s", tt.status, w.Code, w.Body.String())
+			}
+		})
+	}
+
+	// the released reserve only shows in the full list
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, reservesPath+"?status=all", nil))
+	var reserves []managementsystem.CourseReserve
+	if err := json.NewDecoder(w.Body).Decode(&reserves); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(reserves) != 1 || reserves[0].Status != "released" || reserves[0].Copies != 0 {
+		t.Fatalf("Expected the released reserve, got %+v", reserves)
+	}
+}
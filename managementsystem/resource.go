@@ -0,0 +1,534 @@
+package managementsystem
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/gorilla/mux"
+	"managementsystem/validation"
+)
+
+// Resource describes an entity stored in one MySQL table and cached in
+// Redis, and implements the create/get/list/update/patch/delete handlers
+// for it once for every entity.
+type Resource[T any] struct {
+	Name        string   // singular name used in responses, e.g. "student"
+	Path        string   // route prefix, e.g. "/students"
+	Table       string   // MySQL table
+	Key         string   // integer primary key column
+	Columns     []string // columns written on create and update
+	Computed    []string // read-only SQL expressions selected after Columns
+	CachePrefix string   // Redis key prefix, e.g. "student:"
+	CacheTTL    time.Duration
+
+	// Fields returns pointers to the key followed by Columns and Computed,
+	// in order.
+	Fields func(item *T) []any
+	// Validate checks an item before it is written.
+	Validate func(item *T) error
+	// ValidateCreate, when set, adds checks that only apply to new items.
+	ValidateCreate func(item *T) error
+	// Prepare, when set, runs before Validate, e.g. to resolve references.
+	Prepare func(h *HybridHandler5, item *T) error
+	// AfterSave, when set, runs in the same transaction after an item is
+	// created or updated.
+	AfterSave func(h *HybridHandler5, tx querier, item *T) error
+	// AfterCreate, when set, runs in the same transaction after an item is
+	// created.
+	AfterCreate func(h *HybridHandler5, tx querier, item *T) error
+	// AfterCommit, when set, runs once the transaction that created or
+	// updated an item is committed, e.g. to drop cached copies of rows
+	// AfterSave changed.
+	AfterCommit func(h *HybridHandler5, item *T)
+	// Expand, when set, fills in fields kept outside Table after an item is
+	// read.
+	Expand func(q querier, item *T) error
+}
+
+// Register adds the CRUD routes of the resource to the router.
+func (res *Resource[T]) Register(r *mux.Router, h *HybridHandler5) {
+	r.HandleFunc(res.Path, func(w http.ResponseWriter, r *http.Request) { res.Create(h, w, r) }).Methods("POST")
+	r.HandleFunc(res.Path, func(w http.ResponseWriter, r *http.Request) { res.List(h, w, r) }).Methods("GET")
+	r.HandleFunc(res.Path+"/{id}", func(w http.ResponseWriter, r *http.Request) { res.Get(h, w, r) }).Methods("GET")
+	r.HandleFunc(res.Path+"/{id}", func(w http.ResponseWriter, r *http.Request) { res.Update(h, w, r) }).Methods("PUT")
+	r.HandleFunc(res.Path+"/{id}", func(w http.ResponseWriter, r *http.Request) { res.Patch(h, w, r) }).Methods("PATCH")
+	r.HandleFunc(res.Path+"/{id}", func(w http.ResponseWriter, r *http.Request) { res.Delete(h, w, r) }).Methods("DELETE")
+}
+
+// Create inserts the item in the request body.
+func (res *Resource[T]) Create(h *HybridHandler5, w http.ResponseWriter, r *http.Request) {
+	var item T
+	if err := json.NewDecoder(r.Body).Decode(&item); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !res.check(h, w, r, &item) {
+		return
+	}
+	if res.ValidateCreate != nil {
+		if err := res.ValidateCreate(&item); err != nil {
+			writeValidationError(w, r, err)
+			return
+		}
+	}
+	tx, err := h.MySQL.DB.Begin()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	if err := res.insert(h, tx, &item); err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if res.AfterCommit != nil {
+		res.AfterCommit(h, &item)
+	}
+	writeJSON(w, http.StatusCreated, item)
+}
+
+// insert writes a checked item, sets its key and runs AfterCreate and
+// AfterSave.
+func (res *Resource[T]) insert(h *HybridHandler5, tx querier, item *T) error {
+	fields := res.Fields(item)
+	query := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", res.Table, strings.Join(res.Columns, ", "), placeholders(len(res.Columns)))
+	result, err := tx.Exec(query, values(fields[1:1+len(res.Columns)])...)
+	if err != nil {
+		return err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	*fields[0].(*int) = int(id)
+	if res.AfterCreate != nil {
+		if err := res.AfterCreate(h, tx, item); err != nil {
+			return err
+		}
+	}
+	if res.AfterSave != nil {
+		return res.AfterSave(h, tx, item)
+	}
+	return nil
+}
+
+// Get returns one item, from the cache when possible.
+func (res *Resource[T]) Get(h *HybridHandler5, w http.ResponseWriter, r *http.Request) {
+	id, ok := res.id(w, r)
+	if !ok {
+		return
+	}
+	if value, err := h.cacheGet(res.cacheKey(id)); err == nil {
+		log.Println("Cache hit!")
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(value)
+		return
+	}
+	fmt.Println("Cache miss Quering MySQL ...")
+	item, err := res.load(h, id)
+	if err != nil {
+		res.writeLoadError(w, err)
+		return
+	}
+	jsondata, err := json.Marshal(item)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	h.cacheSet(res.cacheKey(id), jsondata, res.CacheTTL)
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(jsondata)
+}
+
+// List returns a page of items ordered by key, sized by the limit and
+// offset query parameters.
+func (res *Resource[T]) List(h *HybridHandler5, w http.ResponseWriter, r *http.Request) {
+	limit, offset, err := pagination(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	rows, err := h.MySQL.DB.Query(res.selectSQL()+" ORDER BY "+res.Key+" LIMIT ? OFFSET ?", limit, offset)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	items := []T{}
+	for rows.Next() {
+		var item T
+		if err := rows.Scan(res.Fields(&item)...); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	for i := range items {
+		if err := res.expand(h.MySQL.DB, &items[i]); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	writeJSON(w, http.StatusOK, items)
+}
+
+// Update replaces an item with the request body. The id in the URL wins
+// over the one in the body.
+func (res *Resource[T]) Update(h *HybridHandler5, w http.ResponseWriter, r *http.Request) {
+	var item T
+	if err := json.NewDecoder(r.Body).Decode(&item); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if _, ok := mux.Vars(r)["id"]; ok {
+		id, ok := res.id(w, r)
+		if !ok {
+			return
+		}
+		*res.Fields(&item)[0].(*int) = id
+	}
+	res.save(h, w, r, &item)
+}
+
+// Patch changes only the fields present in the request body.
+func (res *Resource[T]) Patch(h *HybridHandler5, w http.ResponseWriter, r *http.Request) {
+	id, ok := res.id(w, r)
+	if !ok {
+		return
+	}
+	item, err := res.load(h, id)
+	if err != nil {
+		res.writeLoadError(w, err)
+		return
+	}
+	if err := json.NewDecoder(r.Body).Decode(item); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	*res.Fields(item)[0].(*int) = id
+	res.save(h, w, r, item)
+}
+
+// Delete removes an item and its cache entry.
+func (res *Resource[T]) Delete(h *HybridHandler5, w http.ResponseWriter, r *http.Request) {
+	id, ok := res.id(w, r)
+	if !ok {
+		return
+	}
+	result, err := h.MySQL.DB.Exec("DELETE FROM "+res.Table+" WHERE "+res.Key+"=?", id)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if rows == 0 {
+		http.Error(w, res.Name+" not found", http.StatusNotFound)
+		return
+	}
+	h.cacheDel(res.cacheKey(id))
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(res.Name + " deleted"))
+}
+
+// save validates and writes an existing item, then refreshes the cache.
+func (res *Resource[T]) save(h *HybridHandler5, w http.ResponseWriter, r *http.Request, item *T) {
+	if !res.check(h, w, r, item) {
+		return
+	}
+	fields := res.Fields(item)
+	id := *fields[0].(*int)
+
+	var exists bool
+	err := h.MySQL.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM "+res.Table+" WHERE "+res.Key+"=?)", id).Scan(&exists)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !exists {
+		http.Error(w, res.Name+" not found", http.StatusNotFound)
+		return
+	}
+	assignments := make([]string, len(res.Columns))
+	for i, column := range res.Columns {
+		assignments[i] = column + "=?"
+	}
+	tx, err := h.MySQL.DB.Begin()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	args := append(values(fields[1:1+len(res.Columns)]), id)
+	if _, err := tx.Exec("UPDATE "+res.Table+" SET "+strings.Join(assignments, ", ")+" WHERE "+res.Key+"=?", args...); err != nil {
+		writeDBError(w, err)
+		return
+	}
+	if res.AfterSave != nil {
+		if err := res.AfterSave(h, tx, item); err != nil {
+			writeError(w, err)
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if res.AfterCommit != nil {
+		res.AfterCommit(h, item)
+	}
+	if len(res.Computed) > 0 {
+		// computed fields in the body are not trusted
+		if item, err = res.load(h, id); err != nil {
+			res.writeLoadError(w, err)
+			return
+		}
+	}
+	jsonData, err := json.Marshal(item)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	h.cacheSet(res.cacheKey(id), jsonData, res.CacheTTL)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(jsonData)
+}
+
+// check runs Prepare and Validate, answering the request when either fails.
+func (res *Resource[T]) check(h *HybridHandler5, w http.ResponseWriter, r *http.Request, item *T) bool {
+	if res.Prepare != nil {
+		if err := res.Prepare(h, item); err != nil {
+			writeError(w, err)
+			return false
+		}
+	}
+	if err := res.Validate(item); err != nil {
+		writeValidationError(w, r, err)
+		return false
+	}
+	return true
+}
+
+// load reads one item from MySQL.
+func (res *Resource[T]) load(h *HybridHandler5, id int) (*T, error) {
+	var item T
+	if err := h.MySQL.DB.QueryRow(res.selectSQL()+" WHERE "+res.Key+"=?", id).Scan(res.Fields(&item)...); err != nil {
+		return nil, err
+	}
+	if err := res.expand(h.MySQL.DB, &item); err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// expand runs Expand, if any, on an item just read.
+func (res *Resource[T]) expand(q querier, item *T) error {
+	if res.Expand == nil {
+		return nil
+	}
+	return res.Expand(q, item)
+}
+
+func (res *Resource[T]) writeLoadError(w http.ResponseWriter, err error) {
+	writeLoadError(w, res.Name, err)
+}
+
+// writeLoadError answers a failed lookup of a name with 404 when the row is
+// missing and 500 otherwise.
+func writeLoadError(w http.ResponseWriter, name string, err error) {
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, name+" not found", http.StatusNotFound)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
+
+func (res *Resource[T]) selectSQL() string {
+	columns := append([]string{res.Key}, res.Columns...)
+	return "SELECT " + strings.Join(append(columns, res.Computed...), ", ") + " FROM " + res.Table
+}
+
+func (res *Resource[T]) cacheKey(id int) string {
+	return res.CachePrefix + strconv.Itoa(id)
+}
+
+// id parses the {id} route variable, answering 400 when it is not a number.
+func (res *Resource[T]) id(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "invalid "+res.Name+" id", http.StatusBadRequest)
+		return 0, false
+	}
+	return id, true
+}
+
+// cacheGet, cacheSet and cacheDel treat a missing Redis as a cache miss so
+// the service keeps working on MySQL alone.
+func (h *HybridHandler5) cacheGet(key string) ([]byte, error) {
+	if h.Redis == nil || h.Redis.Client == nil {
+		return nil, errors.New("cache disabled")
+	}
+	return h.Redis.Client.Get(h.Ctx, key).Bytes()
+}
+
+func (h *HybridHandler5) cacheSet(key string, value []byte, ttl time.Duration) {
+	if h.Redis != nil && h.Redis.Client != nil {
+		_ = h.Redis.Client.Set(h.Ctx, key, value, ttl).Err()
+	}
+}
+
+func (h *HybridHandler5) cacheDel(key string) {
+	if h.Redis != nil && h.Redis.Client != nil {
+		_ = h.Redis.Client.Del(h.Ctx, key).Err()
+	}
+}
+
+// querier is what *sql.DB and *sql.Tx have in common, so lookups can run
+// inside or outside a transaction.
+type querier interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// httpError is an error answered with its own status code, and with Body
+// as JSON when it is set.
+type httpError struct {
+	Status  int
+	Message string
+	Body    any
+}
+
+func (e *httpError) Error() string {
+	return e.Message
+}
+
+func newHTTPError(status int, format string, args ...any) *httpError {
+	return &httpError{Status: status, Message: fmt.Sprintf(format, args...)}
+}
+
+// writeError answers an httpError with its status, a MySQL constraint
+// violation with 409 and anything else with 500.
+func writeError(w http.ResponseWriter, err error) {
+	var he *httpError
+	if errors.As(err, &he) {
+		if he.Body != nil {
+			writeJSON(w, he.Status, he.Body)
+			return
+		}
+		http.Error(w, he.Message, he.Status)
+		return
+	}
+	writeDBError(w, err)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeValidationError(w http.ResponseWriter, r *http.Request, err error) {
+	writeJSON(w, http.StatusBadRequest, map[string]string{"Error": validation.Translate(err, r.Header.Get("Accept-Language")).Error()})
+}
+
+// writeDBError answers constraint violations with 409 and anything else
+// with 500.
+func writeDBError(w http.ResponseWriter, err error) {
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		switch mysqlErr.Number {
+		case 1062:
+			http.Error(w, "duplicate entry: "+mysqlErr.Message, http.StatusConflict)
+			return
+		case 1451, 1452:
+			http.Error(w, "conflicting reference: "+mysqlErr.Message, http.StatusConflict)
+			return
+		}
+	}
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
+
+// pagination reads the limit (default 50, max 500) and offset query
+// parameters.
+func pagination(r *http.Request) (int, int, error) {
+	limit, offset := 50, 0
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > 500 {
+			return 0, 0, fmt.Errorf("limit must be between 1 and 500")
+		}
+		limit = n
+	}
+	if v := r.URL.Query().Get("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return 0, 0, fmt.Errorf("offset must not be negative")
+		}
+		offset = n
+	}
+	return limit, offset, nil
+}
+
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+// nullString maps an empty string to NULL, for optional unique columns.
+type nullString struct{ s *string }
+
+func (n nullString) Value() (driver.Value, error) {
+	if *n.s == "" {
+		return nil, nil
+	}
+	return *n.s, nil
+}
+
+func (n *nullString) Scan(src any) error {
+	switch v := src.(type) {
+	case nil:
+		*n.s = ""
+	case []byte:
+		*n.s = string(v)
+	case string:
+		*n.s = v
+	default:
+		return fmt.Errorf("cannot scan %T into a string", src)
+	}
+	return nil
+}
+
+// values dereferences the field pointers returned by Resource.Fields.
+func values(pointers []any) []any {
+	out := make([]any, len(pointers))
+	for i, p := range pointers {
+		out[i] = reflect.ValueOf(p).Elem().Interface()
+	}
+	return out
+}
@@ -0,0 +1,165 @@
+package managementsystem_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	managementsystem "managementsystem/managementsystem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestResource_ListStudents(t *testing.T) {
+
+	os.Setenv("REDIS_ADDR", "localhost:6379")
+	os.Setenv("MySQL_DSN", "root:root@tcp(127.0.0.1:3306)/management_sys")
+
+	redisInstance, err := managementsystem.ConnectRedis()
+	if err != nil {
+		panic(err)
+	}
+	mysqlinstance, err := managementsystem.ConnectMySQL()
+	if err != nil {
+		panic(err)
+	}
+	handler := &managementsystem.HybridHandler5{MySQL: mysqlinstance, Redis: redisInstance, Ctx: context.Background()}
+	router := handler.Router()
+
+	mysqlinstance.DB.Exec("DELETE FROM students")
+	mysqlinstance.DB.Exec("ALTER TABLE students AUTO_INCREMENT=1")
+	redisInstance.Client.FlushAll(context.Background())
+
+	for _, email := range []string{"akash@gmail.com", "kunal@gmail.com", "sujan@gmail.com"} {
+		if _, err := mysqlinstance.DB.Exec("INSERT INTO students (name , email, age , dept , year) VALUES (?, ?, ?, ?, ?)", "Akash", email, 20, "CSE", 3); err != nil {
+			t.Fatalf("insert fail: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name     string // description of this test case
+		query    string
+		want     int
+		willpass bool
+	}{
+		{
+			name:     "all students",
+			query:    "",
+			want:     3,
+			willpass: true,
+		},
+		{
+			name:     "second page",
+			query:    "?limit=2&offset=2",
+			want:     1,
+			willpass: true,
+		},
+		{
+			name:     "invalid limit",
+			query:    "?limit=abc",
+			willpass: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/students"+tt.query, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, r)
+
+			if tt.willpass {
+				if w.Code != http.StatusOK {
+					t.Fatalf("Expected ok status , got %d", w.Code)
+				}
+				var students []managementsystem.Student
+				if err := json.NewDecoder(w.Body).Decode(&students); err != nil {
+					t.Fatalf("failed to decode response: %v", err)
+				}
+				if len(students) != tt.want {
+					t.Fatalf("Expected %d students, got %d", tt.want, len(students))
+				}
+			} else {
+				if w.Code != http.StatusBadRequest {
+					t.Fatalf("Expected bad request status , got %d", w.Code)
+				}
+			}
+		})
+	}
+}
+
+func TestResource_PatchLecturer(t *testing.T) {
+
+	os.Setenv("REDIS_ADDR", "localhost:6379")
+	os.Setenv("MySQL_DSN", "root:root@tcp(127.0.0.1:3306)/management_sys")
+
+	redisInstance, err := managementsystem.ConnectRedis()
+	if err != nil {
+		panic(err)
+	}
+	mysqlinstance, err := managementsystem.ConnectMySQL()
+	if err != nil {
+		panic(err)
+	}
+	handler := &managementsystem.HybridHandler5{MySQL: mysqlinstance, Redis: redisInstance, Ctx: context.Background()}
+	router := handler.Router()
+
+	mysqlinstance.DB.Exec("DELETE FROM lecturers")
+	mysqlinstance.DB.Exec("ALTER TABLE lecturers AUTO_INCREMENT=1")
+	redisInstance.Client.FlushAll(context.Background())
+
+	_, err = mysqlinstance.DB.Exec("INSERT INTO lecturers (id , name , email, dept , designation) VALUES (?, ?, ?, ?, ?)", 1, "ramesh", "ramesh@gmail.com", "CSE", "Senior Lecturer")
+	if err != nil {
+		t.Fatalf("insert fail: %v", err)
+	}
+
+	tests := []struct {
+		name     string // description of this test case
+		path     string
+		body     map[string]any
+		status   int
+		willpass bool
+	}{
+		{
+			name:     "patch name only",
+			path:     "/lecturers/1",
+			body:     map[string]any{"name": "ramesh kumar"},
+			status:   http.StatusOK,
+			willpass: true,
+		},
+		{
+			name:     "patch with invalid email",
+			path:     "/lecturers/1",
+			body:     map[string]any{"email": "ramesh@yahoo.com"},
+			status:   http.StatusBadRequest,
+			willpass: false,
+		},
+		{
+			name:     "patch missing lecturer",
+			path:     "/lecturers/4367",
+			body:     map[string]any{"name": "ramesh"},
+			status:   http.StatusNotFound,
+			willpass: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, _ := json.Marshal(tt.body)
+			r := httptest.NewRequest(http.MethodPatch, tt.path, bytes.NewBuffer(body))
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, r)
+
+			if w.Code != tt.status {
+				t.Fatalf("Expected status %d, got %d", tt.status, w.Code)
+			}
+			if tt.willpass {
+				var lecturer managementsystem.Lecturer
+				if err := json.NewDecoder(w.Body).Decode(&lecturer); err != nil {
+					t.Fatalf("failed to decode response: %v", err)
+				}
+				if lecturer.Name != "ramesh kumar" || lecturer.Email != "ramesh@gmail.com" {
+					t.Fatalf("Expected only the name to change, got %+v", lecturer)
+				}
+			}
+		})
+	}
+}
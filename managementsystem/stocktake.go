@@ -0,0 +1,252 @@
+package managementsystem
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"managementsystem/validation"
+)
+
+const (
+	stocktakeOpen   = "open"
+	stocktakeClosed = "closed"
+)
+
+// Stocktake is a count of the copies on the shelves. Location limits it to
+// copies whose shelf location starts with it.
+type Stocktake struct {
+	ID        int     `json:"id"`
+	Location  string  `json:"location" validate:"max=50"`
+	StartedBy string  `json:"started_by" validate:"trimmed,max=100"`
+	Status    string  `json:"status"`
+	StartedAt string  `json:"started_at"`
+	ClosedAt  *string `json:"closed_at"`
+	Scanned   int     `json:"scanned"`
+}
+
+type StocktakeScan struct {
+	Barcodes []string `json:"barcodes"`
+}
+
+// ScanResult tells which of the scanned barcodes were counted.
+type ScanResult struct {
+	Counted int      `json:"counted"`
+	Repeats int      `json:"repeats"` // barcodes already scanned in this stock-take
+	Unknown []string `json:"unknown"`
+}
+
+// StockItem is a copy a stock-take did not expect to find where it did, or
+// did not find where it expected it.
+type StockItem struct {
+	Copy_id        int    `json:"copy_id"`
+	Book_id        int    `json:"book_id"`
+	Title          string `json:"title"`
+	Barcode        string `json:"barcode"`
+	Shelf_location string `json:"shelf_location"`
+	Status         string `json:"status"`
+}
+
+type StocktakeReport struct {
+	Stocktake
+	Expected   int         `json:"expected"`
+	Missing    []StockItem `json:"missing"`    // on the shelf by status but not scanned
+	Unexpected []StockItem `json:"unexpected"` // scanned although on loan, in transit, lost or retired
+}
+
+const stocktakeSelect = `SELECT s.id, IFNULL(s.location, ''), IFNULL(s.started_by, ''), s.status,
+	DATE_FORMAT(s.started_at, '%Y-%m-%d %H:%i:%s'), DATE_FORMAT(s.closed_at, '%Y-%m-%d %H:%i:%s'),
+	(SELECT COUNT(*) FROM stocktake_scans x WHERE x.stocktake_id = s.id)
+	FROM stocktakes s`
+
+// loadStocktake reads a stock-take, locking it when q is a transaction.
+func loadStocktake(q querier, id int) (*Stocktake, error) {
+	var s Stocktake
+	err := q.QueryRow(stocktakeSelect+" WHERE s.id=? FOR UPDATE", id).Scan(&s.ID, &s.Location, &s.StartedBy, &s.Status, &s.StartedAt, &s.ClosedAt, &s.Scanned)
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func stocktakeID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "invalid stocktake id", http.StatusBadRequest)
+		return 0, false
+	}
+	return id, true
+}
+
+// Start a stock-take
+func (h *HybridHandler5) StartStocktakeHandler(w http.ResponseWriter, r *http.Request) {
+	var s Stocktake
+	if err := json.NewDecoder(r.Body).Decode(&s); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := validation.Struct(s); err != nil {
+		writeValidationError(w, r, err)
+		return
+	}
+	res, err := h.MySQL.DB.Exec("INSERT INTO stocktakes (location, started_by, status) VALUES (?, ?, ?)", nullString{&s.Location}, nullString{&s.StartedBy}, stocktakeOpen)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	started, err := loadStocktake(h.MySQL.DB, int(id))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusCreated, started)
+}
+
+// Scan copies into a stock-take
+func (h *HybridHandler5) ScanStocktakeHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := stocktakeID(w, r)
+	if !ok {
+		return
+	}
+	var scan StocktakeScan
+	if err := json.NewDecoder(r.Body).Decode(&scan); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(scan.Barcodes) == 0 {
+		http.Error(w, "barcodes is required", http.StatusBadRequest)
+		return
+	}
+	tx, err := h.MySQL.DB.Begin()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	s, err := loadStocktake(tx, id)
+	if err != nil {
+		writeLoadError(w, "stocktake", err)
+		return
+	}
+	if s.Status != stocktakeOpen {
+		http.Error(w, "stocktake is closed", http.StatusConflict)
+		return
+	}
+	result := ScanResult{Unknown: []string{}}
+	for _, barcode := range scan.Barcodes {
+		barcode = strings.TrimSpace(barcode)
+		res, err := tx.Exec("INSERT IGNORE INTO stocktake_scans (stocktake_id, copy_id) SELECT ?, copy_id FROM book_copies WHERE barcode=?", id, barcode)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if n, _ := res.RowsAffected(); n > 0 {
+			result.Counted++
+			continue
+		}
+		var known bool
+		if err := tx.QueryRow("SELECT EXISTS(SELECT 1 FROM book_copies WHERE barcode=?)", barcode).Scan(&known); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if known {
+			result.Repeats++
+		} else {
+			result.Unknown = append(result.Unknown, barcode)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// Stock-take report of missing and unexpected copies
+func (h *HybridHandler5) StocktakeReportHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := stocktakeID(w, r)
+	if !ok {
+		return
+	}
+	s, err := loadStocktake(h.MySQL.DB, id)
+	if err != nil {
+		writeLoadError(w, "stocktake", err)
+		return
+	}
+	report := StocktakeReport{Stocktake: *s}
+
+	// copies available or set aside for a hold should be on the shelves
+	inScope := "c.shelf_location LIKE CONCAT(?, '%')"
+	if s.Location == "" {
+		inScope = "? = ''"
+	}
+	err = h.MySQL.DB.QueryRow("SELECT COUNT(*) FROM book_copies c WHERE c.status IN (?, ?) AND "+inScope, copyAvailable, copyOnHold, s.Location).Scan(&report.Expected)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	report.Missing, err = stockItems(h.MySQL.DB, "c.status IN (?, ?) AND "+inScope+" AND NOT EXISTS (SELECT 1 FROM stocktake_scans x WHERE x.stocktake_id=? AND x.copy_id=c.copy_id)", copyAvailable, copyOnHold, s.Location, id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	report.Unexpected, err = stockItems(h.MySQL.DB, "c.status IN (?, ?, ?, ?) AND EXISTS (SELECT 1 FROM stocktake_scans x WHERE x.stocktake_id=? AND x.copy_id=c.copy_id)", copyBorrowed, copyInTransit, copyLost, copyRetired, id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, report)
+}
+
+func stockItems(q querier, where string, args ...any) ([]StockItem, error) {
+	rows, err := q.Query("SELECT c.copy_id, c.book_id, b.title, c.barcode, IFNULL(c.shelf_location, ''), c.status FROM book_copies c JOIN books b ON b.book_id = c.book_id WHERE "+where+" ORDER BY c.shelf_location, c.barcode", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []StockItem{}
+	for rows.Next() {
+		var item StockItem
+		if err := rows.Scan(&item.Copy_id, &item.Book_id, &item.Title, &item.Barcode, &item.Shelf_location, &item.Status); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+// Close a stock-take
+func (h *HybridHandler5) CloseStocktakeHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := stocktakeID(w, r)
+	if !ok {
+		return
+	}
+	res, err := h.MySQL.DB.Exec("UPDATE stocktakes SET status=?, closed_at=NOW() WHERE id=? AND status=?", stocktakeClosed, id, stocktakeOpen)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		if _, err := loadStocktake(h.MySQL.DB, id); err != nil {
+			writeLoadError(w, "stocktake", err)
+			return
+		}
+		http.Error(w, "stocktake is closed", http.StatusConflict)
+		return
+	}
+	s, err := loadStocktake(h.MySQL.DB, id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, s)
+}
@@ -0,0 +1,490 @@
+package managementsystem
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gorilla/mux"
+	"managementsystem/validation"
+)
+
+type Course struct {
+	ID     int    `json:"id"`
+	Code   string `json:"code" validate:"trimmed,required,max=20"`
+	Title  string `json:"title" validate:"trimmed,required,max=100"`
+	DeptID *int   `json:"dept_id"`
+}
+
+type Room struct {
+	ID       int    `json:"id"`
+	Name     string `json:"name" validate:"trimmed,required,max=50"`
+	Capacity int    `json:"capacity" validate:"min=1"`
+}
+
+// Session is a weekly course meeting. Weekday runs from 1 (Monday) to 7
+// (Sunday) and times are "HH:MM:SS".
+type Session struct {
+	ID         int    `json:"id"`
+	CourseID   int    `json:"course_id" validate:"min=1"`
+	RoomID     int    `json:"room_id" validate:"min=1"`
+	LecturerID int    `json:"lecturer_id" validate:"min=1"`
+	Weekday    int    `json:"weekday" validate:"min=1,max=7"`
+	StartTime  string `json:"start_time" validate:"required"`
+	EndTime    string `json:"end_time" validate:"required"`
+}
+
+// Conflict is a clash between a session and an existing one. Type is
+// "lecturer", "room" or "student".
+type Conflict struct {
+	Type      string `json:"type"`
+	SessionID int    `json:"session_id"`
+	StudentID int    `json:"student_id,omitempty"`
+	Message   string `json:"message"`
+}
+
+// TimetableConflict is a clash between two sessions already in the
+// timetable.
+type TimetableConflict struct {
+	SessionID     int    `json:"session_id"`
+	ConflictsWith int    `json:"conflicts_with"`
+	Type          string `json:"type"`
+	StudentID     int    `json:"student_id,omitempty"`
+	Message       string `json:"message"`
+}
+
+type TimetableEntry struct {
+	Session
+	CourseCode  string `json:"course_code"`
+	CourseTitle string `json:"course_title"`
+	Room        string `json:"room"`
+	Lecturer    string `json:"lecturer"`
+}
+
+// validation
+func ValidateSession(session Session) error {
+	return validation.Struct(session)
+}
+
+var courseResource = &Resource[Course]{
+	Name:        "course",
+	Path:        "/courses",
+	Table:       "courses",
+	Key:         "id",
+	Columns:     []string{"code", "title", "dept_id"},
+	CachePrefix: "course:",
+	CacheTTL:    10 * time.Minute,
+	Fields: func(c *Course) []any {
+		return []any{&c.ID, &c.Code, &c.Title, &c.DeptID}
+	},
+	Validate: func(c *Course) error { return validation.Struct(c) },
+	Prepare: func(h *HybridHandler5, c *Course) error {
+		c.Code = strings.ToUpper(strings.TrimSpace(c.Code))
+		return nil
+	},
+}
+
+var roomResource = &Resource[Room]{
+	Name:        "room",
+	Path:        "/rooms",
+	Table:       "rooms",
+	Key:         "id",
+	Columns:     []string{"name", "capacity"},
+	CachePrefix: "room:",
+	CacheTTL:    10 * time.Minute,
+	Fields: func(r *Room) []any {
+		return []any{&r.ID, &r.Name, &r.Capacity}
+	},
+	Validate: func(r *Room) error { return validation.Struct(r) },
+}
+
+var sessionResource = &Resource[Session]{
+	Name:        "session",
+	Path:        "/sessions",
+	Table:       "course_sessions",
+	Key:         "id",
+	Columns:     []string{"course_id", "room_id", "lecturer_id", "weekday", "start_time", "end_time"},
+	CachePrefix: "session:",
+	CacheTTL:    10 * time.Minute,
+	Fields:      (*Session).fields,
+	Validate:    func(s *Session) error { return ValidateSession(*s) },
+	Prepare: func(h *HybridHandler5, s *Session) error {
+		if err := s.normalize(); err != nil {
+			return err
+		}
+		conflicts, err := h.sessionConflicts(*s)
+		if err != nil {
+			return err
+		}
+		if len(conflicts) > 0 {
+			return &httpError{Status: http.StatusConflict, Message: "session conflicts", Body: map[string]any{"Error": "session conflicts", "conflicts": conflicts}}
+		}
+		return nil
+	},
+}
+
+func (s *Session) fields() []any {
+	return []any{&s.ID, &s.CourseID, &s.RoomID, &s.LecturerID, &s.Weekday, &s.StartTime, &s.EndTime}
+}
+
+// normalize turns "9:00" style times into "09:00:00".
+func (s *Session) normalize() error {
+	for _, t := range []*string{&s.StartTime, &s.EndTime} {
+		if *t == "" {
+			continue
+		}
+		parsed, err := time.Parse("15:04:05", *t)
+		if err != nil {
+			parsed, err = time.Parse("15:04", *t)
+		}
+		if err != nil {
+			return newHTTPError(http.StatusBadRequest, "invalid time %q, use HH:MM", *t)
+		}
+		*t = parsed.Format("15:04:05")
+	}
+	if s.StartTime != "" && s.EndTime != "" && s.StartTime >= s.EndTime {
+		return newHTTPError(http.StatusBadRequest, "start_time must be before end_time")
+	}
+	return nil
+}
+
+// Overlaps reports whether two weekly sessions share any time.
+func (s Session) Overlaps(o Session) bool {
+	return s.Weekday == o.Weekday && s.StartTime < o.EndTime && o.StartTime < s.EndTime
+}
+
+// FindConflicts lists what candidate clashes with among others: the same
+// lecturer or room at an overlapping time, or a student enrolled in both
+// courses. enrolled maps course ids to their students.
+func FindConflicts(candidate Session, others []Session, enrolled map[int][]int) []Conflict {
+	var conflicts []Conflict
+	for _, other := range others {
+		if other.ID == candidate.ID || !candidate.Overlaps(other) {
+			continue
+		}
+		if other.LecturerID == candidate.LecturerID {
+			conflicts = append(conflicts, Conflict{Type: "lecturer", SessionID: other.ID, Message: fmt.Sprintf("lecturer %d already teaches session %d at this time", other.LecturerID, other.ID)})
+		}
+		if other.RoomID == candidate.RoomID {
+			conflicts = append(conflicts, Conflict{Type: "room", SessionID: other.ID, Message: fmt.Sprintf("room %d is already booked by session %d", other.RoomID, other.ID)})
+		}
+		if other.CourseID == candidate.CourseID {
+			continue
+		}
+		for _, student := range enrolled[candidate.CourseID] {
+			for _, s := range enrolled[other.CourseID] {
+				if s == student {
+					conflicts = append(conflicts, Conflict{Type: "student", SessionID: other.ID, StudentID: student, Message: fmt.Sprintf("student %d is also in session %d at this time", student, other.ID)})
+				}
+			}
+		}
+	}
+	return conflicts
+}
+
+// sessionConflicts checks a session against the ones already scheduled on
+// the same weekday.
+func (h *HybridHandler5) sessionConflicts(s Session) ([]Conflict, error) {
+	others, err := h.sessions("SELECT id, course_id, room_id, lecturer_id, weekday, start_time, end_time FROM course_sessions WHERE weekday=?", s.Weekday)
+	if err != nil {
+		return nil, err
+	}
+	enrolled, err := h.enrollments("SELECT course_id, student_id FROM enrollments WHERE course_id=? OR course_id IN (SELECT course_id FROM course_sessions WHERE weekday=?)", s.CourseID, s.Weekday)
+	if err != nil {
+		return nil, err
+	}
+	return FindConflicts(s, others, enrolled), nil
+}
+
+func (h *HybridHandler5) sessions(query string, args ...any) ([]Session, error) {
+	rows, err := h.MySQL.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []Session
+	for rows.Next() {
+		var s Session
+		if err := rows.Scan(s.fields()...); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, s)
+	}
+	return sessions, rows.Err()
+}
+
+func (h *HybridHandler5) enrollments(query string, args ...any) (map[int][]int, error) {
+	rows, err := h.MySQL.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	enrolled := map[int][]int{}
+	for rows.Next() {
+		var course, student int
+		if err := rows.Scan(&course, &student); err != nil {
+			return nil, err
+		}
+		enrolled[course] = append(enrolled[course], student)
+	}
+	return enrolled, rows.Err()
+}
+
+// Check a session without saving it
+func (h *HybridHandler5) CheckSessionHandler(w http.ResponseWriter, r *http.Request) {
+	var session Session
+	if err := json.NewDecoder(r.Body).Decode(&session); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := session.normalize(); err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := ValidateSession(session); err != nil {
+		writeValidationError(w, r, err)
+		return
+	}
+	conflicts, err := h.sessionConflicts(session)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"conflicts": append([]Conflict{}, conflicts...)})
+}
+
+// Report every conflict in the current timetable
+func (h *HybridHandler5) TimetableConflictsHandler(w http.ResponseWriter, r *http.Request) {
+	sessions, err := h.sessions("SELECT id, course_id, room_id, lecturer_id, weekday, start_time, end_time FROM course_sessions ORDER BY id")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	enrolled, err := h.enrollments("SELECT course_id, student_id FROM enrollments")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	report := []TimetableConflict{}
+	for i, s := range sessions {
+		for _, c := range FindConflicts(s, sessions[i+1:], enrolled) {
+			report = append(report, TimetableConflict{SessionID: s.ID, ConflictsWith: c.SessionID, Type: c.Type, StudentID: c.StudentID, Message: c.Message})
+		}
+	}
+	writeJSON(w, http.StatusOK, report)
+}
+
+// Enroll a student in a course
+func (h *HybridHandler5) EnrollStudentHandler(w http.ResponseWriter, r *http.Request) {
+	courseID, ok := courseResource.id(w, r)
+	if !ok {
+		return
+	}
+	var body struct {
+		StudentID int `json:"student_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if _, err := studentResource.load(h, body.StudentID); err != nil {
+		studentResource.writeLoadError(w, err)
+		return
+	}
+	candidates, err := h.sessions("SELECT id, course_id, room_id, lecturer_id, weekday, start_time, end_time FROM course_sessions WHERE course_id=?", courseID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	current, err := h.sessions("SELECT id, course_id, room_id, lecturer_id, weekday, start_time, end_time FROM course_sessions WHERE course_id IN (SELECT course_id FROM enrollments WHERE student_id=?) AND course_id<>?", body.StudentID, courseID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	enrolled := map[int][]int{courseID: {body.StudentID}}
+	for _, s := range current {
+		enrolled[s.CourseID] = []int{body.StudentID}
+	}
+	var conflicts []Conflict
+	for _, candidate := range candidates {
+		for _, c := range FindConflicts(candidate, current, enrolled) {
+			if c.Type == "student" {
+				conflicts = append(conflicts, c)
+			}
+		}
+	}
+	if len(conflicts) > 0 {
+		writeJSON(w, http.StatusConflict, map[string]any{"Error": "timetable conflicts", "conflicts": conflicts})
+		return
+	}
+	if _, err := h.MySQL.DB.Exec("INSERT INTO enrollments (student_id, course_id) VALUES (?, ?)", body.StudentID, courseID); err != nil {
+		writeDBError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, map[string]int{"student_id": body.StudentID, "course_id": courseID})
+}
+
+// Remove a student from a course
+func (h *HybridHandler5) UnenrollStudentHandler(w http.ResponseWriter, r *http.Request) {
+	courseID, ok := courseResource.id(w, r)
+	if !ok {
+		return
+	}
+	studentID, err := strconv.Atoi(mux.Vars(r)["student_id"])
+	if err != nil {
+		http.Error(w, "invalid student id", http.StatusBadRequest)
+		return
+	}
+	res, err := h.MySQL.DB.Exec("DELETE FROM enrollments WHERE student_id=? AND course_id=?", studentID, courseID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if rows, _ := res.RowsAffected(); rows == 0 {
+		http.Error(w, "enrollment not found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write([]byte("enrollment deleted"))
+}
+
+// timetable loads the sessions matching a condition on course_sessions s.
+func (h *HybridHandler5) timetable(condition string, arg int) ([]TimetableEntry, error) {
+	rows, err := h.MySQL.DB.Query(`SELECT s.id, s.course_id, s.room_id, s.lecturer_id, s.weekday, s.start_time, s.end_time, c.code, c.title, r.name, l.name
+		FROM course_sessions s
+		JOIN courses c ON c.id = s.course_id
+		JOIN rooms r ON r.id = s.room_id
+		JOIN lecturers l ON l.id = s.lecturer_id
+		WHERE `+condition+` ORDER BY s.weekday, s.start_time`, arg)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []TimetableEntry{}
+	for rows.Next() {
+		var e TimetableEntry
+		fields := append(e.Session.fields(), &e.CourseCode, &e.CourseTitle, &e.Room, &e.Lecturer)
+		if err := rows.Scan(fields...); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// Student timetable
+func (h *HybridHandler5) StudentTimetableHandler(w http.ResponseWriter, r *http.Request) {
+	h.serveTimetable(w, r, studentResource.Name, "s.course_id IN (SELECT course_id FROM enrollments WHERE student_id=?)", func(id int) error {
+		_, err := studentResource.load(h, id)
+		return err
+	})
+}
+
+// Lecturer timetable
+func (h *HybridHandler5) LecturerTimetableHandler(w http.ResponseWriter, r *http.Request) {
+	h.serveTimetable(w, r, lecturerResource.Name, "s.lecturer_id=?", func(id int) error {
+		_, err := lecturerResource.load(h, id)
+		return err
+	})
+}
+
+// serveTimetable answers with JSON, or with an iCalendar feed when the path
+// ends in .ics. The feed starts on ?from=YYYY-MM-DD, default today.
+func (h *HybridHandler5) serveTimetable(w http.ResponseWriter, r *http.Request, owner, condition string, exists func(id int) error) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "invalid "+owner+" id", http.StatusBadRequest)
+		return
+	}
+	if err := exists(id); err != nil {
+		writeLoadError(w, owner, err)
+		return
+	}
+	entries, err := h.timetable(condition, id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !strings.HasSuffix(r.URL.Path, ".ics") {
+		writeJSON(w, http.StatusOK, entries)
+		return
+	}
+	from := time.Now()
+	if v := r.URL.Query().Get("from"); v != "" {
+		if from, err = time.Parse(time.DateOnly, v); err != nil {
+			http.Error(w, "from must be YYYY-MM-DD", http.StatusBadRequest)
+			return
+		}
+	}
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=%s-%d.ics", owner, id))
+	WriteICS(w, fmt.Sprintf("%s %d timetable", owner, id), entries, from)
+}
+
+// WriteICS writes entries as weekly recurring events, each starting on its
+// first weekday on or after from.
+func WriteICS(w io.Writer, name string, entries []TimetableEntry, from time.Time) error {
+	lines := []string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//managementsystem//timetable//EN",
+		"CALSCALE:GREGORIAN",
+		"X-WR-CALNAME:" + icsEscape(name),
+	}
+	stamp := time.Now().UTC().Format("20060102T150405Z")
+	day := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.Local)
+	for _, e := range entries {
+		// ISO weekday 1..7 onto Go's Sunday=0
+		offset := (e.Weekday%7 - int(day.Weekday()) + 7) % 7
+		date := day.AddDate(0, 0, offset).Format("20060102")
+		lines = append(lines,
+			"BEGIN:VEVENT",
+			fmt.Sprintf("UID:session-%d@managementsystem", e.ID),
+			"DTSTAMP:"+stamp,
+			"DTSTART:"+date+"T"+strings.ReplaceAll(e.StartTime, ":", ""),
+			"DTEND:"+date+"T"+strings.ReplaceAll(e.EndTime, ":", ""),
+			"RRULE:FREQ=WEEKLY",
+			"SUMMARY:"+icsEscape(e.CourseCode+" "+e.CourseTitle),
+			"LOCATION:"+icsEscape(e.Room),
+			"DESCRIPTION:"+icsEscape("Lecturer: "+e.Lecturer),
+			"END:VEVENT",
+		)
+	}
+	lines = append(lines, "END:VCALENDAR")
+	for _, line := range lines {
+		if _, err := io.WriteString(w, icsFold(line)+"\r\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// icsFold splits lines longer than 75 bytes as RFC 5545 requires, without
+// cutting a UTF-8 sequence.
+func icsFold(line string) string {
+	var b strings.Builder
+	limit := 75
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		b.WriteString(line[:cut] + "\r\n ")
+		line = line[cut:]
+		limit = 74
+	}
+	b.WriteString(line)
+	return b.String()
+}
+
+func icsEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`).Replace(s)
+}
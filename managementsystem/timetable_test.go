@@ -0,0 +1,203 @@
+package managementsystem_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log"
+	managementsystem "managementsystem/managementsystem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFindConflicts(t *testing.T) {
+	existing := []managementsystem.Session{
+		{ID: 1, CourseID: 10, RoomID: 100, LecturerID: 7, Weekday: 1, StartTime: "09:00:00", EndTime: "10:00:00"},
+		{ID: 2, CourseID: 20, RoomID: 200, LecturerID: 8, Weekday: 1, StartTime: "11:00:00", EndTime: "12:00:00"},
+	}
+	enrolled := map[int][]int{10: {1001}, 20: {1002}, 30: {1001, 1002}}
+
+	tests := []struct {
+		name      string // description of this test case
+		candidate managementsystem.Session
+		want      []string
+	}{
+		{
+			name:      "free slot",
+			candidate: managementsystem.Session{CourseID: 40, RoomID: 300, LecturerID: 9, Weekday: 1, StartTime: "10:00:00", EndTime: "11:00:00"},
+			want:      nil,
+		},
+		{
+			name:      "lecturer double booked",
+			candidate: managementsystem.Session{CourseID: 40, RoomID: 300, LecturerID: 7, Weekday: 1, StartTime: "09:30:00", EndTime: "10:30:00"},
+			want:      []string{"lecturer"},
+		},
+		{
+			name:      "room double booked",
+			candidate: managementsystem.Session{CourseID: 40, RoomID: 200, LecturerID: 9, Weekday: 1, StartTime: "11:30:00", EndTime: "12:30:00"},
+			want:      []string{"room"},
+		},
+		{
+			name:      "student in overlapping course",
+			candidate: managementsystem.Session{CourseID: 30, RoomID: 300, LecturerID: 9, Weekday: 1, StartTime: "08:30:00", EndTime: "09:15:00"},
+			want:      []string{"student"},
+		},
+		{
+			name:      "same time on another day",
+			candidate: managementsystem.Session{CourseID: 30, RoomID: 100, LecturerID: 7, Weekday: 2, StartTime: "09:00:00", EndTime: "10:00:00"},
+			want:      nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conflicts := managementsystem.FindConflicts(tt.candidate, existing, enrolled)
+			if len(conflicts) != len(tt.want) {
+				t.Fatalf("Expected %d conflicts, got %+v", len(tt.want), conflicts)
+			}
+			for i, c := range conflicts {
+				if c.Type != tt.want[i] {
+					t.Fatalf("Expected %s conflict, got %s", tt.want[i], c.Type)
+				}
+			}
+		})
+	}
+}
+
+func TestWriteICS(t *testing.T) {
+	entries := []managementsystem.TimetableEntry{
+		{
+			Session:     managementsystem.Session{ID: 5, Weekday: 3, StartTime: "09:00:00", EndTime: "10:30:00"},
+			CourseCode:  "CSE101",
+			CourseTitle: "Programming, in Go",
+			Room:        "A-101",
+			Lecturer:    "Ramesh",
+		},
+	}
+	// 2026-10-19 is a Monday, so the first Wednesday is the 21st
+	from := time.Date(2026, 10, 19, 0, 0, 0, 0, time.Local)
+
+	var buf bytes.Buffer
+	if err := managementsystem.WriteICS(&buf, "student 1 timetable", entries, from); err != nil {
+		t.Fatalf("WriteICS failed: %v", err)
+	}
+	ics := buf.String()
+	for _, want := range []string{
+		"BEGIN:VCALENDAR\r\n",
+		"DTSTART:20261021T090000\r\n",
+		"DTEND:20261021T103000\r\n",
+		"RRULE:FREQ=WEEKLY\r\n",
+		"SUMMARY:CSE101 Programming\\, in Go\r\n",
+		"END:VCALENDAR\r\n",
+	} {
+		if !strings.Contains(ics, want) {
+			t.Fatalf("Expected %q in feed:\n%s", want, ics)
+		}
+	}
+}
+
+func TestHybridHandler5_CreateSession(t *testing.T) {
+
+	os.Setenv("REDIS_ADDR", "localhost:6379")
+	os.Setenv("MySQL_DSN", "root:root@tcp(127.0.0.1:3306)/management_sys")
+
+	redisInstance, err := managementsystem.ConnectRedis()
+	if err != nil {
+		panic(err)
+	}
+	mysqlinstance, err := managementsystem.ConnectMySQL()
+	if err != nil {
+		panic(err)
+	}
+	handler := &managementsystem.HybridHandler5{MySQL: mysqlinstance, Redis: redisInstance, Ctx: context.Background()}
+	router := handler.Router()
+
+	mysqlinstance.DB.Exec("DELETE FROM course_sessions")
+	mysqlinstance.DB.Exec("DELETE FROM enrollments")
+	mysqlinstance.DB.Exec("DELETE FROM courses")
+	mysqlinstance.DB.Exec("DELETE FROM rooms")
+	mysqlinstance.DB.Exec("DELETE FROM lecturers")
+	redisInstance.Client.FlushAll(context.Background())
+
+	mysqlinstance.DB.Exec("INSERT INTO lecturers (id , name , email, dept , designation) VALUES (1, 'ramesh', 'ramesh@gmail.com', 'CSE', 'Lecturer'), (2, 'suresh', 'suresh@gmail.com', 'CSE', 'Lecturer')")
+	mysqlinstance.DB.Exec("INSERT INTO courses (id, code, title) VALUES (1, 'CSE101', 'Programming'), (2, 'CSE102', 'Databases')")
+	mysqlinstance.DB.Exec("INSERT INTO rooms (id, name, capacity) VALUES (1, 'A-101', 60), (2, 'A-102', 60)")
+	res, err := mysqlinstance.DB.Exec("INSERT INTO course_sessions (course_id, room_id, lecturer_id, weekday, start_time, end_time) VALUES (1, 1, 1, 1, '09:00', '10:00')")
+	if err != nil {
+		t.Fatalf("insert fail: %v", err)
+	}
+	first, _ := res.LastInsertId()
+
+	tests := []struct {
+		name    string // description of this test case
+		session managementsystem.Session
+		status  int
+	}{
+		{
+			name:    "free slot",
+			session: managementsystem.Session{CourseID: 2, RoomID: 2, LecturerID: 2, Weekday: 1, StartTime: "9:00", EndTime: "10:00"},
+			status:  http.StatusCreated,
+		},
+		{
+			name:    "lecturer double booked",
+			session: managementsystem.Session{CourseID: 2, RoomID: 2, LecturerID: 1, Weekday: 1, StartTime: "09:30", EndTime: "10:30"},
+			status:  http.StatusConflict,
+		},
+		{
+			name:    "end before start",
+			session: managementsystem.Session{CourseID: 2, RoomID: 2, LecturerID: 2, Weekday: 2, StartTime: "11:00", EndTime: "10:00"},
+			status:  http.StatusBadRequest,
+		},
+		{
+			name:    "invalid weekday",
+			session: managementsystem.Session{CourseID: 2, RoomID: 2, LecturerID: 2, Weekday: 8, StartTime: "09:00", EndTime: "10:00"},
+			status:  http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			userBytes, err := json.Marshal(tt.session)
+			if err != nil {
+				log.Panic(err)
+			}
+			r := httptest.NewRequest(http.MethodPost, "/sessions", bytes.NewBuffer(userBytes))
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, r)
+
+			if w.Code != tt.status {
+				t.Fatalf("Expected status %d, got %d: %s", tt.status, w.Code, w.Body.String())
+			}
+		})
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/lecturers/1/timetable.ics", nil))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "SUMMARY:CSE101 Programming") {
+		t.Fatalf("Expected lecturer feed with CSE101, got %d: %s", w.Code, w.Body.String())
+	}
+
+	// a session written past the checks clashes with the first one's room
+	res, err = mysqlinstance.DB.Exec("INSERT INTO course_sessions (course_id, room_id, lecturer_id, weekday, start_time, end_time) VALUES (2, 1, 2, 1, '09:30', '10:30')")
+	if err != nil {
+		t.Fatalf("insert fail: %v", err)
+	}
+	clash, _ := res.LastInsertId()
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/timetable/conflicts", nil))
+	var report []managementsystem.TimetableConflict
+	if err := json.NewDecoder(w.Body).Decode(&report); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	found := false
+	for _, c := range report {
+		if c.Type == "room" && c.SessionID == int(first) && c.ConflictsWith == int(clash) {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("Expected session %d to clash with %d, got %+v", first, clash, report)
+	}
+}
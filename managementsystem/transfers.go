@@ -0,0 +1,266 @@
+package managementsystem
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"managementsystem/validation"
+)
+
+// Transfer statuses. A copy in transit stays at the branch it left until
+// the branch it goes to receives it.
+const (
+	transferInTransit = "in_transit"
+	transferReceived  = "received"
+	transferCancelled = "cancelled"
+)
+
+// Transfer moves a copy, named by id or barcode, to another branch.
+type Transfer struct {
+	ID           int     `json:"id"`
+	CopyID       int     `json:"copy_id" validate:"min=0"`
+	Barcode      string  `json:"barcode" validate:"trimmed,max=32"`
+	BookID       int     `json:"book_id"`
+	FromBranchID int     `json:"from_branch_id"`
+	ToBranchID   int     `json:"to_branch_id" validate:"min=1"`
+	Status       string  `json:"status"`
+	Reason       string  `json:"reason" validate:"trimmed,max=255"`
+	RequestedBy  string  `json:"requested_by" validate:"trimmed,max=100"`
+	SentAt       string  `json:"sent_at"`
+	ClosedAt     *string `json:"closed_at"` // when it was received or cancelled
+}
+
+const transferSelect = `SELECT t.id, t.copy_id, c.barcode, c.book_id, t.from_branch_id, t.to_branch_id, t.status,
+	IFNULL(t.reason, ''), IFNULL(t.requested_by, ''),
+	DATE_FORMAT(t.sent_at, '%Y-%m-%d %H:%i:%s'), DATE_FORMAT(t.closed_at, '%Y-%m-%d %H:%i:%s')
+	FROM transfers t JOIN book_copies c ON c.copy_id = t.copy_id`
+
+func scanTransfer(row interface{ Scan(...any) error }, t *Transfer) error {
+	return row.Scan(&t.ID, &t.CopyID, &t.Barcode, &t.BookID, &t.FromBranchID, &t.ToBranchID, &t.Status,
+		&t.Reason, &t.RequestedBy, &t.SentAt, &t.ClosedAt)
+}
+
+// loadTransfer reads a transfer, locking it when q is a transaction.
+func loadTransfer(q querier, id int) (*Transfer, error) {
+	var t Transfer
+	if err := scanTransfer(q.QueryRow(transferSelect+" WHERE t.id=? FOR UPDATE", id), &t); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// startTransfer sends a copy from one branch to another, taking it off the
+// shelf until it is received.
+func startTransfer(tx querier, copyID, from, to int, reason, requestedBy string) (*Transfer, error) {
+	if _, err := tx.Exec("UPDATE book_copies SET status=? WHERE copy_id=?", copyInTransit, copyID); err != nil {
+		return nil, err
+	}
+	res, err := tx.Exec("INSERT INTO transfers (copy_id, from_branch_id, to_branch_id, status, reason, requested_by) VALUES (?, ?, ?, ?, NULLIF(?, ''), NULLIF(?, ''))", copyID, from, to, transferInTransit, reason, requestedBy)
+	if err != nil {
+		return nil, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	return loadTransfer(tx, int(id))
+}
+
+func transferID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "invalid transfer id", http.StatusBadRequest)
+		return 0, false
+	}
+	return id, true
+}
+
+// Send a copy to another branch
+func (h *HybridHandler5) CreateTransferHandler(w http.ResponseWriter, r *http.Request) {
+	var t Transfer
+	if err := json.NewDecoder(r.Body).Decode(&t); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := validation.Struct(t); err != nil {
+		writeValidationError(w, r, err)
+		return
+	}
+	if t.CopyID == 0 && t.Barcode == "" {
+		http.Error(w, "copy_id or barcode is required", http.StatusBadRequest)
+		return
+	}
+	tx, err := h.MySQL.DB.Begin()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	var c *BookCopy
+	if t.CopyID != 0 {
+		c, err = loadCopy(tx, "copy_id=?", t.CopyID)
+	} else {
+		c, err = loadCopy(tx, "barcode=?", t.Barcode)
+	}
+	if err != nil {
+		copyResource.writeLoadError(w, err)
+		return
+	}
+	if err := checkBranch(tx, t.ToBranchID); err != nil {
+		writeError(w, err)
+		return
+	}
+	if c.Branch_id == t.ToBranchID {
+		http.Error(w, "copy is already at branch "+strconv.Itoa(c.Branch_id), http.StatusBadRequest)
+		return
+	}
+	// copies on loan or set aside for a hold stay where they are
+	if c.Status != copyAvailable {
+		http.Error(w, "copy is "+c.Status, http.StatusConflict)
+		return
+	}
+	sent, err := startTransfer(tx, c.Copy_id, c.Branch_id, t.ToBranchID, t.Reason, t.RequestedBy)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	h.cacheDel(bookResource.cacheKey(c.Book_id))
+	writeJSON(w, http.StatusCreated, sent)
+}
+
+// List transfers, filtered by ?status=, ?from_branch_id= and ?to_branch_id=
+func (h *HybridHandler5) ListTransfersHandler(w http.ResponseWriter, r *http.Request) {
+	limit, offset, err := pagination(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	where, args := "1=1", []any{}
+	query := r.URL.Query()
+	switch status := query.Get("status"); status {
+	case "":
+	case transferInTransit, transferReceived, transferCancelled:
+		where += " AND t.status=?"
+		args = append(args, status)
+	default:
+		http.Error(w, "status must be in_transit, received or cancelled", http.StatusBadRequest)
+		return
+	}
+	for _, column := range []string{"from_branch_id", "to_branch_id"} {
+		v := query.Get(column)
+		if v == "" {
+			continue
+		}
+		id, err := strconv.Atoi(v)
+		if err != nil {
+			http.Error(w, "invalid "+column, http.StatusBadRequest)
+			return
+		}
+		where += " AND t." + column + "=?"
+		args = append(args, id)
+	}
+	rows, err := h.MySQL.DB.Query(transferSelect+" WHERE "+where+" ORDER BY t.id DESC LIMIT ? OFFSET ?", append(args, limit, offset)...)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	transfers := []Transfer{}
+	for rows.Next() {
+		var t Transfer
+		if err := scanTransfer(rows, &t); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		transfers = append(transfers, t)
+	}
+	if err := rows.Err(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, transfers)
+}
+
+// Get transfer
+func (h *HybridHandler5) GetTransferHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := transferID(w, r)
+	if !ok {
+		return
+	}
+	t, err := loadTransfer(h.MySQL.DB, id)
+	if err != nil {
+		writeLoadError(w, "transfer", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, t)
+}
+
+// Receive a copy at the branch it was sent to
+func (h *HybridHandler5) ReceiveTransferHandler(w http.ResponseWriter, r *http.Request) {
+	h.closeTransfer(w, r, transferReceived)
+}
+
+// Cancel a transfer, putting the copy back on the shelf it left
+func (h *HybridHandler5) CancelTransferHandler(w http.ResponseWriter, r *http.Request) {
+	h.closeTransfer(w, r, transferCancelled)
+}
+
+// closeTransfer ends a transfer in transit as received or cancelled. The
+// copy is available again at the branch it went to or came from, and goes
+// to the first waiting hold, if any.
+func (h *HybridHandler5) closeTransfer(w http.ResponseWriter, r *http.Request, status string) {
+	id, ok := transferID(w, r)
+	if !ok {
+		return
+	}
+	tx, err := h.MySQL.DB.Begin()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	t, err := loadTransfer(tx, id)
+	if err != nil {
+		writeLoadError(w, "transfer", err)
+		return
+	}
+	if t.Status != transferInTransit {
+		http.Error(w, "transfer is already "+t.Status, http.StatusConflict)
+		return
+	}
+	branchID := t.FromBranchID
+	if status == transferReceived {
+		branchID = t.ToBranchID
+	}
+	if _, err := tx.Exec("UPDATE book_copies SET branch_id=?, status=? WHERE copy_id=? AND status=?", branchID, copyAvailable, t.CopyID, copyInTransit); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if _, err := tx.Exec("UPDATE transfers SET status=?, closed_at=NOW() WHERE id=?", status, id); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := refreshHolds(tx, t.BookID); err != nil {
+		writeError(w, err)
+		return
+	}
+	if t, err = loadTransfer(tx, id); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	h.cacheDel(bookResource.cacheKey(t.BookID))
+	writeJSON(w, http.StatusOK, t)
+}
@@ -0,0 +1,325 @@
+// Package validation checks structs against rules declared in `validate`
+// struct tags, e.g.
+//
+//	Name  string `json:"name" validate:"trimmed,required,max=100"`
+//	Email string `json:"email" validate:"required,email=gmail.com"`
+//	Type  string `json:"type" validate:"enum=student|lecturer"`
+//
+// Rules are applied left to right and every failing field is reported.
+// Messages ship in English and Hindi; new rules are added with
+// RegisterRule and messages for other languages with SetMessage. The
+// domain of an email rule is compared case-sensitively.
+package validation
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultLocale is the language used when no translation is available.
+const DefaultLocale = "en"
+
+// RuleFunc reports whether value satisfies the rule given its tag parameter.
+type RuleFunc func(value reflect.Value, param string) bool
+
+// FieldError describes one failed rule on one field.
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Param   string `json:"param,omitempty"`
+	Message string `json:"message"`
+}
+
+func (e *FieldError) Error() string {
+	return e.Message
+}
+
+// Errors is the list of failed rules returned by Struct.
+type Errors []*FieldError
+
+func (e Errors) Error() string {
+	messages := make([]string, len(e))
+	for i, fe := range e {
+		messages[i] = fe.Message
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Validator holds the rule set and message catalogue.
+type Validator struct {
+	mu       sync.RWMutex
+	rules    map[string]RuleFunc
+	messages map[string]map[string]string
+}
+
+// New returns a Validator with the built-in rules, their English messages
+// and the translations in builtinTranslations.
+func New() *Validator {
+	v := &Validator{
+		rules:    map[string]RuleFunc{},
+		messages: map[string]map[string]string{},
+	}
+	for name, rule := range builtinRules {
+		v.rules[name] = rule
+	}
+	for rule, message := range englishMessages {
+		v.SetMessage(DefaultLocale, rule, message)
+	}
+	for locale, messages := range builtinTranslations {
+		for rule, message := range messages {
+			v.SetMessage(locale, rule, message)
+		}
+	}
+	return v
+}
+
+// RegisterRule adds or replaces a rule. message is the English template,
+// where {field} and {param} are substituted.
+func (v *Validator) RegisterRule(name string, rule RuleFunc, message string) {
+	v.mu.Lock()
+	v.rules[name] = rule
+	v.mu.Unlock()
+	v.SetMessage(DefaultLocale, name, message)
+}
+
+// SetMessage sets the message template of a rule for a locale.
+func (v *Validator) SetMessage(locale, rule, message string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.messages[locale] == nil {
+		v.messages[locale] = map[string]string{}
+	}
+	v.messages[locale][rule] = message
+}
+
+// Struct validates s, a struct or pointer to struct, and returns Errors
+// with messages in the default locale, or nil.
+func (v *Validator) Struct(s any) error {
+	return v.StructLocale(s, DefaultLocale)
+}
+
+// StructLocale is Struct with messages in the given locale.
+func (v *Validator) StructLocale(s any, locale string) error {
+	rv := reflect.ValueOf(s)
+	for rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return fmt.Errorf("validation: nil %s", rv.Type())
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return fmt.Errorf("validation: %s is not a struct", rv.Type())
+	}
+	var errs Errors
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		sf := rt.Field(i)
+		tag, ok := sf.Tag.Lookup("validate")
+		if !ok || tag == "-" || !sf.IsExported() {
+			continue
+		}
+		if fe := v.field(rv.Field(i), fieldName(sf), tag, locale); fe != nil {
+			errs = append(errs, fe)
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// Translate re-renders the messages of a validation error in locale. Other
+// errors are returned unchanged.
+func (v *Validator) Translate(err error, locale string) error {
+	errs, ok := err.(Errors)
+	if !ok {
+		return err
+	}
+	out := make(Errors, len(errs))
+	for i, fe := range errs {
+		out[i] = &FieldError{Field: fe.Field, Rule: fe.Rule, Param: fe.Param}
+		out[i].Message = v.message(locale, fe.Rule, fe.Field, fe.Param)
+	}
+	return out
+}
+
+// field checks one field and returns its first failed rule.
+func (v *Validator) field(value reflect.Value, name, tag, locale string) *FieldError {
+	for value.Kind() == reflect.Pointer {
+		if value.IsNil() {
+			break
+		}
+		value = value.Elem()
+	}
+	for _, spec := range strings.Split(tag, ",") {
+		rule, param, _ := strings.Cut(strings.TrimSpace(spec), "=")
+		switch rule {
+		case "":
+			continue
+		case "omitempty":
+			if value.IsZero() {
+				return nil
+			}
+			continue
+		case "trimmed":
+			if value.Kind() == reflect.String {
+				value = reflect.ValueOf(strings.TrimSpace(value.String()))
+			}
+			continue
+		}
+		v.mu.RLock()
+		fn, ok := v.rules[rule]
+		v.mu.RUnlock()
+		if !ok {
+			return &FieldError{Field: name, Rule: rule, Param: param, Message: fmt.Sprintf("%s: unknown validation rule %q", name, rule)}
+		}
+		if !fn(value, param) {
+			return &FieldError{Field: name, Rule: rule, Param: param, Message: v.message(locale, rule, name, param)}
+		}
+	}
+	return nil
+}
+
+// message renders the template of rule, falling back to English and then
+// to a generic message.
+func (v *Validator) message(locale, rule, field, param string) string {
+	v.mu.RLock()
+	tmpl, ok := v.messages[normalizeLocale(locale)][rule]
+	if !ok {
+		tmpl, ok = v.messages[DefaultLocale][rule]
+	}
+	v.mu.RUnlock()
+	if !ok {
+		tmpl = "{field} is invalid"
+	}
+	param = strings.ReplaceAll(param, "|", ", ")
+	return strings.NewReplacer("{field}", field, "{param}", param).Replace(tmpl)
+}
+
+// normalizeLocale turns an Accept-Language value such as "hi-IN,hi;q=0.9"
+// into its primary language tag.
+func normalizeLocale(locale string) string {
+	locale, _, _ = strings.Cut(locale, ",")
+	locale, _, _ = strings.Cut(locale, ";")
+	locale, _, _ = strings.Cut(locale, "-")
+	locale = strings.ToLower(strings.TrimSpace(locale))
+	if locale == "" {
+		return DefaultLocale
+	}
+	return locale
+}
+
+// fieldName is the json name of a field, which is what API clients see.
+func fieldName(sf reflect.StructField) string {
+	name, _, _ := strings.Cut(sf.Tag.Get("json"), ",")
+	if name == "" || name == "-" {
+		return sf.Name
+	}
+	return name
+}
+
+var builtinRules = map[string]RuleFunc{
+	"required": func(value reflect.Value, _ string) bool {
+		return value.IsValid() && !value.IsZero()
+	},
+	"min": func(value reflect.Value, param string) bool {
+		n, ok := measure(value)
+		limit, err := strconv.ParseFloat(param, 64)
+		return ok && err == nil && n >= limit
+	},
+	"max": func(value reflect.Value, param string) bool {
+		n, ok := measure(value)
+		limit, err := strconv.ParseFloat(param, 64)
+		return ok && err == nil && n <= limit
+	},
+	"email": func(value reflect.Value, param string) bool {
+		if value.Kind() != reflect.String {
+			return false
+		}
+		local, domain, ok := strings.Cut(value.String(), "@")
+		if !ok || local == "" || domain == "" || strings.ContainsAny(local+domain, " @") {
+			return false
+		}
+		return param == "" || domain == param
+	},
+	"enum": func(value reflect.Value, param string) bool {
+		if value.Kind() != reflect.String {
+			return false
+		}
+		for _, option := range strings.Split(param, "|") {
+			if value.String() == option {
+				return true
+			}
+		}
+		return false
+	},
+}
+
+var englishMessages = map[string]string{
+	"required": "{field} is required",
+	"min":      "{field} must be at least {param}",
+	"max":      "{field} must be at most {param}",
+	"email":    "{field} must be a valid email address",
+	"enum":     "{field} must be one of: {param}",
+}
+
+// builtinTranslations are the messages of the built-in rules in other
+// languages, by locale.
+var builtinTranslations = map[string]map[string]string{
+	"hi": {
+		"required": "{field} आवश्यक है",
+		"min":      "{field} कम से कम {param} होना चाहिए",
+		"max":      "{field} अधिकतम {param} होना चाहिए",
+		"email":    "{field} एक मान्य ईमेल पता होना चाहिए",
+		"enum":     "{field} इनमें से एक होना चाहिए: {param}",
+	},
+}
+
+// measure returns the number a min/max rule compares: the value of numbers
+// and the length of strings, slices and maps.
+func measure(value reflect.Value) (float64, bool) {
+	switch value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(value.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(value.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return value.Float(), true
+	case reflect.String:
+		return float64(len([]rune(value.String()))), true
+	case reflect.Slice, reflect.Map, reflect.Array:
+		return float64(value.Len()), true
+	}
+	return 0, false
+}
+
+// Default is the validator used by the package level functions.
+var Default = New()
+
+// Struct validates s with the Default validator.
+func Struct(s any) error {
+	return Default.Struct(s)
+}
+
+// StructLocale validates s with the Default validator in locale.
+func StructLocale(s any, locale string) error {
+	return Default.StructLocale(s, locale)
+}
+
+// Translate re-renders err in locale with the Default validator.
+func Translate(err error, locale string) error {
+	return Default.Translate(err, locale)
+}
+
+// RegisterRule adds a rule to the Default validator.
+func RegisterRule(name string, rule RuleFunc, message string) {
+	Default.RegisterRule(name, rule, message)
+}
+
+// SetMessage sets a message template on the Default validator.
+func SetMessage(locale, rule, message string) {
+	Default.SetMessage(locale, rule, message)
+}
@@ -0,0 +1,170 @@
+package validation_test
+
+import (
+	"errors"
+	"managementsystem/validation"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type member struct {
+	Name  string `json:"name" validate:"trimmed,required,max=10"`
+	Email string `json:"email" validate:"required,email=gmail.com"`
+	Age   int    `json:"age" validate:"min=1,max=99"`
+	Type  string `json:"type" validate:"enum=student|lecturer"`
+	Note  string `json:"note" validate:"omitempty,min=3"`
+}
+
+func TestStruct(t *testing.T) {
+	valid := member{Name: "Akash", Email: "akash@gmail.com", Age: 21, Type: "student"}
+
+	tests := []struct {
+		name     string // description of this test case
+		member   member
+		field    string
+		willpass bool
+	}{
+		{
+			name:     "valid",
+			member:   valid,
+			willpass: true,
+		},
+		{
+			name:     "withspace name",
+			member:   member{Name: "   ", Email: valid.Email, Age: valid.Age, Type: valid.Type},
+			field:    "name",
+			willpass: false,
+		},
+		{
+			name:     "name too long",
+			member:   member{Name: "Akash Kumar Paul", Email: valid.Email, Age: valid.Age, Type: valid.Type},
+			field:    "name",
+			willpass: false,
+		},
+		{
+			name:     "email without prefix",
+			member:   member{Name: valid.Name, Email: "@gmail.com", Age: valid.Age, Type: valid.Type},
+			field:    "email",
+			willpass: false,
+		},
+		{
+			name:     "email with other domain",
+			member:   member{Name: valid.Name, Email: "akash@yahoo.com", Age: valid.Age, Type: valid.Type},
+			field:    "email",
+			willpass: false,
+		},
+		{
+			name:     "email domain in another case",
+			member:   member{Name: valid.Name, Email: "akash@GMAIL.COM", Age: valid.Age, Type: valid.Type},
+			field:    "email",
+			willpass: false,
+		},
+		{
+			name:     "age below min",
+			member:   member{Name: valid.Name, Email: valid.Email, Age: 0, Type: valid.Type},
+			field:    "age",
+			willpass: false,
+		},
+		{
+			name:     "age above max",
+			member:   member{Name: valid.Name, Email: valid.Email, Age: 100, Type: valid.Type},
+			field:    "age",
+			willpass: false,
+		},
+		{
+			name:     "type outside enum",
+			member:   member{Name: valid.Name, Email: valid.Email, Age: valid.Age, Type: "staff"},
+			field:    "type",
+			willpass: false,
+		},
+		{
+			name:     "optional note too short",
+			member:   member{Name: valid.Name, Email: valid.Email, Age: valid.Age, Type: valid.Type, Note: "ok"},
+			field:    "note",
+			willpass: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validation.Struct(&tt.member)
+			if tt.willpass {
+				if err != nil {
+					t.Fatalf("Expected no error, got %v", err)
+				}
+				return
+			}
+			var errs validation.Errors
+			if !errors.As(err, &errs) {
+				t.Fatalf("Expected validation.Errors, got %v", err)
+			}
+			if len(errs) != 1 || errs[0].Field != tt.field {
+				t.Fatalf("Expected one error on %s, got %v", tt.field, errs)
+			}
+		})
+	}
+}
+
+func TestStructReportsEveryField(t *testing.T) {
+	err := validation.Struct(member{})
+
+	var errs validation.Errors
+	if !errors.As(err, &errs) {
+		t.Fatalf("Expected validation.Errors, got %v", err)
+	}
+	if len(errs) != 4 {
+		t.Fatalf("Expected 4 errors, got %d: %v", len(errs), errs)
+	}
+}
+
+func TestRegisterRuleAndTranslate(t *testing.T) {
+	v := validation.New()
+	v.RegisterRule("even", func(value reflect.Value, _ string) bool {
+		return value.Int()%2 == 0
+	}, "{field} must be even")
+	v.SetMessage("hi", "even", "{field} सम होना चाहिए")
+
+	type pair struct {
+		Count int `json:"count" validate:"even"`
+	}
+
+	if err := v.Struct(pair{Count: 2}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	err := v.Struct(pair{Count: 3})
+	if err == nil || err.Error() != "count must be even" {
+		t.Fatalf("Expected 'count must be even', got %v", err)
+	}
+	translated := v.Translate(err, "hi-IN,hi;q=0.9")
+	if !strings.Contains(translated.Error(), "सम होना चाहिए") {
+		t.Fatalf("Expected hindi message, got %v", translated)
+	}
+	fallback := v.Translate(err, "fr")
+	if fallback.Error() != "count must be even" {
+		t.Fatalf("Expected english fallback, got %v", fallback)
+	}
+}
+
+func TestTranslateBuiltinRules(t *testing.T) {
+	type signup struct {
+		Name string `json:"name" validate:"required"`
+		Type string `json:"type" validate:"enum=student|lecturer"`
+	}
+	err := validation.Struct(signup{Type: "guest"})
+	tests := []struct {
+		name   string // description of this test case
+		locale string
+		want   string
+	}{
+		{name: "english", locale: "en", want: "name is required; type must be one of: student, lecturer"},
+		{name: "hindi", locale: "hi-IN,hi;q=0.9", want: "name आवश्यक है; type इनमें से एक होना चाहिए: student, lecturer"},
+		{name: "no catalogue falls back to english", locale: "fr", want: "name is required; type must be one of: student, lecturer"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := validation.Translate(err, tt.locale).Error(); got != tt.want {
+				t.Fatalf("Expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}